@@ -0,0 +1,494 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/dgutierrez1287/vault-util/util"
+)
+
+// how often the agent checks for changes when the config does not say
+const DefaultAgentPollInterval = 30 * time.Second
+
+// agent states in the status file
+const (
+  AgentStateRunning = "running"
+  AgentStateStopped = "stopped"
+)
+
+/*
+AgentConfig - the config file for the agent, every file is
+written from a secret and kept up to date as it changes
+*/
+type AgentConfig struct {
+  PollInterval string            `json:"pollInterval,omitempty"`
+  StatusFile string              `json:"statusFile,omitempty"`
+  Files []AgentFile              `json:"files"`
+  pollInterval time.Duration
+}
+
+/*
+AgentFile - a file written from a secret, with a field only
+the value of the field is written otherwise all of the data
+is written as json. The owner is user or user:group and the
+hook is a shell command run after the file changes
+*/
+type AgentFile struct {
+  SecretKey string               `json:"secretKey"`
+  Field string                   `json:"field,omitempty"`
+  Format string                  `json:"format,omitempty"`
+  Destination string             `json:"destination"`
+  Mode string                    `json:"mode,omitempty"`
+  Owner string                   `json:"owner,omitempty"`
+  Hook string                    `json:"hook,omitempty"`
+  mode os.FileMode
+  uid int
+  gid int
+}
+
+/*
+AgentStatus - the health of the agent, it is written to the
+status file after every check so other tools can read it
+*/
+type AgentStatus struct {
+  Pid int                        `json:"pid"`
+  State string                   `json:"state"`
+  Healthy bool                   `json:"healthy"`
+  Error string                   `json:"error,omitempty"`
+  StartedTime time.Time          `json:"startedTime"`
+  LastCheckTime time.Time        `json:"lastCheckTime"`
+  LastSuccessTime *time.Time     `json:"lastSuccessTime,omitempty"`
+  Files []AgentFileStatus        `json:"files"`
+}
+
+/*
+AgentFileStatus - the last time a file was written and
+the version of the secret it was written from
+*/
+type AgentFileStatus struct {
+  Destination string             `json:"destination"`
+  SecretKey string               `json:"secretKey"`
+  Version int64                  `json:"version"`
+  WrittenTime *time.Time         `json:"writtenTime,omitempty"`
+  Error string                   `json:"error,omitempty"`
+}
+
+/*
+agent - the state of a running agent
+*/
+type agent struct {
+  client *VaultClient
+  config AgentConfig
+  secrets map[string]VaultSecret
+  watcher *VersionWatcher
+  status AgentStatus
+  failed map[int]bool
+  failedHooks map[string]bool
+}
+
+/*
+This will load and check an agent config file
+*/
+func LoadAgentConfig(configFilePath string) (AgentConfig, error) {
+  var config AgentConfig
+
+  data, err := os.ReadFile(configFilePath)
+  if err != nil {
+    logger.LogError("Error reading agent config file")
+    return config, err
+  }
+
+  err = json.Unmarshal(data, &config)
+  if err != nil {
+    logger.LogError("Error unmarshaling agent config file")
+    return config, fmt.Errorf("%s: %w", configFilePath, err)
+  }
+
+  err = config.validate()
+  if err != nil {
+    return config, fmt.Errorf("%s: %w", configFilePath, err)
+  }
+  return config, nil
+}
+
+/*
+This will check the config and fill in the defaults, the
+modes and owners are looked up so mistakes are found before
+anything is written
+*/
+func (c *AgentConfig) validate() error {
+  c.pollInterval = DefaultAgentPollInterval
+  if c.PollInterval != "" {
+    interval, err := time.ParseDuration(c.PollInterval)
+    if err != nil || interval <= 0 {
+      return fmt.Errorf("poll interval %s must be a duration like 30s", c.PollInterval)
+    }
+    c.pollInterval = interval
+  }
+
+  if len(c.Files) == 0 {
+    return errors.New("at least one file is required")
+  }
+
+  destinations := make(map[string]bool)
+  for i := range c.Files {
+    file := &c.Files[i]
+    if file.SecretKey == "" || file.Destination == "" {
+      return fmt.Errorf("file %d must have a secretKey and a destination", i)
+    }
+    if destinations[file.Destination] {
+      return fmt.Errorf("destination %s is used more than once", file.Destination)
+    }
+    destinations[file.Destination] = true
+
+    if file.Field == "" && file.Format != "" {
+      return fmt.Errorf("%s: a format can only be used with a field", file.Destination)
+    }
+    if file.Field != "" {
+      _, err := FormatFieldValue("", file.Format)
+      if err != nil {
+        return fmt.Errorf("%s: %w", file.Destination, err)
+      }
+    }
+
+    file.mode = 0600
+    if file.Mode != "" {
+      mode, err := ParseFileMode(file.Mode)
+      if err != nil {
+        return fmt.Errorf("%s: %w", file.Destination, err)
+      }
+      file.mode = mode
+    }
+
+    uid, gid, err := lookupOwner(file.Owner)
+    if err != nil {
+      return fmt.Errorf("%s: %w", file.Destination, err)
+    }
+    file.uid = uid
+    file.gid = gid
+  }
+  return nil
+}
+
+/*
+This will get the uid and gid of an owner in the form user
+or user:group, -1 is returned for the parts that are not set
+*/
+func lookupOwner(owner string) (int, int, error) {
+  if owner == "" {
+    return -1, -1, nil
+  }
+
+  userName, groupName, _ := strings.Cut(owner, ":")
+  uid := -1
+  gid := -1
+
+  if userName != "" {
+    account, err := user.Lookup(userName)
+    if err != nil {
+      return -1, -1, err
+    }
+    uid, err = strconv.Atoi(account.Uid)
+    if err != nil {
+      return -1, -1, fmt.Errorf("user %s does not have a numeric uid", userName)
+    }
+  }
+
+  if groupName != "" {
+    group, err := user.LookupGroup(groupName)
+    if err != nil {
+      return -1, -1, err
+    }
+    gid, err = strconv.Atoi(group.Gid)
+    if err != nil {
+      return -1, -1, fmt.Errorf("group %s does not have a numeric gid", groupName)
+    }
+  }
+  return uid, gid, nil
+}
+
+/*
+This will run the agent, every file is written on start and
+the secret versions are polled so files are written again
+when their secret changes. Files that fail are tried again on
+the next check. With once the agent stops after the first write,
+otherwise it runs until the context is done
+*/
+func RunAgent(ctx context.Context, client *VaultClient, config AgentConfig,
+  once bool) error {
+
+  a := agent{
+    client: client,
+    config: config,
+    secrets: make(map[string]VaultSecret),
+    failed: make(map[int]bool),
+    failedHooks: make(map[string]bool),
+    status: AgentStatus{
+      Pid: os.Getpid(),
+      State: AgentStateRunning,
+      StartedTime: time.Now().UTC(),
+    },
+  }
+
+  var secrets []VaultSecret
+  for _, file := range config.Files {
+    key := strings.Trim(file.SecretKey, "/")
+    if _, ok := a.secrets[key]; ok {
+      continue
+    }
+    secret, err := NewSecret(key, "", "", nil, *client)
+    if err != nil {
+      return fmt.Errorf("%s: %w", key, err)
+    }
+    if secret.SecretType != "kv" {
+      return fmt.Errorf("%s: only kv secrets can be used", key)
+    }
+    a.secrets[key] = secret
+    secrets = append(secrets, secret)
+  }
+
+  for _, file := range config.Files {
+    a.status.Files = append(a.status.Files, AgentFileStatus{
+      Destination: file.Destination,
+      SecretKey: strings.Trim(file.SecretKey, "/"),
+    })
+  }
+
+  watcher, err := NewVersionWatcher(client, secrets)
+  if err != nil {
+    return err
+  }
+  a.watcher = watcher
+
+  logger.LogInfo("Writing secret files")
+  all := make([]int, len(config.Files))
+  for i := range all {
+    all[i] = i
+  }
+  a.sync(all)
+  if once {
+    a.status.State = AgentStateStopped
+    a.writeStatus()
+    if !a.status.Healthy {
+      return errors.New(a.status.Error)
+    }
+    return nil
+  }
+  a.writeStatus()
+
+  ticker := time.NewTicker(config.pollInterval)
+  defer ticker.Stop()
+  for {
+    select {
+    case <-ctx.Done():
+      logger.LogInfo("Stopping agent")
+      a.status.State = AgentStateStopped
+      a.writeStatus()
+      return nil
+
+    case <-ticker.C:
+      a.check()
+      a.writeStatus()
+    }
+  }
+}
+
+/*
+This will check the secret versions and write the files of
+the secrets that changed, files and hooks that failed before
+are tried again
+*/
+func (a *agent) check() {
+  a.status.LastCheckTime = time.Now().UTC()
+
+  changed, err := a.watcher.Changed()
+  if err != nil {
+    logger.LogError(fmt.Sprintf("Error checking secret versions, will check again: %s", err))
+    a.status.Healthy = false
+    a.status.Error = err.Error()
+    return
+  }
+
+  changedKeys := make(map[string]bool)
+  for _, key := range changed {
+    changedKeys[key] = true
+  }
+
+  var files []int
+  for i, file := range a.config.Files {
+    if changedKeys[strings.Trim(file.SecretKey, "/")] || a.failed[i] {
+      files = append(files, i)
+    }
+  }
+
+  if len(files) == 0 && len(a.failedHooks) == 0 {
+    logger.LogDebug("No secrets changed")
+    a.updateHealth()
+    return
+  }
+
+  if len(changed) > 0 {
+    logger.LogInfo("Secrets changed, writing files", "keys", changed)
+  }
+  a.sync(files)
+}
+
+/*
+This will write files from their secrets, every secret is
+read once and the hook of each file that changed is run once.
+Hooks that failed before are run again
+*/
+func (a *agent) sync(files []int) {
+  a.status.LastCheckTime = time.Now().UTC()
+
+  data := make(map[string]map[string]interface{})
+  readErrors := make(map[string]error)
+  var hooks []string
+  hooksSeen := make(map[string]bool)
+  for hook := range a.failedHooks {
+    hooksSeen[hook] = true
+    hooks = append(hooks, hook)
+  }
+  sort.Strings(hooks)
+
+  for _, i := range files {
+    file := a.config.Files[i]
+    key := strings.Trim(file.SecretKey, "/")
+
+    if _, ok := data[key]; !ok && readErrors[key] == nil {
+      secret := a.secrets[key]
+      err := secret.ReadSecret(a.client)
+      if err != nil {
+        readErrors[key] = err
+      } else {
+        data[key] = secret.SecretData
+      }
+    }
+
+    err := readErrors[key]
+    changed := false
+    if err == nil {
+      changed, err = a.writeFile(file, data[key])
+    }
+
+    fileStatus := &a.status.Files[i]
+    if err != nil {
+      logger.LogError(fmt.Sprintf("Error writing %s from %s: %s", file.Destination, key, err))
+      fileStatus.Error = err.Error()
+      a.failed[i] = true
+      continue
+    }
+
+    now := time.Now().UTC()
+    fileStatus.Error = ""
+    fileStatus.Version = a.watcher.Version(key)
+    fileStatus.WrittenTime = &now
+    delete(a.failed, i)
+
+    if changed && file.Hook != "" && !hooksSeen[file.Hook] {
+      hooksSeen[file.Hook] = true
+      hooks = append(hooks, file.Hook)
+    }
+  }
+
+  for _, hook := range hooks {
+    err := runHook(hook)
+    if err != nil {
+      a.failedHooks[hook] = true
+    } else {
+      delete(a.failedHooks, hook)
+    }
+
+    for i, file := range a.config.Files {
+      if file.Hook != hook || a.failed[i] {
+        continue
+      }
+      a.status.Files[i].Error = ""
+      if err != nil {
+        a.status.Files[i].Error = err.Error()
+      }
+    }
+  }
+  a.updateHealth()
+}
+
+/*
+This will write a file from the data of its secret
+*/
+func (a *agent) writeFile(file AgentFile, data map[string]interface{}) (bool, error) {
+  var content []byte
+  if file.Field != "" {
+    value, err := ExtractField(data, file.Field)
+    if err != nil {
+      return false, err
+    }
+    text, err := FormatFieldValue(value, file.Format)
+    if err != nil {
+      return false, err
+    }
+    content = []byte(text)
+  } else {
+    jsonBytes, err := json.MarshalIndent(data, "", "  ")
+    if err != nil {
+      return false, err
+    }
+    content = append(jsonBytes, '\n')
+  }
+
+  changed, err := writeIfChanged(file.Destination, content, file.mode, file.uid, file.gid)
+  if changed {
+    logger.LogInfo("Wrote secret file", "file", file.Destination)
+  }
+  return changed, err
+}
+
+/*
+This will set the agent health from the files, the agent
+is healthy when every file was written and its hook ran
+*/
+func (a *agent) updateHealth() {
+  var failed []string
+  for _, file := range a.status.Files {
+    if file.Error != "" {
+      failed = append(failed, file.Destination)
+    }
+  }
+  sort.Strings(failed)
+
+  a.status.Healthy = len(failed) == 0
+  a.status.Error = ""
+  if !a.status.Healthy {
+    a.status.Error = fmt.Sprintf("files failed: %s", strings.Join(failed, ", "))
+    return
+  }
+
+  now := time.Now().UTC()
+  a.status.LastSuccessTime = &now
+}
+
+/*
+This will write the status file, errors are logged since
+the agent keeps running without it
+*/
+func (a *agent) writeStatus() {
+  if a.config.StatusFile == "" {
+    return
+  }
+
+  jsonBytes, err := json.MarshalIndent(a.status, "", "  ")
+  if err == nil {
+    err = util.WriteFileAtomic(a.config.StatusFile, append(jsonBytes, '\n'), 0644)
+  }
+  if err != nil {
+    logger.LogError(fmt.Sprintf("Error writing status file %s: %s", a.config.StatusFile, err))
+  }
+}
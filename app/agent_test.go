@@ -0,0 +1,127 @@
+package app
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for the file agent
+*/
+func writeAgentConfig(t *testing.T, config string) string {
+  configFilePath := filepath.Join(t.TempDir(), "agent.json")
+  err := os.WriteFile(configFilePath, []byte(config), 0600)
+  assert.NoError(t, err)
+  return configFilePath
+}
+
+func TestLoadAgentConfig(t *testing.T) {
+  current, err := user.Current()
+  assert.NoError(t, err)
+
+  config, err := LoadAgentConfig(writeAgentConfig(t, `{
+    "pollInterval": "5s",
+    "statusFile": "/tmp/status.json",
+    "files": [
+      {"secretKey": "kv/app/db", "field": "password", "destination": "/tmp/db-password", "mode": "0640", "owner": "`+current.Username+`"},
+      {"secretKey": "kv/app/db", "destination": "/tmp/db.json", "hook": "true"}
+    ]
+  }`))
+  assert.NoError(t, err)
+  assert.Equal(t, 5*time.Second, config.pollInterval)
+  assert.Equal(t, os.FileMode(0640), config.Files[0].mode)
+  assert.NotEqual(t, -1, config.Files[0].uid)
+  assert.Equal(t, -1, config.Files[0].gid)
+  assert.Equal(t, os.FileMode(0600), config.Files[1].mode)
+  assert.Equal(t, -1, config.Files[1].uid)
+
+  config, err = LoadAgentConfig(writeAgentConfig(t, `{"files": [{"secretKey": "kv/a", "destination": "/tmp/a"}]}`))
+  assert.NoError(t, err)
+  assert.Equal(t, DefaultAgentPollInterval, config.pollInterval)
+
+  bad := []string{
+    `{"files": []}`,
+    `{"pollInterval": "soon", "files": [{"secretKey": "kv/a", "destination": "/tmp/a"}]}`,
+    `{"files": [{"secretKey": "kv/a"}]}`,
+    `{"files": [{"secretKey": "kv/a", "destination": "/tmp/a"}, {"secretKey": "kv/b", "destination": "/tmp/a"}]}`,
+    `{"files": [{"secretKey": "kv/a", "destination": "/tmp/a", "format": "base64"}]}`,
+    `{"files": [{"secretKey": "kv/a", "destination": "/tmp/a", "field": "f", "format": "hex"}]}`,
+    `{"files": [{"secretKey": "kv/a", "destination": "/tmp/a", "mode": "rw"}]}`,
+    `{"files": [{"secretKey": "kv/a", "destination": "/tmp/a", "owner": "no-such-user-here"}]}`,
+    `{"files": `,
+  }
+  for _, config := range bad {
+    _, err = LoadAgentConfig(writeAgentConfig(t, config))
+    assert.Error(t, err, config)
+  }
+}
+
+func TestAgentWriteFile(t *testing.T) {
+  dir := t.TempDir()
+  a := agent{}
+  data := map[string]interface{}{
+    "password": "hunter2",
+    "db": map[string]interface{}{"port": float64(5432)},
+  }
+
+  file := AgentFile{Field: "password", Format: FieldFormatBase64,
+    Destination: filepath.Join(dir, "password"), mode: 0600, uid: -1, gid: -1}
+  changed, err := a.writeFile(file, data)
+  assert.NoError(t, err)
+  assert.True(t, changed)
+  content, _ := os.ReadFile(file.Destination)
+  assert.Equal(t, "aHVudGVyMg==", string(content))
+
+  changed, err = a.writeFile(file, data)
+  assert.NoError(t, err)
+  assert.False(t, changed)
+
+  file = AgentFile{Destination: filepath.Join(dir, "all.json"), mode: 0640, uid: -1, gid: -1}
+  changed, err = a.writeFile(file, data)
+  assert.NoError(t, err)
+  assert.True(t, changed)
+  content, _ = os.ReadFile(file.Destination)
+  assert.JSONEq(t, `{"password": "hunter2", "db": {"port": 5432}}`, string(content))
+
+  file = AgentFile{Field: "missing", Destination: filepath.Join(dir, "missing"), mode: 0600, uid: -1, gid: -1}
+  _, err = a.writeFile(file, data)
+  assert.Error(t, err)
+  _, err = os.Stat(file.Destination)
+  assert.True(t, os.IsNotExist(err))
+}
+
+func TestAgentHealth(t *testing.T) {
+  statusFile := filepath.Join(t.TempDir(), "status.json")
+  a := agent{
+    config: AgentConfig{StatusFile: statusFile},
+    status: AgentStatus{
+      State: AgentStateRunning,
+      Files: []AgentFileStatus{
+        {Destination: "/tmp/b"},
+        {Destination: "/tmp/a", Error: "denied"},
+      },
+    },
+  }
+
+  a.updateHealth()
+  assert.False(t, a.status.Healthy)
+  assert.Equal(t, "files failed: /tmp/a", a.status.Error)
+  assert.Nil(t, a.status.LastSuccessTime)
+
+  a.status.Files[1].Error = ""
+  a.updateHealth()
+  assert.True(t, a.status.Healthy)
+  assert.Empty(t, a.status.Error)
+  assert.NotNil(t, a.status.LastSuccessTime)
+
+  a.writeStatus()
+  content, err := os.ReadFile(statusFile)
+  assert.NoError(t, err)
+  assert.Contains(t, string(content), `"healthy": true`)
+  assert.Contains(t, string(content), `"state": "running"`)
+}
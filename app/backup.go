@@ -0,0 +1,855 @@
+package app
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/dgutierrez1287/vault-util/util"
+	"github.com/hashicorp/vault-client-go/schema"
+)
+
+// version of the backup archive format
+const BackupFormatVersion = 1
+
+/*
+Files in a backup archive, the archive holds a header and an
+encrypted payload, the payload is a compressed tar of the
+manifest and one file per secret
+*/
+const (
+  backupHeaderFile = "header.json"
+  backupPayloadFile = "payload.enc"
+  backupManifestFile = "manifest.json"
+)
+
+/*
+BackupHeader - the part of a backup that is not
+encrypted, it has what is needed to decrypt the payload
+*/
+type BackupHeader struct {
+  FormatVersion int               `json:"formatVersion"`
+  CreatedAt time.Time             `json:"createdAt"`
+  Encryption *EncryptionMetadata  `json:"encryption"`
+  PayloadSha256 string            `json:"payloadSha256"`
+}
+
+/*
+BackupManifest - the mounts and secrets in a backup
+with a checksum for every secret file
+*/
+type BackupManifest struct {
+  FormatVersion int               `json:"formatVersion"`
+  CreatedAt time.Time             `json:"createdAt"`
+  Mounts []BackupMount            `json:"mounts"`
+  Secrets []BackupManifestEntry   `json:"secrets"`
+}
+
+/*
+BackupMount - a kv mount in a backup
+*/
+type BackupMount struct {
+  Mount string                    `json:"mount"`
+  KvVersion string                `json:"kvVersion"`
+  Description string              `json:"description,omitempty"`
+  SecretCount int                 `json:"secretCount"`
+}
+
+/*
+BackupManifestEntry - where a secret is in the
+payload and the checksum of its file
+*/
+type BackupManifestEntry struct {
+  Mount string                    `json:"mount"`
+  Path string                     `json:"path"`
+  File string                     `json:"file"`
+  Sha256 string                   `json:"sha256"`
+}
+
+/*
+BackupSecret - a secret in a backup, kv v1 secrets have
+data and kv v2 secrets have every readable version and
+their metadata
+*/
+type BackupSecret struct {
+  Mount string                    `json:"mount"`
+  Path string                     `json:"path"`
+  KvVersion string                `json:"kvVersion"`
+  Data map[string]interface{}     `json:"data,omitempty"`
+  Versions []BackupVersion        `json:"versions,omitempty"`
+  Metadata *BackupMetadata        `json:"metadata,omitempty"`
+}
+
+/*
+BackupVersion - a single kv v2 version of a secret
+*/
+type BackupVersion struct {
+  Version int64                   `json:"version"`
+  CreatedTime string              `json:"createdTime,omitempty"`
+  Data map[string]interface{}     `json:"data"`
+}
+
+/*
+BackupMetadata - the kv v2 metadata of a secret
+*/
+type BackupMetadata struct {
+  CasRequired bool                      `json:"casRequired,omitempty"`
+  CustomMetadata map[string]interface{} `json:"customMetadata,omitempty"`
+  DeleteVersionAfter string             `json:"deleteVersionAfter,omitempty"`
+  MaxVersions int64                     `json:"maxVersions,omitempty"`
+}
+
+/*
+Backup - the contents of a backup archive
+*/
+type Backup struct {
+  Manifest BackupManifest
+  Secrets []BackupSecret
+}
+
+/*
+RestoreOptions - options for restoring a backup
+*/
+type RestoreOptions struct {
+  MountMap map[string]string
+  CreateMounts bool
+}
+
+/*
+This will back up every kv mount, or only the mounts
+passed, with the data, versions and metadata of every secret.
+Secrets that cannot be read are returned as errors
+*/
+func CreateBackup(client *VaultClient, mountNames []string) (Backup,
+  []SecretActionError, error) {
+
+  var secretErrors []SecretActionError
+  backup := Backup{
+    Manifest: BackupManifest{
+      FormatVersion: BackupFormatVersion,
+      CreatedAt: time.Now().UTC(),
+    },
+  }
+
+  logger.LogDebug("Getting secret mounts to back up")
+  mounts, err := GetSecretMounts(client)
+  if err != nil {
+    return backup, nil, err
+  }
+
+  wanted := make(map[string]bool)
+  for _, name := range mountNames {
+    wanted[strings.Trim(name, "/")] = true
+  }
+
+  sort.Slice(mounts, func(i, j int) bool { return mounts[i].Mount < mounts[j].Mount })
+  for _, mount := range mounts {
+    name := strings.Trim(mount.Mount, "/")
+    if mount.Type != "kv" || (len(wanted) > 0 && !wanted[name]) {
+      continue
+    }
+    delete(wanted, name)
+
+    logger.LogInfo("Backing up mount", "mount", name)
+    secrets, errs, err := backupMount(client, mount)
+    if err != nil {
+      logger.LogError("Error backing up mount")
+      return backup, nil, fmt.Errorf("%s: %w", name, err)
+    }
+
+    backup.Manifest.Mounts = append(backup.Manifest.Mounts, BackupMount{
+      Mount: name,
+      KvVersion: mount.KvVersion,
+      Description: mount.Description,
+      SecretCount: len(secrets),
+    })
+    backup.Secrets = append(backup.Secrets, secrets...)
+    secretErrors = append(secretErrors, errs...)
+  }
+
+  if len(wanted) > 0 {
+    var missing []string
+    for name := range wanted {
+      missing = append(missing, name)
+    }
+    sort.Strings(missing)
+    logger.LogError("Error mounts to back up were not found")
+    return backup, nil, fmt.Errorf("kv mounts not found: %s", strings.Join(missing, ", "))
+  }
+  return backup, secretErrors, nil
+}
+
+/*
+This will back up every secret in a kv mount
+*/
+func backupMount(client *VaultClient, mount SecretMount) ([]BackupSecret,
+  []SecretActionError, error) {
+
+  var secretErrors []SecretActionError
+  name := strings.Trim(mount.Mount, "/")
+
+  keys, err := listSubtree(client, name)
+  if err != nil {
+    return nil, nil, err
+  }
+
+  paths := make([]string, 0, len(keys))
+  for path := range keys {
+    paths = append(paths, path)
+  }
+
+  var lock sync.Mutex
+  secrets := make(map[string]BackupSecret)
+  results := RunBulk(paths, client.Concurrency(), func(path string) error {
+    logger.LogDebug("Backing up secret", "key", keys[path])
+    secret, err := backupSecret(client, mount, path)
+    if err != nil {
+      return err
+    }
+
+    lock.Lock()
+    secrets[path] = secret
+    lock.Unlock()
+    return nil
+  })
+
+  var backupSecrets []BackupSecret
+  for _, result := range results {
+    if result.Err != nil {
+      logger.LogError("Error backing up secret")
+      secretErrors = append(secretErrors, SecretActionError{
+        VaultKey: keys[result.Key],
+        Error: result.Err,
+      })
+      continue
+    }
+    backupSecrets = append(backupSecrets, secrets[result.Key])
+  }
+  return backupSecrets, secretErrors, nil
+}
+
+/*
+This will read a single secret for a backup, for kv v2
+every version that was not deleted or destroyed is read
+*/
+func backupSecret(client *VaultClient, mount SecretMount, path string) (BackupSecret, error) {
+  name := strings.Trim(mount.Mount, "/")
+  backupSecret := BackupSecret{
+    Mount: name,
+    Path: path,
+    KvVersion: mount.KvVersion,
+  }
+
+  secret, err := NewSecret(joinKey(name, path), mount.Type, mount.KvVersion, nil, *client)
+  if err != nil {
+    return backupSecret, err
+  }
+  secret.MountName = mount.Mount
+  err = secret.getNormalizedSecretPath()
+  if err != nil {
+    return backupSecret, err
+  }
+
+  if mount.KvVersion != "2" {
+    data, exists, err := secret.ReadCurrentData(client)
+    if err != nil {
+      return backupSecret, err
+    }
+    if !exists {
+      return backupSecret, errors.New("secret does not exist")
+    }
+    backupSecret.Data = data
+    return backupSecret, nil
+  }
+
+  metadata, err := client.ReadKvMetadata(secret)
+  if err != nil {
+    return backupSecret, err
+  }
+
+  backupSecret.Metadata = &BackupMetadata{
+    CasRequired: metadata.CasRequired,
+    CustomMetadata: metadata.CustomMetadata,
+    DeleteVersionAfter: metadata.DeleteVersionAfter,
+    MaxVersions: metadata.MaxVersions,
+  }
+
+  oldest := metadata.OldestVersion
+  if oldest < 1 {
+    oldest = 1
+  }
+
+  for version := oldest; version <= metadata.CurrentVersion; version++ {
+    versionInfo, _ := metadata.Versions[strconv.FormatInt(version, 10)].(map[string]interface{})
+    if versionSkipped(versionInfo) {
+      logger.LogDebug("Skipping deleted or destroyed version", "version", version)
+      continue
+    }
+
+    data, err := client.ReadKvSecretVersion(secret, version)
+    if err != nil {
+      if IsNotFoundError(err) {
+        continue
+      }
+      return backupSecret, err
+    }
+
+    createdTime, _ := versionInfo["created_time"].(string)
+    backupSecret.Versions = append(backupSecret.Versions, BackupVersion{
+      Version: version,
+      CreatedTime: createdTime,
+      Data: data,
+    })
+  }
+
+  // a secret with nothing to restore would be dropped on restore
+  if len(backupSecret.Versions) == 0 {
+    logger.LogError("Error secret has no readable versions")
+    return backupSecret, errors.New("secret has no readable versions to back up")
+  }
+  return backupSecret, nil
+}
+
+/*
+This will get the latest data of a backed up secret
+*/
+func (s BackupSecret) LatestData() map[string]interface{} {
+  if len(s.Versions) > 0 {
+    return s.Versions[len(s.Versions)-1].Data
+  }
+  return s.Data
+}
+
+/*
+This will write a backup archive, the payload is compressed
+and then encrypted with a data key that is encrypted to the
+age recipients and/or transit key
+*/
+func WriteBackupFile(backupFilePath string, backup Backup,
+  opts EncryptionOptions, client *VaultClient) error {
+
+  logger.LogDebug("Building backup payload")
+  payload, err := backup.payload()
+  if err != nil {
+    logger.LogError("Error building the backup payload")
+    return err
+  }
+
+  metadata, dataKey, err := NewDataKey(opts, client)
+  if err != nil {
+    return err
+  }
+
+  gcm, err := newGCM(dataKey)
+  if err != nil {
+    return err
+  }
+  nonce := make([]byte, gcm.NonceSize())
+  _, err = rand.Read(nonce)
+  if err != nil {
+    return err
+  }
+  sealed := gcm.Seal(nonce, nonce, payload, []byte(backupPayloadFile))
+
+  header := BackupHeader{
+    FormatVersion: BackupFormatVersion,
+    CreatedAt: backup.Manifest.CreatedAt,
+    Encryption: metadata,
+    PayloadSha256: sha256Hex(sealed),
+  }
+  headerJson, err := json.MarshalIndent(header, "", "  ")
+  if err != nil {
+    return err
+  }
+
+  var archive bytes.Buffer
+  err = writeTar(&archive, []tarFile{
+    {name: backupHeaderFile, data: headerJson},
+    {name: backupPayloadFile, data: sealed},
+  })
+  if err != nil {
+    return err
+  }
+
+  err = util.WriteFileAtomic(backupFilePath, archive.Bytes(), 0600)
+  if err != nil {
+    logger.LogError("Error writing the backup file")
+    return err
+  }
+  return nil
+}
+
+/*
+This will build the compressed payload, the manifest
+gets the checksum of every secret file
+*/
+func (b Backup) payload() ([]byte, error) {
+  var files []tarFile
+  manifest := b.Manifest
+  manifest.Secrets = nil
+
+  for i, secret := range b.Secrets {
+    data, err := json.Marshal(secret)
+    if err != nil {
+      return nil, err
+    }
+
+    fileName := fmt.Sprintf("secrets/%06d.json", i)
+    manifest.Secrets = append(manifest.Secrets, BackupManifestEntry{
+      Mount: secret.Mount,
+      Path: secret.Path,
+      File: fileName,
+      Sha256: sha256Hex(data),
+    })
+    files = append(files, tarFile{name: fileName, data: data})
+  }
+
+  manifestJson, err := json.MarshalIndent(manifest, "", "  ")
+  if err != nil {
+    return nil, err
+  }
+  files = append([]tarFile{{name: backupManifestFile, data: manifestJson}}, files...)
+
+  var payload bytes.Buffer
+  gzipWriter := gzip.NewWriter(&payload)
+  err = writeTar(gzipWriter, files)
+  if err != nil {
+    return nil, err
+  }
+  err = gzipWriter.Close()
+  if err != nil {
+    return nil, err
+  }
+  return payload.Bytes(), nil
+}
+
+/*
+This will read a backup archive, the payload checksum is
+checked before it is decrypted and every secret file is
+checked against the manifest
+*/
+func ReadBackupFile(backupFilePath string, client *VaultClient) (Backup, error) {
+  var backup Backup
+
+  file, err := os.Open(backupFilePath)
+  if err != nil {
+    logger.LogError("Error opening backup file")
+    return backup, err
+  }
+  defer file.Close()
+
+  files, err := readTar(file)
+  if err != nil {
+    logger.LogError("Error reading backup archive")
+    return backup, err
+  }
+
+  var header BackupHeader
+  err = json.Unmarshal(files[backupHeaderFile], &header)
+  if err != nil {
+    logger.LogError("Error reading backup header")
+    return backup, fmt.Errorf("invalid backup header: %w", err)
+  }
+  if header.FormatVersion != BackupFormatVersion {
+    logger.LogError("Error unsupported backup format version")
+    return backup, fmt.Errorf("unsupported backup format version %d", header.FormatVersion)
+  }
+  if header.Encryption == nil {
+    return backup, errors.New("backup has no encryption metadata")
+  }
+
+  sealed := files[backupPayloadFile]
+  if sha256Hex(sealed) != header.PayloadSha256 {
+    logger.LogError("Error backup payload checksum does not match")
+    return backup, errors.New("backup payload checksum does not match, the file is damaged")
+  }
+
+  dataKey, err := header.Encryption.DataKey(client)
+  if err != nil {
+    logger.LogError("Error decrypting the backup data key")
+    return backup, err
+  }
+
+  gcm, err := newGCM(dataKey)
+  if err != nil {
+    return backup, err
+  }
+  if len(sealed) < gcm.NonceSize() {
+    return backup, errors.New("backup payload is too short")
+  }
+  payload, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():],
+    []byte(backupPayloadFile))
+  if err != nil {
+    logger.LogError("Error decrypting the backup payload")
+    return backup, errors.New("cannot decrypt backup payload")
+  }
+
+  gzipReader, err := gzip.NewReader(bytes.NewReader(payload))
+  if err != nil {
+    return backup, err
+  }
+  payloadFiles, err := readTar(gzipReader)
+  if err != nil {
+    logger.LogError("Error reading backup payload")
+    return backup, err
+  }
+
+  err = json.Unmarshal(payloadFiles[backupManifestFile], &backup.Manifest)
+  if err != nil {
+    logger.LogError("Error reading backup manifest")
+    return backup, fmt.Errorf("invalid backup manifest: %w", err)
+  }
+
+  for _, entry := range backup.Manifest.Secrets {
+    data, ok := payloadFiles[entry.File]
+    if !ok {
+      return backup, fmt.Errorf("backup is missing %s for %s/%s", entry.File, entry.Mount, entry.Path)
+    }
+    if sha256Hex(data) != entry.Sha256 {
+      logger.LogError("Error backup secret checksum does not match")
+      return backup, fmt.Errorf("checksum does not match for %s/%s", entry.Mount, entry.Path)
+    }
+
+    var secret BackupSecret
+    decoder := json.NewDecoder(bytes.NewReader(data))
+    decoder.UseNumber()
+    err = decoder.Decode(&secret)
+    if err != nil {
+      return backup, fmt.Errorf("invalid secret %s/%s: %w", entry.Mount, entry.Path, err)
+    }
+    backup.Secrets = append(backup.Secrets, secret)
+  }
+  return backup, nil
+}
+
+/*
+This will parse mount mappings in the form old=new
+*/
+func ParseMountMap(mappings []string) (map[string]string, error) {
+  mountMap := make(map[string]string)
+  for _, mapping := range mappings {
+    parts := strings.SplitN(mapping, "=", 2)
+    if len(parts) != 2 || strings.Trim(parts[0], "/") == "" || strings.Trim(parts[1], "/") == "" {
+      return nil, fmt.Errorf("mount mapping %s must be in the form old=new", mapping)
+    }
+    mountMap[strings.Trim(parts[0], "/")] = strings.Trim(parts[1], "/")
+  }
+  return mountMap, nil
+}
+
+/*
+This will get the mount a backed up mount is restored to
+*/
+func (o RestoreOptions) targetMount(mount string) string {
+  if target, ok := o.MountMap[mount]; ok {
+    return target
+  }
+  return mount
+}
+
+/*
+This will build a plan for restoring a backup, the latest data
+of every secret is compared to what is in vault. Mounts that do
+not exist are an error unless they will be created
+*/
+func (b Backup) BuildRestorePlan(client *VaultClient, opts RestoreOptions) (SecretPlan, error) {
+  plan := SecretPlan{
+    FormatVersion: SecretPlanFormatVersion,
+    CreatedAt: time.Now().UTC(),
+  }
+
+  targetMounts, err := b.targetMounts(client, opts)
+  if err != nil {
+    return plan, err
+  }
+
+  var lock sync.Mutex
+  entries := make(map[string]SecretPlanEntry)
+  secrets := b.secretsByTarget(opts)
+  names := make([]string, 0, len(secrets))
+  for name, secret := range secrets {
+    if secret.LatestData() == nil {
+      logger.LogDebug("Secret has no readable versions, skipping", "key", name)
+      continue
+    }
+    names = append(names, name)
+  }
+
+  results := RunBulk(names, client.Concurrency(), func(name string) error {
+    backupSecret := secrets[name]
+    mount := targetMounts[opts.targetMount(backupSecret.Mount)]
+
+    secret := VaultSecret{
+      VaultKey: name,
+      SecretType: "kv",
+      KvVersion: mount.KvVersion,
+      MountName: mount.Mount,
+      SecretData: backupSecret.LatestData(),
+    }
+    err := secret.getNormalizedSecretPath()
+    if err != nil {
+      return err
+    }
+
+    var current map[string]interface{}
+    exists := false
+    if mount.Type != "" {
+      current, exists, err = secret.ReadCurrentData(client)
+      if err != nil {
+        return fmt.Errorf("%s: %w", name, err)
+      }
+    }
+
+    lock.Lock()
+    entries[name] = planEntry(name, secret, current, exists)
+    lock.Unlock()
+    return nil
+  })
+
+  for _, result := range results {
+    if result.Err != nil {
+      return plan, result.Err
+    }
+    plan.Entries = append(plan.Entries, entries[result.Key])
+  }
+  return plan, nil
+}
+
+/*
+This will restore a backup by applying a restore plan, missing
+mounts are created first. Unchanged secrets are skipped, kv v2
+secrets that are created get every backed up version and their
+metadata and secrets that are updated get the latest data
+*/
+func (b Backup) Restore(client *VaultClient, plan SecretPlan,
+  opts RestoreOptions) PlanApplyResult {
+
+  var result PlanApplyResult
+
+  err := b.createMounts(client, opts)
+  if err != nil {
+    result.Errors = append(result.Errors, SecretActionError{VaultKey: "", Error: err})
+    return result
+  }
+
+  secrets := b.secretsByTarget(opts)
+  entries := make(map[string]SecretPlanEntry)
+  var names []string
+  for _, entry := range plan.Entries {
+    if entry.Action == PlanActionUnchanged {
+      result.Unchanged = append(result.Unchanged, entry.Name)
+      continue
+    }
+    entries[entry.Name] = entry
+    names = append(names, entry.Name)
+  }
+
+  results := RunBulk(names, client.Concurrency(), func(name string) error {
+    logger.LogDebug("Restoring secret", "key", name)
+    return restoreSecret(client, entries[name], secrets[name])
+  })
+
+  succeeded, failed := SplitBulkResults(results)
+  result.Applied = succeeded
+  for _, failure := range failed {
+    logger.LogError("Error restoring secret")
+    result.Errors = append(result.Errors, SecretActionError{
+      VaultKey: failure.Key,
+      Error: failure.Err,
+    })
+  }
+  return result
+}
+
+/*
+This will write a single backed up secret, all versions are
+only written when the secret is created on a kv v2 mount. A
+secret that exists only gets the latest data so its own
+history is not pushed out
+*/
+func restoreSecret(client *VaultClient, entry SecretPlanEntry, backupSecret BackupSecret) error {
+  secret := entry.Secret
+  if secret.KvVersion != "2" || len(backupSecret.Versions) == 0 ||
+    entry.Action != PlanActionCreate {
+    secret.SecretData = backupSecret.LatestData()
+    return secret.WriteSecret(client)
+  }
+
+  for _, version := range backupSecret.Versions {
+    secret.SecretData = version.Data
+    _, err := client.WriteKvSecretVersion(secret)
+    if err != nil {
+      return err
+    }
+  }
+
+  if backupSecret.Metadata != nil {
+    err := client.WriteKvMetadata(secret, schema.KvV2WriteMetadataRequest{
+      CasRequired: backupSecret.Metadata.CasRequired,
+      CustomMetadata: backupSecret.Metadata.CustomMetadata,
+      DeleteVersionAfter: backupSecret.Metadata.DeleteVersionAfter,
+      MaxVersions: int32(backupSecret.Metadata.MaxVersions),
+    })
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+/*
+This will get the target mounts for the backup, mounts that
+will be created have an empty type
+*/
+func (b Backup) targetMounts(client *VaultClient, opts RestoreOptions) (map[string]SecretMount,
+  error) {
+
+  existing, err := GetSecretMounts(client)
+  if err != nil {
+    return nil, err
+  }
+
+  existingByName := make(map[string]SecretMount)
+  for _, mount := range existing {
+    existingByName[strings.Trim(mount.Mount, "/")] = mount
+  }
+
+  targets := make(map[string]SecretMount)
+  for _, backupMount := range b.Manifest.Mounts {
+    target := opts.targetMount(backupMount.Mount)
+    mount, ok := existingByName[target]
+
+    switch {
+    case ok && mount.Type != "kv":
+      logger.LogError("Error restore target mount is not kv")
+      return nil, fmt.Errorf("target mount %s is a %s mount", target, mount.Type)
+    case ok:
+      targets[target] = mount
+    case opts.CreateMounts:
+      logger.LogDebug("Target mount will be created", "mount", target)
+      targets[target] = SecretMount{Mount: target + "/", KvVersion: backupMount.KvVersion}
+    default:
+      logger.LogError("Error restore target mount does not exist")
+      return nil, fmt.Errorf("target mount %s does not exist, use --create-mounts to create it", target)
+    }
+  }
+  return targets, nil
+}
+
+/*
+This will create the target mounts that do not exist
+*/
+func (b Backup) createMounts(client *VaultClient, opts RestoreOptions) error {
+  if !opts.CreateMounts {
+    return nil
+  }
+
+  targets, err := b.targetMounts(client, opts)
+  if err != nil {
+    return err
+  }
+
+  for _, backupMount := range b.Manifest.Mounts {
+    target := opts.targetMount(backupMount.Mount)
+    if targets[target].Type != "" {
+      continue
+    }
+
+    logger.LogInfo("Creating kv mount", "mount", target)
+    err = client.EnableKvMount(target, backupMount.KvVersion, backupMount.Description)
+    if err != nil {
+      logger.LogError("Error creating kv mount")
+      return fmt.Errorf("cannot create mount %s: %w", target, err)
+    }
+  }
+  return nil
+}
+
+/*
+This will index the backed up secrets by the key they
+are restored to
+*/
+func (b Backup) secretsByTarget(opts RestoreOptions) map[string]BackupSecret {
+  secrets := make(map[string]BackupSecret, len(b.Secrets))
+  for _, secret := range b.Secrets {
+    secrets[joinKey(opts.targetMount(secret.Mount), secret.Path)] = secret
+  }
+  return secrets
+}
+
+/*
+tarFile - a file to write to a tar archive
+*/
+type tarFile struct {
+  name string
+  data []byte
+}
+
+/*
+This will write files to a tar archive
+*/
+func writeTar(writer io.Writer, files []tarFile) error {
+  tarWriter := tar.NewWriter(writer)
+  for _, file := range files {
+    err := tarWriter.WriteHeader(&tar.Header{
+      Name: file.name,
+      Mode: 0600,
+      Size: int64(len(file.data)),
+      ModTime: time.Now().UTC(),
+    })
+    if err != nil {
+      return err
+    }
+    _, err = tarWriter.Write(file.data)
+    if err != nil {
+      return err
+    }
+  }
+  return tarWriter.Close()
+}
+
+/*
+This will read every file in a tar archive
+*/
+func readTar(reader io.Reader) (map[string][]byte, error) {
+  files := make(map[string][]byte)
+  tarReader := tar.NewReader(reader)
+
+  for {
+    header, err := tarReader.Next()
+    if err == io.EOF {
+      return files, nil
+    }
+    if err != nil {
+      return nil, err
+    }
+
+    data, err := io.ReadAll(tarReader)
+    if err != nil {
+      return nil, err
+    }
+    files[header.Name] = data
+  }
+}
+
+/*
+Returns the hex sha256 of data
+*/
+func sha256Hex(data []byte) string {
+  hash := sha256.Sum256(data)
+  return hex.EncodeToString(hash[:])
+}
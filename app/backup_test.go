@@ -0,0 +1,204 @@
+package app
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"github.com/dgutierrez1287/vault-util/util"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for backup archives
+*/
+func TestBackupFileRoundTrip(t *testing.T) {
+  identityFile := filepath.Join(util.MockHomeDir, "age-keys.txt")
+  backupFile := filepath.Join(util.MockHomeDir, "backup.vub")
+
+  err := util.MockHomeSetup()
+  assert.NoError(t, err)
+
+  identity, err := age.GenerateX25519Identity()
+  assert.NoError(t, err)
+  err = os.WriteFile(identityFile, []byte(identity.String()+"\n"), 0600)
+  assert.NoError(t, err)
+
+  previousIdentity := AgeIdentityFile
+  AgeIdentityFile = identityFile
+  defer func() {
+    AgeIdentityFile = previousIdentity
+  }()
+
+  backup := Backup{
+    Manifest: BackupManifest{
+      FormatVersion: BackupFormatVersion,
+      Mounts: []BackupMount{
+        {Mount: "kv1", KvVersion: "1", SecretCount: 1},
+        {Mount: "secret", KvVersion: "2", SecretCount: 1},
+      },
+    },
+    Secrets: []BackupSecret{
+      {Mount: "kv1", Path: "app", KvVersion: "1", Data: map[string]interface{}{"a": "1"}},
+      {Mount: "secret", Path: "team/db", KvVersion: "2",
+        Versions: []BackupVersion{
+          {Version: 1, Data: map[string]interface{}{"password": "old"}},
+          {Version: 3, Data: map[string]interface{}{"password": "new"}},
+        },
+        Metadata: &BackupMetadata{MaxVersions: 5},
+      },
+    },
+  }
+
+  err = WriteBackupFile(backupFile, backup, EncryptionOptions{
+    AgeRecipients: []string{identity.Recipient().String()},
+  }, nil)
+  assert.NoError(t, err)
+
+  raw, err := os.ReadFile(backupFile)
+  assert.NoError(t, err)
+  assert.NotContains(t, string(raw), "team/db")
+
+  read, err := ReadBackupFile(backupFile, nil)
+  assert.NoError(t, err)
+  assert.Len(t, read.Secrets, 2)
+  assert.Len(t, read.Manifest.Secrets, 2)
+  assert.Equal(t, "team/db", read.Secrets[1].Path)
+  assert.Equal(t, "new", read.Secrets[1].LatestData()["password"])
+  assert.Equal(t, "1", read.Secrets[0].LatestData()["a"])
+
+  err = util.MockHomeCleanup()
+  assert.NoError(t, err)
+}
+
+func TestBackupFileDamaged(t *testing.T) {
+  identityFile := filepath.Join(util.MockHomeDir, "age-keys.txt")
+  backupFile := filepath.Join(util.MockHomeDir, "backup.vub")
+
+  err := util.MockHomeSetup()
+  assert.NoError(t, err)
+
+  identity, err := age.GenerateX25519Identity()
+  assert.NoError(t, err)
+  err = os.WriteFile(identityFile, []byte(identity.String()+"\n"), 0600)
+  assert.NoError(t, err)
+
+  previousIdentity := AgeIdentityFile
+  AgeIdentityFile = identityFile
+  defer func() {
+    AgeIdentityFile = previousIdentity
+  }()
+
+  backup := Backup{Manifest: BackupManifest{FormatVersion: BackupFormatVersion}}
+  err = WriteBackupFile(backupFile, backup, EncryptionOptions{
+    AgeRecipients: []string{identity.Recipient().String()},
+  }, nil)
+  assert.NoError(t, err)
+
+  raw, err := os.ReadFile(backupFile)
+  assert.NoError(t, err)
+  files, err := readTar(bytes.NewReader(raw))
+  assert.NoError(t, err)
+
+  payload := files[backupPayloadFile]
+  payload[len(payload)-1] ^= 0xff
+  var damaged []tarFile
+  damaged = append(damaged, tarFile{name: backupHeaderFile, data: files[backupHeaderFile]})
+  damaged = append(damaged, tarFile{name: backupPayloadFile, data: payload})
+  file, err := os.Create(backupFile)
+  assert.NoError(t, err)
+  err = writeTar(file, damaged)
+  assert.NoError(t, err)
+  file.Close()
+
+  _, err = ReadBackupFile(backupFile, nil)
+  assert.ErrorContains(t, err, "checksum")
+
+  err = util.MockHomeCleanup()
+  assert.NoError(t, err)
+}
+
+func TestParseMountMap(t *testing.T) {
+  mountMap, err := ParseMountMap([]string{"secret/=restored", "kv1=kv-old"})
+  assert.NoError(t, err)
+  assert.Equal(t, map[string]string{"secret": "restored", "kv1": "kv-old"}, mountMap)
+
+  _, err = ParseMountMap([]string{"secret"})
+  assert.Error(t, err)
+  _, err = ParseMountMap([]string{"secret="})
+  assert.Error(t, err)
+}
+
+func TestBackupSecretsByTarget(t *testing.T) {
+  backup := Backup{
+    Secrets: []BackupSecret{
+      {Mount: "secret", Path: "app/config"},
+      {Mount: "kv1", Path: "db"},
+    },
+  }
+
+  secrets := backup.secretsByTarget(RestoreOptions{
+    MountMap: map[string]string{"secret": "restored"},
+  })
+  assert.Contains(t, secrets, "restored/app/config")
+  assert.Contains(t, secrets, "kv1/db")
+}
+
+func TestCreateBackupVersions(t *testing.T) {
+  fake, client := newFakeVault(t, map[string]string{"secret/": "2"})
+
+  // delete_version_after gives live versions a future deletion time
+  fake.put("secret/app/live", map[string]interface{}{"key": "1"})
+  fake.put("secret/app/live", map[string]interface{}{"key": "2"})
+  fake.setDeletionTime("secret/app/live", 1, time.Now().Add(time.Hour))
+  fake.setDeletionTime("secret/app/live", 2, time.Now().Add(time.Hour))
+
+  fake.put("secret/app/gone", map[string]interface{}{"key": "1"})
+  fake.softDelete("secret/app/gone")
+
+  backup, secretErrors, err := CreateBackup(client, nil)
+  assert.NoError(t, err)
+
+  assert.Len(t, backup.Secrets, 1)
+  assert.Equal(t, "app/live", backup.Secrets[0].Path)
+  assert.Len(t, backup.Secrets[0].Versions, 2)
+  assert.Equal(t, map[string]interface{}{"key": "2"}, backup.Secrets[0].LatestData())
+
+  assert.Len(t, secretErrors, 1)
+  assert.Equal(t, "secret/app/gone", secretErrors[0].VaultKey)
+}
+
+func TestRestoreVersions(t *testing.T) {
+  source, sourceClient := newFakeVault(t, map[string]string{"secret/": "2"})
+  dest, destClient := newFakeVault(t, map[string]string{"secret/": "2"})
+
+  for _, key := range []string{"secret/app/existing", "secret/app/new"} {
+    source.put(key, map[string]interface{}{"key": "1"})
+    source.put(key, map[string]interface{}{"key": "2"})
+  }
+  dest.put("secret/app/existing", map[string]interface{}{"key": "mine"})
+
+  backup, secretErrors, err := CreateBackup(sourceClient, nil)
+  assert.NoError(t, err)
+  assert.Empty(t, secretErrors)
+
+  plan, err := backup.BuildRestorePlan(destClient, RestoreOptions{})
+  assert.NoError(t, err)
+  assert.Equal(t, map[string]string{
+    "secret/app/existing": PlanActionUpdate,
+    "secret/app/new": PlanActionCreate,
+  }, planActions(plan))
+
+  result := backup.Restore(destClient, plan, RestoreOptions{})
+  assert.Empty(t, result.Errors)
+
+  // an existing secret only gets the latest data on top of its own history
+  assert.Equal(t, 2, dest.versionCount("secret/app/existing"))
+  assert.Equal(t, map[string]interface{}{"key": "2"}, dest.current("secret/app/existing"))
+
+  assert.Equal(t, 2, dest.versionCount("secret/app/new"))
+  assert.Equal(t, map[string]interface{}{"key": "2"}, dest.current("secret/app/new"))
+}
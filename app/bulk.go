@@ -0,0 +1,78 @@
+package app
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/dgutierrez1287/vault-util/logger"
+)
+
+/*
+BulkResult - the result of running a bulk
+task for a single key
+*/
+type BulkResult struct {
+  Key string
+  Err error
+}
+
+/*
+This will run a task for every key using a pool of workers,
+the results are always returned sorted by key so output and
+errors are the same no matter what order the workers finish in
+*/
+func RunBulk(keys []string, concurrency int,
+  task func(key string) error) []BulkResult {
+
+  if concurrency < 1 {
+    concurrency = 1
+  }
+
+  sortedKeys := make([]string, len(keys))
+  copy(sortedKeys, keys)
+  sort.Strings(sortedKeys)
+
+  results := make([]BulkResult, len(sortedKeys))
+  jobs := make(chan int)
+  var wg sync.WaitGroup
+
+  logger.LogDebug("Starting bulk workers", "workers", concurrency, "tasks", len(sortedKeys))
+  for w := 0; w < concurrency && w < len(sortedKeys); w++ {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      for i := range jobs {
+        results[i] = BulkResult{
+          Key: sortedKeys[i],
+          Err: task(sortedKeys[i]),
+        }
+      }
+    }()
+  }
+
+  for i := range sortedKeys {
+    jobs <- i
+  }
+  close(jobs)
+  wg.Wait()
+
+  return results
+}
+
+/*
+This will split bulk results into the keys that succeeded
+and the keys that failed, both keep the sorted key order
+*/
+func SplitBulkResults(results []BulkResult) ([]string, []BulkResult) {
+  var succeeded []string
+  var failed []BulkResult
+
+  for _, result := range results {
+    if result.Err != nil {
+      failed = append(failed, result)
+    } else {
+      succeeded = append(succeeded, result.Key)
+    }
+  }
+  return succeeded, failed
+}
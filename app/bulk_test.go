@@ -0,0 +1,51 @@
+package app
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for RunBulk
+*/
+func TestRunBulkSortedResults(t *testing.T) {
+  keys := []string{"c", "a", "d", "b"}
+
+  results := RunBulk(keys, 3, func(key string) error {
+    if key == "b" || key == "d" {
+      return errors.New("failed " + key)
+    }
+    return nil
+  })
+
+  assert.Len(t, results, 4)
+  assert.Equal(t, "a", results[0].Key)
+  assert.Equal(t, "d", results[3].Key)
+
+  succeeded, failed := SplitBulkResults(results)
+  assert.Equal(t, []string{"a", "c"}, succeeded)
+  assert.Len(t, failed, 2)
+  assert.Equal(t, "b", failed[0].Key)
+  assert.EqualError(t, failed[1].Err, "failed d")
+}
+
+func TestRunBulkRunsEveryKeyOnce(t *testing.T) {
+  var count int64
+  keys := []string{"a", "b", "c", "d", "e", "f"}
+
+  RunBulk(keys, 0, func(key string) error {
+    atomic.AddInt64(&count, 1)
+    return nil
+  })
+  assert.Equal(t, int64(len(keys)), count)
+}
+
+func TestRunBulkEmpty(t *testing.T) {
+  results := RunBulk(nil, 4, func(key string) error {
+    return nil
+  })
+  assert.Empty(t, results)
+}
@@ -0,0 +1,262 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/dgutierrez1287/vault-util/util"
+)
+
+// version of the checkpoint file format
+const CheckpointFormatVersion = 1
+
+// how often completed keys are saved to the checkpoint file
+const checkpointSaveInterval = time.Second
+
+/*
+Operations a checkpoint can be written for
+*/
+const (
+  CheckpointOpBulkLoad = "bulk-load"
+  CheckpointOpExport = "export"
+  CheckpointOpCopy = "copy"
+)
+
+/*
+Checkpoint - the keys a bulk operation has finished
+so a run that stopped can be resumed, this never
+contains secret values
+*/
+type Checkpoint struct {
+  FormatVersion int               `json:"formatVersion"`
+  Operation string                `json:"operation"`
+  CreatedAt time.Time             `json:"createdAt"`
+  UpdatedAt time.Time             `json:"updatedAt"`
+  InputHash string                `json:"inputHash,omitempty"`
+  Completed []string              `json:"completed"`
+
+  checkpointPath string
+  completed map[string]bool
+  lastSave time.Time
+  lock sync.Mutex
+}
+
+/*
+This will create a checkpoint for an operation and write it,
+the input hash is used to make sure a resume uses the same input
+*/
+func NewCheckpoint(checkpointPath string, operation string,
+  inputHash string) (*Checkpoint, error) {
+
+  checkpoint := &Checkpoint{
+    FormatVersion: CheckpointFormatVersion,
+    Operation: operation,
+    CreatedAt: time.Now().UTC(),
+    InputHash: inputHash,
+    Completed: []string{},
+    checkpointPath: checkpointPath,
+    completed: make(map[string]bool),
+  }
+
+  err := checkpoint.Save()
+  if err != nil {
+    return nil, err
+  }
+  return checkpoint, nil
+}
+
+/*
+Reads a checkpoint from a file so an operation
+can be resumed
+*/
+func ReadCheckpoint(checkpointPath string) (*Checkpoint, error) {
+  checkpoint := &Checkpoint{}
+
+  file, err := os.Open(checkpointPath)
+  if err != nil {
+    logger.LogError("Error opening checkpoint file")
+    return nil, err
+  }
+  defer file.Close()
+
+  bytes, err := io.ReadAll(file)
+  if err != nil {
+    logger.LogError("Error reading checkpoint file")
+    return nil, err
+  }
+
+  err = json.Unmarshal(bytes, checkpoint)
+  if err != nil {
+    logger.LogError("Error unmarshaling json to checkpoint struct")
+    return nil, err
+  }
+
+  if checkpoint.FormatVersion != CheckpointFormatVersion {
+    logger.LogError("Error unsupported checkpoint format version")
+    return nil, fmt.Errorf("unsupported checkpoint format version %d", checkpoint.FormatVersion)
+  }
+
+  checkpoint.checkpointPath = checkpointPath
+  checkpoint.completed = make(map[string]bool, len(checkpoint.Completed))
+  for _, key := range checkpoint.Completed {
+    checkpoint.completed[key] = true
+  }
+  return checkpoint, nil
+}
+
+/*
+This will check that a checkpoint is for the operation being
+resumed and that the input has not changed since it was written
+*/
+func (c *Checkpoint) Verify(operation string, inputHash string) error {
+  if c.Operation != operation {
+    logger.LogError("Error checkpoint is for a different operation")
+    return fmt.Errorf("checkpoint is for %s not %s", c.Operation, operation)
+  }
+
+  if c.InputHash != inputHash {
+    logger.LogError("Error input has changed since the checkpoint was written")
+    return errors.New("input has changed since the checkpoint was written, start a new run")
+  }
+  return nil
+}
+
+/*
+Checks if a key was finished in an earlier run
+*/
+func (c *Checkpoint) IsDone(key string) bool {
+  c.lock.Lock()
+  defer c.lock.Unlock()
+  return c.completed[key]
+}
+
+/*
+Returns the keys that have been finished, sorted
+*/
+func (c *Checkpoint) CompletedKeys() []string {
+  c.lock.Lock()
+  defer c.lock.Unlock()
+
+  keys := make([]string, len(c.Completed))
+  copy(keys, c.Completed)
+  sort.Strings(keys)
+  return keys
+}
+
+/*
+This will record keys as finished, the checkpoint is saved at
+most once a second so large runs are not slowed down by it
+*/
+func (c *Checkpoint) MarkDone(keys ...string) error {
+  c.lock.Lock()
+  for _, key := range keys {
+    if !c.completed[key] {
+      c.completed[key] = true
+      c.Completed = append(c.Completed, key)
+    }
+  }
+  due := time.Since(c.lastSave) >= checkpointSaveInterval
+  c.lock.Unlock()
+
+  if due {
+    return c.Save()
+  }
+  return nil
+}
+
+/*
+This will set the input hash, used when the input is only
+known after the operation has run
+*/
+func (c *Checkpoint) SetInputHash(inputHash string) {
+  c.lock.Lock()
+  c.InputHash = inputHash
+  c.lock.Unlock()
+}
+
+/*
+Returns the path of the checkpoint file
+*/
+func (c *Checkpoint) Path() string {
+  return c.checkpointPath
+}
+
+/*
+This will write the checkpoint to its file
+*/
+func (c *Checkpoint) Save() error {
+  c.lock.Lock()
+  defer c.lock.Unlock()
+
+  c.UpdatedAt = time.Now().UTC()
+  sort.Strings(c.Completed)
+
+  jsonData, err := json.MarshalIndent(c, "", "  ")
+  if err != nil {
+    logger.LogError("Error marshaling checkpoint to json")
+    return err
+  }
+
+  err = util.WriteFileAtomic(c.checkpointPath, jsonData, 0600)
+  if err != nil {
+    logger.LogError("Error writing the checkpoint file")
+    return err
+  }
+  c.lastSave = time.Now()
+  return nil
+}
+
+/*
+This will remove the checkpoint file once an
+operation has finished without errors
+*/
+func (c *Checkpoint) Remove() error {
+  c.lock.Lock()
+  defer c.lock.Unlock()
+
+  err := os.Remove(c.checkpointPath)
+  if err != nil && !os.IsNotExist(err) {
+    logger.LogError("Error removing the checkpoint file")
+    return err
+  }
+  return nil
+}
+
+/*
+This will hash the contents of an input file
+*/
+func HashFile(filePath string) (string, error) {
+  file, err := os.Open(filePath)
+  if err != nil {
+    logger.LogError("Error opening file to hash")
+    return "", err
+  }
+  defer file.Close()
+
+  hash := sha256.New()
+  _, err = io.Copy(hash, file)
+  if err != nil {
+    logger.LogError("Error reading file to hash")
+    return "", err
+  }
+  return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+/*
+This will hash the inputs of an operation that does not
+read from a file, like the paths and filters of a copy
+*/
+func HashInputs(inputs ...string) string {
+  hash := sha256.Sum256([]byte(strings.Join(inputs, "\x00")))
+  return hex.EncodeToString(hash[:])
+}
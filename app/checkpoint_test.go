@@ -0,0 +1,72 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgutierrez1287/vault-util/util"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for checkpoints
+*/
+func TestCheckpointRoundTrip(t *testing.T) {
+  checkpointFile := filepath.Join(util.MockHomeDir, "checkpoint.json")
+
+  err := util.MockHomeSetup()
+  assert.NoError(t, err)
+
+  checkpoint, err := NewCheckpoint(checkpointFile, CheckpointOpBulkLoad, "abc")
+  assert.NoError(t, err)
+
+  err = checkpoint.MarkDone("b", "a")
+  assert.NoError(t, err)
+  err = checkpoint.MarkDone("a")
+  assert.NoError(t, err)
+  err = checkpoint.Save()
+  assert.NoError(t, err)
+
+  read, err := ReadCheckpoint(checkpointFile)
+  assert.NoError(t, err)
+  assert.Equal(t, []string{"a", "b"}, read.CompletedKeys())
+  assert.True(t, read.IsDone("a"))
+  assert.False(t, read.IsDone("c"))
+
+  assert.NoError(t, read.Verify(CheckpointOpBulkLoad, "abc"))
+  assert.Error(t, read.Verify(CheckpointOpBulkLoad, "def"))
+  assert.Error(t, read.Verify(CheckpointOpCopy, "abc"))
+
+  err = read.Remove()
+  assert.NoError(t, err)
+  _, err = os.Stat(checkpointFile)
+  assert.True(t, os.IsNotExist(err))
+
+  err = util.MockHomeCleanup()
+  assert.NoError(t, err)
+}
+
+func TestHashFile(t *testing.T) {
+  inputFile := filepath.Join(util.MockHomeDir, "input.json")
+
+  err := util.MockHomeSetup()
+  assert.NoError(t, err)
+
+  err = os.WriteFile(inputFile, []byte(`{"secrets": {}}`), 0600)
+  assert.NoError(t, err)
+  first, err := HashFile(inputFile)
+  assert.NoError(t, err)
+
+  err = os.WriteFile(inputFile, []byte(`{"secrets": {"a": {}}}`), 0600)
+  assert.NoError(t, err)
+  second, err := HashFile(inputFile)
+  assert.NoError(t, err)
+  assert.NotEqual(t, first, second)
+
+  assert.Equal(t, HashInputs("a", "b"), HashInputs("a", "b"))
+  assert.NotEqual(t, HashInputs("a", "b"), HashInputs("ab", ""))
+
+  err = util.MockHomeCleanup()
+  assert.NoError(t, err)
+}
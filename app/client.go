@@ -2,10 +2,18 @@ package app
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/dgutierrez1287/vault-util/logger"
 	vaultGo "github.com/hashicorp/vault-client-go"
 	"github.com/hashicorp/vault-client-go/schema"
+	"golang.org/x/time/rate"
 )
 
 /*
@@ -24,9 +32,11 @@ type VaultClientInterface interface {
 Vault client
 */
 type VaultClient struct {
-  secrets   *vaultGo.Secrets
-  system    *vaultGo.System
-  ctx       *context.Context
+  secrets       *vaultGo.Secrets
+  system        *vaultGo.System
+  ctx           *context.Context
+  limiter       *rate.Limiter
+  concurrency   int
 }
 
 /*
@@ -66,13 +76,61 @@ func NewClient(v VaultInstance, ctx *context.Context) (*VaultClient, error) {
     secrets: &client.Secrets,
     system: &client.System,
     ctx: ctx,
+    concurrency: 1,
   }, nil
 }
 
+/*
+This will set how many requests can be in flight at once
+for bulk operations and the max requests per second that
+the client will send, a rps of 0 is unlimited
+*/
+func (c *VaultClient) SetThrottling(concurrency int, rps float64) {
+  if concurrency < 1 {
+    concurrency = 1
+  }
+  logger.LogDebug("Setting client throttling", "concurrency", concurrency, "rps", rps)
+  c.concurrency = concurrency
+
+  if rps > 0 {
+    burst := int(rps)
+    if burst < 1 {
+      burst = 1
+    }
+    c.limiter = rate.NewLimiter(rate.Limit(rps), burst)
+  } else {
+    c.limiter = nil
+  }
+}
+
+/*
+Returns the number of workers to use for bulk operations
+*/
+func (c *VaultClient) Concurrency() int {
+  if c.concurrency < 1 {
+    return 1
+  }
+  return c.concurrency
+}
+
+/*
+This will block until the rate limiter allows another
+request, if there is no rate limit it returns right away
+*/
+func (c *VaultClient) wait() error {
+  if c.limiter == nil {
+    return nil
+  }
+  return c.limiter.Wait(*c.ctx)
+}
+
 /*
 wrapper for kv write secret
 */
 func (c *VaultClient) WriteKvSecret(s VaultSecret) error {
+  if err := c.wait(); err != nil {
+    return err
+  }
 
   if s.KvVersion == "2" {
     logger.LogDebug("Writing kv v2 secret")
@@ -96,16 +154,25 @@ func (c *VaultClient) WriteKvSecret(s VaultSecret) error {
 wrapper for kv list secret
 */
 func (c *VaultClient) ListKvSecrets(mount string, path string, kvVersion string) ([]string, error) {
+  if err := c.wait(); err != nil {
+    return nil, err
+  }
 
   if kvVersion == "2" {
     logger.LogDebug("Getting a list of kv v2 secrets for", "mount", mount)
     resp, err := c.secrets.KvV2List(*c.ctx, path, vaultGo.WithMountPath(mount))
-    return resp.Data.Keys, err
+    if err != nil {
+      return nil, err
+    }
+    return resp.Data.Keys, nil
   }
 
   logger.LogDebug("Getting a list of kv v1 secrets for", "mount", mount)
   resp, err := c.secrets.KvV1List(*c.ctx, path, vaultGo.WithMountPath(mount))
-  return resp.Data.Keys, err
+  if err != nil {
+    return nil, err
+  }
+  return resp.Data.Keys, nil
 }
 
 
@@ -115,6 +182,10 @@ wrapper for kv read secret
 func (c *VaultClient) ReadKvSecret(s VaultSecret) (map[string]interface{}, error) {
   data := make(map[string]interface{})
 
+  if err := c.wait(); err != nil {
+    return data, err
+  }
+
   if s.KvVersion == "2" {
     logger.LogDebug("Reading kv v2 secret")
 
@@ -137,12 +208,246 @@ func (c *VaultClient) ReadKvSecret(s VaultSecret) (map[string]interface{}, error
   return resp.Data, nil
 }
 
+/*
+wrapper for kv read secret that also returns the version
+that was read, kv v1 secrets have no versions so 0 is returned
+*/
+func (c *VaultClient) ReadKvSecretWithVersion(s VaultSecret) (map[string]interface{},
+  int64, error) {
+
+  if s.KvVersion != "2" {
+    data, err := c.ReadKvSecret(s)
+    return data, 0, err
+  }
+
+  if err := c.wait(); err != nil {
+    return nil, 0, err
+  }
+
+  logger.LogDebug("Reading kv v2 secret with its version")
+  resp, err := c.secrets.KvV2Read(*c.ctx, s.NormalizedSecretPath,
+    vaultGo.WithMountPath(s.MountName))
+  if err != nil {
+    logger.LogError("Error reading the v2 secret")
+    return nil, 0, err
+  }
+
+  version, err := strconv.ParseInt(fmt.Sprint(resp.Data.Metadata["version"]), 10, 64)
+  if err != nil {
+    logger.LogError("Error reading the v2 secret version")
+    return nil, 0, fmt.Errorf("secret %s has no version in its metadata", s.VaultKey)
+  }
+  return resp.Data.Data, version, nil
+}
+
+/*
+wrapper for kv v2 read secret at a version
+*/
+func (c *VaultClient) ReadKvSecretVersion(s VaultSecret, version int64) (map[string]interface{},
+  error) {
+  if err := c.wait(); err != nil {
+    return nil, err
+  }
+
+  logger.LogDebug("Reading kv v2 secret version", "version", version)
+  params := url.Values{}
+  params.Set("version", strconv.FormatInt(version, 10))
+
+  resp, err := c.secrets.KvV2Read(*c.ctx, s.NormalizedSecretPath,
+    vaultGo.WithMountPath(s.MountName), vaultGo.WithQueryParameters(params))
+  if err != nil {
+    logger.LogError("Error reading the v2 secret version")
+    return nil, err
+  }
+  return resp.Data.Data, nil
+}
+
+/*
+wrapper for kv v2 write secret that returns the
+new version of the secret
+*/
+func (c *VaultClient) WriteKvSecretVersion(s VaultSecret) (int64, error) {
+  if err := c.wait(); err != nil {
+    return 0, err
+  }
+
+  logger.LogDebug("Writing kv v2 secret")
+  writeReq := schema.KvV2WriteRequest {
+    Data: s.SecretData,
+  }
+  resp, err := c.secrets.KvV2Write(*c.ctx, s.NormalizedSecretPath,
+    writeReq, vaultGo.WithMountPath(s.MountName))
+  if err != nil {
+    logger.LogError("Error writing the v2 secret")
+    return 0, err
+  }
+  return resp.Data.Version, nil
+}
+
+/*
+wrapper for kv v2 write secret with check-and-set, the
+write is only done if the current version of the secret
+is still the cas version
+*/
+func (c *VaultClient) WriteKvSecretCas(s VaultSecret, cas int64) error {
+  if err := c.wait(); err != nil {
+    return err
+  }
+
+  logger.LogDebug("Writing kv v2 secret with check-and-set", "cas", cas)
+  writeReq := schema.KvV2WriteRequest {
+    Data: s.SecretData,
+    Options: map[string]interface{}{"cas": cas},
+  }
+  _, err := c.secrets.KvV2Write(*c.ctx, s.NormalizedSecretPath,
+    writeReq, vaultGo.WithMountPath(s.MountName))
+  return err
+}
+
+/*
+wrapper for kv v2 read metadata
+*/
+func (c *VaultClient) ReadKvMetadata(s VaultSecret) (schema.KvV2ReadMetadataResponse,
+  error) {
+  if err := c.wait(); err != nil {
+    return schema.KvV2ReadMetadataResponse{}, err
+  }
+
+  logger.LogDebug("Reading kv v2 secret metadata")
+  resp, err := c.secrets.KvV2ReadMetadata(*c.ctx, s.NormalizedSecretPath,
+    vaultGo.WithMountPath(s.MountName))
+  if err != nil {
+    logger.LogError("Error reading the v2 secret metadata")
+    return schema.KvV2ReadMetadataResponse{}, err
+  }
+  return resp.Data, nil
+}
+
+/*
+wrapper for kv v2 write metadata
+*/
+func (c *VaultClient) WriteKvMetadata(s VaultSecret,
+  metadata schema.KvV2WriteMetadataRequest) error {
+  if err := c.wait(); err != nil {
+    return err
+  }
+
+  logger.LogDebug("Writing kv v2 secret metadata")
+  _, err := c.secrets.KvV2WriteMetadata(*c.ctx, s.NormalizedSecretPath,
+    metadata, vaultGo.WithMountPath(s.MountName))
+  return err
+}
+
+/*
+wrapper for kv delete secret, for kv v2 this will
+delete the metadata and all versions of the secret
+*/
+func (c *VaultClient) DeleteKvSecret(s VaultSecret) error {
+  if err := c.wait(); err != nil {
+    return err
+  }
+
+  if s.KvVersion == "2" {
+    logger.LogDebug("Deleting kv v2 secret and all versions")
+    _, err := c.secrets.KvV2DeleteMetadataAndAllVersions(*c.ctx, s.NormalizedSecretPath,
+      vaultGo.WithMountPath(s.MountName))
+    return err
+  }
+
+  logger.LogDebug("Deleting kv v1 secret")
+  _, err := c.secrets.KvV1Delete(*c.ctx, s.NormalizedSecretPath,
+    vaultGo.WithMountPath(s.MountName))
+  return err
+}
+
+/*
+wrapper for kv soft delete secret, for kv v2 this will
+delete the current version so it can be undeleted, kv v1
+has no versions so the secret is removed
+*/
+func (c *VaultClient) SoftDeleteKvSecret(s VaultSecret) error {
+  if err := c.wait(); err != nil {
+    return err
+  }
+
+  if s.KvVersion == "2" {
+    logger.LogDebug("Deleting the current version of kv v2 secret")
+    _, err := c.secrets.KvV2Delete(*c.ctx, s.NormalizedSecretPath,
+      vaultGo.WithMountPath(s.MountName))
+    return err
+  }
+
+  logger.LogDebug("Deleting kv v1 secret")
+  _, err := c.secrets.KvV1Delete(*c.ctx, s.NormalizedSecretPath,
+    vaultGo.WithMountPath(s.MountName))
+  return err
+}
+
+/*
+wrapper for transit encrypt, the plaintext is base64
+encoded before it is sent and the vault ciphertext is
+returned
+*/
+func (c *VaultClient) TransitEncrypt(mount string, keyName string,
+  plaintext []byte) (string, error) {
+  if err := c.wait(); err != nil {
+    return "", err
+  }
+
+  logger.LogDebug("Encrypting with transit key", "mount", mount, "key", keyName)
+  resp, err := c.secrets.TransitEncrypt(*c.ctx, keyName, schema.TransitEncryptRequest{
+    Plaintext: base64.StdEncoding.EncodeToString(plaintext),
+  }, vaultGo.WithMountPath(mount))
+  if err != nil {
+    logger.LogError("Error encrypting with transit key")
+    return "", err
+  }
+
+  ciphertext, ok := resp.Data["ciphertext"].(string)
+  if !ok {
+    logger.LogError("Error transit response has no ciphertext")
+    return "", errors.New("transit response has no ciphertext")
+  }
+  return ciphertext, nil
+}
+
+/*
+wrapper for transit decrypt, the decoded plaintext
+is returned
+*/
+func (c *VaultClient) TransitDecrypt(mount string, keyName string,
+  ciphertext string) ([]byte, error) {
+  if err := c.wait(); err != nil {
+    return nil, err
+  }
+
+  logger.LogDebug("Decrypting with transit key", "mount", mount, "key", keyName)
+  resp, err := c.secrets.TransitDecrypt(*c.ctx, keyName, schema.TransitDecryptRequest{
+    Ciphertext: ciphertext,
+  }, vaultGo.WithMountPath(mount))
+  if err != nil {
+    logger.LogError("Error decrypting with transit key")
+    return nil, err
+  }
+
+  plaintext, ok := resp.Data["plaintext"].(string)
+  if !ok {
+    logger.LogError("Error transit response has no plaintext")
+    return nil, errors.New("transit response has no plaintext")
+  }
+  return base64.StdEncoding.DecodeString(plaintext)
+}
+
 /*
 wrapper for MountsListSecretsENgines
 */
 func (c *VaultClient) GetSecretMountsData() (map[string]interface{}, 
   error) {
 
+    if err := c.wait(); err != nil {
+      return nil, err
+    }
+
     mounts, err := c.system.MountsListSecretsEngines(*c.ctx)
     if err != nil {
       logger.LogError("Error getting a list of secrets engines")
@@ -152,6 +457,60 @@ func (c *VaultClient) GetSecretMountsData() (map[string]interface{},
     return mounts.Data, nil
 }
  
+/*
+wrapper for enabling a kv secrets engine
+*/
+func (c *VaultClient) EnableKvMount(mount string, kvVersion string,
+  description string) error {
+  if err := c.wait(); err != nil {
+    return err
+  }
+
+  logger.LogDebug("Enabling kv secrets engine", "mount", mount, "kvVersion", kvVersion)
+  _, err := c.system.MountsEnableSecretsEngine(*c.ctx, strings.TrimSuffix(mount, "/"),
+    schema.MountsEnableSecretsEngineRequest{
+      Type: "kv",
+      Description: description,
+      Options: map[string]interface{}{"version": kvVersion},
+    })
+  return err
+}
+
+/*
+Checks if an error returned from vault is a
+not found (404) error
+*/
+func IsNotFoundError(err error) bool {
+  return vaultGo.IsErrorStatus(err, http.StatusNotFound)
+}
+
+/*
+Checks if an error returned from vault is a
+permission denied (403) error
+*/
+func IsPermissionDeniedError(err error) bool {
+  return vaultGo.IsErrorStatus(err, http.StatusForbidden)
+}
+
+/*
+Checks if an error returned from vault is a kv v2
+check-and-set mismatch, the secret was changed since
+the version that was given
+*/
+func IsCasMismatchError(err error) bool {
+  var responseError *vaultGo.ResponseError
+  if !errors.As(err, &responseError) || responseError.StatusCode != http.StatusBadRequest {
+    return false
+  }
+
+  for _, message := range responseError.Errors {
+    if strings.Contains(message, "check-and-set") {
+      return true
+    }
+  }
+  return false
+}
+
 /*
 Checks if any custom tls configuration is needed and returns if 
 that custom configuration is enabled and what that configuration is
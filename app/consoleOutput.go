@@ -1,8 +1,12 @@
 package app
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/olekukonko/tablewriter"
 )
@@ -49,7 +53,9 @@ func ListVaultsConsoleOutput(settingsExist bool, vaults []string) {
 /*
 Console output for Get secret
 */
-func GetSecretConsoleOutput(secret VaultSecret, secretExists bool) {
+func GetSecretConsoleOutput(secret VaultSecret, secretExists bool,
+  reveal RevealOptions, jsonView bool) {
+
   fmt.Println("Get Secret Results")
   fmt.Println("==============================")
 
@@ -61,24 +67,181 @@ func GetSecretConsoleOutput(secret VaultSecret, secretExists bool) {
   fmt.Println("Key: " + secret.NormalizedSecretPath)
   fmt.Println("")
   fmt.Println("data:")
-  
-  for key, val := range secret.SecretData {
-    fmt.Println("Key: " + key)
-    fmt.Println("Value: " + val.(string))
+
+  masked := MaskSecretData(secret.SecretData, reveal)
+  if jsonView {
+    jsonBytes, err := json.MarshalIndent(masked, "", "  ")
+    if err != nil {
+      fmt.Println("Error formatting secret data: " + err.Error())
+      return
+    }
+    fmt.Println(string(jsonBytes))
+  } else {
+    table := tablewriter.NewWriter(os.Stdout)
+    table.SetHeader([]string{"Field", "Value"})
+    table.SetAlignment(tablewriter.ALIGN_LEFT)
+    table.SetAutoWrapText(false)
+
+    var rows [][]string
+    walkLeaves(masked, "", func(field string, name string, value interface{}) {
+      rows = append(rows, []string{field, searchText(value)})
+    })
+    sort.Slice(rows, func(i, j int) bool { return rows[i][0] < rows[j][0] })
+    table.AppendBulk(rows)
+    table.Render()
+  }
+
+  if !reveal.All {
     fmt.Println("")
+    fmt.Println("Values are masked, use --reveal or --reveal-field to show them")
   }
 }
 
 /*
 Console output for listing secrets
 */
-func ListSecretsConsoleOutput(secrets []string, mountName string) {
+func ListSecretsConsoleOutput(secrets []string, result WalkResult, mountName string) {
+  ListSecretsHeaderConsoleOutput(mountName)
+  for _, key := range secrets {
+    fmt.Println(key)
+  }
+  WalkResultConsoleOutput(result)
+}
+
+/*
+Console output for the start of a secret list, the
+secrets are printed after it as they are found when
+streaming
+*/
+func ListSecretsHeaderConsoleOutput(mountName string) {
   fmt.Printf("Secrets for mount %s\n", mountName)
   fmt.Println("============================")
 
   fmt.Println("Secrets:")
-  for _, key := range secrets {
-    fmt.Println(key)
+}
+
+/*
+Console output for the folders a secret list
+did not walk
+*/
+func WalkResultConsoleOutput(result WalkResult) {
+  if len(result.Folders) > 0 {
+    fmt.Println("")
+    fmt.Println("Folders not walked:")
+    for _, folder := range result.Folders {
+      fmt.Println(folder)
+    }
+  }
+
+  skippedFoldersConsoleOutput(result.Skipped)
+}
+
+/*
+This will print the folders that could not be listed
+*/
+func skippedFoldersConsoleOutput(skippedFolders []SkippedFolder) {
+  if len(skippedFolders) == 0 {
+    return
+  }
+
+  fmt.Println("")
+  fmt.Println("The following folders could not be listed")
+  for _, skipped := range skippedFolders {
+    fmt.Printf("folder: %s, error: %s\n", skipped.Path, skipped.Error)
+  }
+}
+
+/*
+Console output for listing secrets with metadata
+*/
+func SecretDetailsConsoleOutput(details []SecretDetail, result WalkResult,
+  errorList []SecretActionError, mountName string) {
+
+  fmt.Printf("Secrets for mount %s\n", mountName)
+  fmt.Println("============================")
+
+  table := tablewriter.NewWriter(os.Stdout)
+  table.SetHeader([]string{"Secret", "Version", "Versions", "Created", "Updated", "State", "Owner"})
+  table.SetAlignment(tablewriter.ALIGN_LEFT)
+  table.SetRowLine(true)
+  table.SetAutoWrapText(false)
+
+  for _, detail := range details {
+    table.Append([]string{
+      detail.Key,
+      strconv.FormatInt(detail.CurrentVersion, 10),
+      strconv.Itoa(detail.VersionCount),
+      formatTime(detail.CreatedTime),
+      formatTime(detail.UpdatedTime),
+      detail.DeletionState,
+      detail.Owner,
+    })
+  }
+
+  table.Render()
+  WalkResultConsoleOutput(result)
+
+  if len(errorList) > 0 {
+    fmt.Println("")
+    fmt.Println("The following secrets had errors")
+    for _, errorSecret := range errorList {
+      fmt.Printf("key: %s, error: %s\n", errorSecret.VaultKey, errorSecret.Error)
+    }
+  }
+}
+
+/*
+This will format a time for a table, times
+that are not set are left empty
+*/
+func formatTime(t time.Time) string {
+  if t.IsZero() {
+    return ""
+  }
+  return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+/*
+Console output for listing secrets as a tree
+*/
+func SecretTreeConsoleOutput(tree *SecretFolder, skippedFolders []SkippedFolder) {
+  fmt.Printf("Secrets for %s\n", tree.Path)
+  fmt.Println("============================")
+
+  fmt.Printf("%s (%d secrets)\n", tree.Path, tree.SecretCount)
+  printSecretFolder(tree, "")
+  skippedFoldersConsoleOutput(skippedFolders)
+}
+
+/*
+This will print the folders and secrets in a folder
+with the lines of the tree in front of them
+*/
+func printSecretFolder(folder *SecretFolder, indent string) {
+  total := len(folder.Folders) + len(folder.Secrets)
+  i := 0
+
+  branch := func() (string, string) {
+    i++
+    if i == total {
+      return "└── ", "    "
+    }
+    return "├── ", "│   "
+  }
+
+  for _, child := range folder.Folders {
+    line, childIndent := branch()
+    if child.Truncated {
+      fmt.Printf("%s%s%s (not walked)\n", indent, line, child.Name)
+      continue
+    }
+    fmt.Printf("%s%s%s (%d secrets)\n", indent, line, child.Name, child.SecretCount)
+    printSecretFolder(child, indent+childIndent)
+  }
+
+  for _, secret := range folder.Secrets {
+    line, _ := branch()
+    fmt.Printf("%s%s%s\n", indent, line, secret)
   }
 }
 
@@ -119,3 +282,367 @@ func ListMountsWithDetailConsoleOutput(mounts []SecretMount) {
 
   table.Render()
 }
+
+/*
+Console output for a bulk load plan, values are masked
+unless showValues is set
+*/
+func PlanConsoleOutput(plan SecretPlan, showValues bool) {
+  fmt.Println("Plan")
+  fmt.Println("===========================")
+  fmt.Println()
+
+  for _, entry := range plan.Entries {
+    switch entry.Action {
+    case PlanActionCreate:
+      fmt.Printf("  + %s (create)\n", entry.Secret.VaultKey)
+    case PlanActionUpdate:
+      fmt.Printf("  ~ %s (update)\n", entry.Secret.VaultKey)
+    case PlanActionDelete:
+      if entry.Destroy {
+        fmt.Printf("  - %s (destroy all versions)\n", entry.Secret.VaultKey)
+      } else {
+        fmt.Printf("  - %s (delete)\n", entry.Secret.VaultKey)
+      }
+      continue
+    case PlanActionExists:
+      fmt.Printf("  ! %s (exists, not updated)\n", entry.Secret.VaultKey)
+      continue
+    default:
+      fmt.Printf("  = %s (unchanged)\n", entry.Secret.VaultKey)
+      continue
+    }
+
+    for _, change := range entry.Changes {
+      switch change.Action {
+      case FieldActionAdd:
+        fmt.Printf("      + %s = %s\n", change.Field,
+          planValue(change.NewValue, showValues))
+      case FieldActionChange:
+        fmt.Printf("      ~ %s = %s => %s\n", change.Field,
+          planValue(change.OldValue, showValues), planValue(change.NewValue, showValues))
+      case FieldActionRemove:
+        fmt.Printf("      - %s = %s\n", change.Field,
+          planValue(change.OldValue, showValues))
+      }
+    }
+  }
+
+  creates, updates, deletes, unchanged := plan.Counts()
+  fmt.Println()
+  fmt.Printf("Plan: %d to create, %d to update, %d to delete, %d unchanged\n",
+    creates, updates, deletes, unchanged)
+
+  existing := 0
+  for _, entry := range plan.Entries {
+    if entry.Action == PlanActionExists {
+      existing++
+    }
+  }
+  if existing > 0 {
+    fmt.Printf("%d secrets already exist and will not be updated\n", existing)
+  }
+}
+
+/*
+This will format a value for plan output
+*/
+func planValue(value interface{}, showValues bool) string {
+  if !showValues {
+    return "(sensitive)"
+  }
+  jsonBytes, err := json.Marshal(value)
+  if err != nil {
+    return fmt.Sprintf("%v", value)
+  }
+  return string(jsonBytes)
+}
+
+/*
+Console output for applying a plan
+*/
+func PlanApplyConsoleOutput(result PlanApplyResult) {
+  fmt.Println("Apply Results")
+  fmt.Println("===========================")
+
+  fmt.Printf("%d secrets added/updated\n", len(result.Applied))
+  fmt.Printf("%d secrets removed\n", len(result.Deleted))
+  fmt.Printf("%d secrets unchanged\n", len(result.Unchanged))
+  if len(result.Existing) > 0 {
+    fmt.Printf("%d secrets already exist and were not updated\n", len(result.Existing))
+  }
+  if len(result.Skipped) > 0 {
+    fmt.Printf("%d secrets already done in an earlier run\n", len(result.Skipped))
+  }
+  fmt.Println("")
+  fmt.Println("The following secrets had errors")
+  for _, errorSecret := range result.Errors {
+    fmt.Printf("key: %s, error: %s\n", errorSecret.VaultKey, errorSecret.Error)
+  }
+}
+
+/*
+Console output for moving secrets
+*/
+func MoveConsoleOutput(result MoveResult, journalFile string, reversed bool) {
+  fmt.Println("Move Results")
+  fmt.Println("===========================")
+
+  if reversed {
+    fmt.Printf("%d secrets reversed\n", len(result.Completed))
+  } else {
+    fmt.Printf("%d secrets moved\n", len(result.Completed))
+  }
+  fmt.Printf("Journal: %s\n", journalFile)
+  fmt.Println("")
+  fmt.Println("The following secrets had errors")
+  for _, errorSecret := range result.Errors {
+    fmt.Printf("key: %s, error: %s\n", errorSecret.VaultKey, errorSecret.Error)
+  }
+}
+
+/*
+Console output for exporting secrets
+*/
+func ExportConsoleOutput(secretNames []string, errorList []SecretActionError,
+  outputFile string, encrypted bool) {
+  fmt.Println("Export Results")
+  fmt.Println("===========================")
+
+  if encrypted {
+    fmt.Printf("%d secrets exported to %s (encrypted)\n", len(secretNames), outputFile)
+  } else {
+    fmt.Printf("%d secrets exported to %s\n", len(secretNames), outputFile)
+  }
+  fmt.Println("")
+  fmt.Println("The following secrets had errors")
+  for _, errorSecret := range errorList {
+    fmt.Printf("key: %s, error: %s\n", errorSecret.VaultKey, errorSecret.Error)
+  }
+}
+
+/*
+Console output for rolling back a failed atomic write
+*/
+func RollbackConsoleOutput(result RollbackResult) {
+  fmt.Println("")
+  fmt.Println("Rollback Results")
+  fmt.Println("===========================")
+
+  fmt.Printf("%d secrets restored\n", len(result.Restored))
+  for _, name := range result.Restored {
+    fmt.Println(name)
+  }
+  fmt.Printf("%d new secrets deleted\n", len(result.Deleted))
+  for _, name := range result.Deleted {
+    fmt.Println(name)
+  }
+  if len(result.Conflicts) > 0 {
+    fmt.Println("")
+    fmt.Println("The following secrets were changed by someone else and were not rolled back")
+    for _, name := range result.Conflicts {
+      fmt.Println(name)
+    }
+  }
+  fmt.Println("")
+  fmt.Println("The following secrets could not be rolled back")
+  for _, errorSecret := range result.Errors {
+    fmt.Printf("key: %s, error: %s\n", errorSecret.VaultKey, errorSecret.Error)
+  }
+}
+
+/*
+Console output for a checkpoint that was kept
+because the operation did not finish
+*/
+func CheckpointConsoleOutput(checkpointFile string) {
+  fmt.Println("")
+  fmt.Printf("Not every secret finished, resume with --resume %s\n", checkpointFile)
+}
+
+/*
+Console output for validating a secrets file
+*/
+func ValidateConsoleOutput(secretsFile string, issues []ValidationIssue) {
+  fmt.Println("Validation Results")
+  fmt.Println("===========================")
+
+  if len(issues) == 0 {
+    fmt.Printf("%s is valid\n", secretsFile)
+    return
+  }
+
+  fmt.Printf("%s has %d problems\n", secretsFile, len(issues))
+  fmt.Println("")
+  for _, issue := range issues {
+    if issue.Line == 0 {
+      fmt.Printf("%s: %s\n", secretsFile, issue)
+    } else {
+      fmt.Printf("%s:%s\n", secretsFile, issue)
+    }
+  }
+}
+
+/*
+Console output for backing up kv mounts
+*/
+func BackupConsoleOutput(backup Backup, errorList []SecretActionError,
+  backupFile string) {
+  fmt.Println("Backup Results")
+  fmt.Println("===========================")
+
+  for _, mount := range backup.Manifest.Mounts {
+    fmt.Printf("%s (kv v%s): %d secrets\n", mount.Mount, mount.KvVersion, mount.SecretCount)
+  }
+  fmt.Printf("%d secrets backed up to %s\n", len(backup.Secrets), backupFile)
+  fmt.Println("")
+  fmt.Println("The following secrets had errors")
+  for _, errorSecret := range errorList {
+    fmt.Printf("key: %s, error: %s\n", errorSecret.VaultKey, errorSecret.Error)
+  }
+}
+
+/*
+Console output for searching secrets
+*/
+func SearchConsoleOutput(matches []SearchMatch, errorList []SecretActionError) {
+  fmt.Println("Search Results")
+  fmt.Println("===========================")
+
+  fmt.Printf("%d secrets matched\n", len(matches))
+  for _, match := range matches {
+    fmt.Println("")
+    fmt.Println(match.Key)
+    if match.PathMatched {
+      fmt.Println("  path matched")
+    }
+    for _, field := range match.Fields {
+      fmt.Printf("  field: %s%s\n", field, searchValue(match, field))
+    }
+    for _, field := range match.ValueFields {
+      fmt.Printf("  value: %s%s\n", field, searchValue(match, field))
+    }
+  }
+
+  fmt.Println("")
+  fmt.Println("The following secrets had errors")
+  for _, errorSecret := range errorList {
+    fmt.Printf("key: %s, error: %s\n", errorSecret.VaultKey, errorSecret.Error)
+  }
+}
+
+/*
+This will format the value of a matched field when
+values are being shown
+*/
+func searchValue(match SearchMatch, field string) string {
+  value, ok := match.Values[field]
+  if !ok {
+    return ""
+  }
+  return " = " + searchText(value)
+}
+
+/*
+Console output for auditing duplicate secret values
+*/
+func DuplicatesConsoleOutput(groups []DuplicateGroup, errorList []SecretActionError) {
+  fmt.Println("Duplicate Secret Values")
+  fmt.Println("===========================")
+
+  fmt.Printf("%d values are stored in more than one place\n", len(groups))
+  if len(groups) > 0 {
+    table := tablewriter.NewWriter(os.Stdout)
+    table.SetHeader([]string{"Group", "Secret", "Field"})
+    table.SetAlignment(tablewriter.ALIGN_LEFT)
+    table.SetAutoMergeCells(true)
+    table.SetRowLine(true)
+    table.SetAutoWrapText(false)
+
+    for _, group := range groups {
+      for _, location := range group.Locations {
+        table.Append([]string{group.Id, location.Key, location.Field})
+      }
+    }
+    table.Render()
+  }
+
+  fmt.Println("")
+  fmt.Println("The following secrets had errors")
+  for _, errorSecret := range errorList {
+    fmt.Printf("key: %s, error: %s\n", errorSecret.VaultKey, errorSecret.Error)
+  }
+}
+
+/*
+Console output for reporting stale secrets
+*/
+func StaleConsoleOutput(report StaleReport, warnDays int, criticalDays int) {
+  fmt.Println("Stale Secrets")
+  fmt.Println("===========================")
+
+  fmt.Printf("%d secrets checked, %d warn (%d days), %d critical (%d days)\n",
+    report.Checked, report.Warn, warnDays, report.Critical, criticalDays)
+
+  for _, group := range report.Groups {
+    owner := group.Owner
+    if owner == "" {
+      owner = "(no owner)"
+    }
+
+    fmt.Println("")
+    fmt.Printf("%s owner: %s, %d warn, %d critical\n", group.Mount, owner, group.Warn, group.Critical)
+
+    table := tablewriter.NewWriter(os.Stdout)
+    table.SetHeader([]string{"Secret", "Updated", "Age (days)", "Status"})
+    table.SetAlignment(tablewriter.ALIGN_LEFT)
+    table.SetAutoWrapText(false)
+
+    for _, secret := range group.Secrets {
+      table.Append([]string{
+        secret.Key,
+        formatTime(secret.UpdatedTime),
+        strconv.Itoa(secret.AgeDays),
+        secret.Status,
+      })
+    }
+    table.Render()
+  }
+
+  skippedFoldersConsoleOutput(report.Skipped)
+
+  fmt.Println("")
+  fmt.Println("The following secrets had errors")
+  for _, errorSecret := range report.Errors {
+    fmt.Printf("key: %s, error: %s\n", errorSecret.VaultKey, errorSecret.Error)
+  }
+}
+
+/*
+This will print the files rendered from templates
+*/
+func RenderConsoleOutput(results []RenderResult) {
+  fmt.Println("Rendered Templates")
+  fmt.Println("===========================")
+
+  for _, result := range results {
+    status := "unchanged"
+    if result.Changed {
+      status = "written"
+    }
+    fmt.Printf("%s -> %s (%s)\n", result.Source, result.Destination, status)
+  }
+}
+
+/*
+This will print the names in a generated artifact
+*/
+func GenerateConsoleOutput(kind string, outputFile string, names []string) {
+  fmt.Println("Generated " + kind)
+  fmt.Println("===========================")
+  fmt.Printf("file: %s\n", outputFile)
+
+  for _, name := range names {
+    fmt.Println(name)
+  }
+}
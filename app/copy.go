@@ -0,0 +1,310 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgutierrez1287/vault-util/logger"
+)
+
+/*
+CopyOptions - options for copying secrets
+from one vault to another
+*/
+type CopyOptions struct {
+  FromPath string
+  ToPath string
+  Include []string
+  Exclude []string
+  Prune bool
+  MaxDeletions int
+  Destroy bool
+}
+
+/*
+This will build a plan to copy secrets from one vault to
+another, the from path can be a single secret or a folder.
+The plan is made against the destination vault so it can
+be applied with the destination client
+*/
+func BuildCopyPlan(fromClient *VaultClient, toClient *VaultClient,
+  opts CopyOptions) (SecretPlan, error) {
+
+  plan := SecretPlan{
+    FormatVersion: SecretPlanFormatVersion,
+    CreatedAt: time.Now().UTC(),
+  }
+
+  if isSecretKey(opts.FromPath) {
+    logger.LogDebug("Checking if the from path is a single secret", "path", opts.FromPath)
+    source, err := NewSecret(opts.FromPath, "", "", nil, *fromClient)
+    if err != nil {
+      logger.LogError("Error getting source secret details")
+      return plan, err
+    }
+
+    sourceData, exists, err := source.ReadCurrentData(fromClient)
+    if err != nil {
+      logger.LogError("Error reading source secret")
+      return plan, err
+    }
+
+    if exists {
+      logger.LogDebug("From path is a single secret")
+      entry, err := copyPlanEntry(toClient, opts.FromPath, opts.ToPath, sourceData)
+      if err != nil {
+        return plan, err
+      }
+      plan.Entries = append(plan.Entries, entry)
+      return plan, nil
+    }
+  }
+
+  logger.LogDebug("From path is a folder, listing secrets")
+  sourceKeys, err := listSubtree(fromClient, opts.FromPath)
+  if err != nil {
+    logger.LogError("Error listing source secrets")
+    return plan, err
+  }
+
+  var relativeKeys []string
+  for relKey := range sourceKeys {
+    if MatchesFilters(relKey, opts.Include, opts.Exclude) {
+      relativeKeys = append(relativeKeys, relKey)
+    }
+  }
+
+  // an empty source is an error even with prune, a mistyped from
+  // path would otherwise plan deleting everything at the to path
+  if len(relativeKeys) == 0 {
+    logger.LogError("Error no secrets found to copy")
+    return plan, errors.New("no secrets found at from path")
+  }
+
+  var lock sync.Mutex
+  entries := make(map[string]SecretPlanEntry)
+
+  results := RunBulk(relativeKeys, fromClient.Concurrency(), func(relKey string) error {
+    sourceSecret, err := NewSecret(sourceKeys[relKey], "", "", nil, *fromClient)
+    if err != nil {
+      return err
+    }
+
+    data, exists, err := sourceSecret.ReadCurrentData(fromClient)
+    if err != nil {
+      return err
+    }
+    if !exists {
+      return errors.New("secret was deleted while copying")
+    }
+
+    entry, err := copyPlanEntry(toClient, sourceKeys[relKey],
+      joinKey(opts.ToPath, relKey), data)
+    if err != nil {
+      return err
+    }
+
+    lock.Lock()
+    entries[relKey] = entry
+    lock.Unlock()
+    return nil
+  })
+
+  for _, result := range results {
+    if result.Err != nil {
+      return plan, fmt.Errorf("%s: %w", sourceKeys[result.Key], result.Err)
+    }
+    plan.Entries = append(plan.Entries, entries[result.Key])
+  }
+
+  if opts.Prune {
+    logger.LogDebug("Finding destination secrets to prune")
+    pruneEntries, err := prunePlanEntries(toClient, opts.ToPath, sourceKeys,
+      opts.Include, opts.Exclude, nil, opts.Destroy)
+    if err != nil {
+      return plan, err
+    }
+
+    if opts.MaxDeletions >= 0 && len(pruneEntries) > opts.MaxDeletions {
+      logger.LogError("Error copy would delete more than the max deletions")
+      return plan, fmt.Errorf("copy would delete %d secrets which is more than the max of %d",
+        len(pruneEntries), opts.MaxDeletions)
+    }
+    plan.Entries = append(plan.Entries, pruneEntries...)
+  }
+  return plan, nil
+}
+
+/*
+This will build the plan entry for copying data to a
+destination key, the destination secret details are
+looked up so kv versions can be different
+*/
+func copyPlanEntry(toClient *VaultClient, fromKey string, toKey string,
+  data map[string]interface{}) (SecretPlanEntry, error) {
+
+  logger.LogDebug("Planning copy", "from", fromKey, "to", toKey)
+  dest, err := NewSecret(toKey, "", "", data, *toClient)
+  if err != nil {
+    logger.LogError("Error getting destination secret details")
+    return SecretPlanEntry{}, err
+  }
+
+  current, exists, err := dest.ReadCurrentData(toClient)
+  if err != nil {
+    logger.LogError("Error reading destination secret")
+    return SecretPlanEntry{}, err
+  }
+  return planEntry(toKey, dest, current, exists), nil
+}
+
+/*
+This will build delete plan entries for every secret under
+the destination path that is not in the keep list, protected
+keys are never deleted. The deletes are soft deletes unless
+destroy is set
+*/
+func prunePlanEntries(client *VaultClient, toPath string, keep map[string]string,
+  include []string, exclude []string, protected []string,
+  destroy bool) ([]SecretPlanEntry, error) {
+
+  var entries []SecretPlanEntry
+
+  destKeys, err := listSubtree(client, toPath)
+  if err != nil {
+    logger.LogError("Error listing destination secrets")
+    return entries, err
+  }
+
+  var pruneKeys []string
+  for relKey := range destKeys {
+    if _, ok := keep[relKey]; ok {
+      continue
+    }
+    if !MatchesFilters(relKey, include, exclude) {
+      continue
+    }
+    if isProtected(destKeys[relKey], protected) {
+      logger.LogDebug("Secret is protected, not pruning", "key", destKeys[relKey])
+      continue
+    }
+    pruneKeys = append(pruneKeys, relKey)
+  }
+
+  var lock sync.Mutex
+  pruned := make(map[string]SecretPlanEntry)
+
+  results := RunBulk(pruneKeys, client.Concurrency(), func(relKey string) error {
+    secret, err := NewSecret(destKeys[relKey], "", "", nil, *client)
+    if err != nil {
+      return err
+    }
+
+    current, exists, err := secret.ReadCurrentData(client)
+    if err != nil {
+      return err
+    }
+    if !exists {
+      return nil
+    }
+
+    lock.Lock()
+    pruned[relKey] = deletePlanEntry(destKeys[relKey], secret, current, destroy)
+    lock.Unlock()
+    return nil
+  })
+
+  for _, result := range results {
+    if result.Err != nil {
+      return entries, fmt.Errorf("%s: %w", destKeys[result.Key], result.Err)
+    }
+    if entry, ok := pruned[result.Key]; ok {
+      entries = append(entries, entry)
+    }
+  }
+  return entries, nil
+}
+
+/*
+This will list every secret under a key, the returned map
+is from the key relative to the folder to the full key
+*/
+func listSubtree(client *VaultClient, folderKey string) (map[string]string, error) {
+  keys := make(map[string]string)
+
+  secrets, err := listSubtreeKeys(client, folderKey)
+  if err != nil {
+    return keys, err
+  }
+
+  mountName, prefix := splitFolderKey(folderKey)
+  base := mountName
+  if prefix != "" {
+    base = mountName + prefix + "/"
+  }
+  for _, fullKey := range secrets {
+    keys[strings.TrimPrefix(fullKey, base)] = fullKey
+  }
+  return keys, nil
+}
+
+/*
+This will list the full key of every secret under a key
+*/
+func listSubtreeKeys(client *VaultClient, folderKey string) ([]string, error) {
+  mountName, prefix := splitFolderKey(folderKey)
+  mount, err := NewSecretMount(mountName, "", "", "", client)
+  if err != nil {
+    logger.LogError("Error getting secret mount details")
+    return nil, err
+  }
+
+  if mount.Type != "kv" {
+    logger.LogError("Error secret mount is not kv")
+    return nil, fmt.Errorf("secret mount %s is not kv", mountName)
+  }
+
+  return mount.ListSecretsWithPrefix(client, prefix)
+}
+
+/*
+This will split a folder key into its mount and the
+prefix of the folder in the mount
+*/
+func splitFolderKey(folderKey string) (string, string) {
+  mountName := MountFromKey(folderKey)
+  prefix := strings.TrimPrefix(strings.Trim(folderKey, "/"),
+    strings.TrimSuffix(mountName, "/"))
+  return mountName, strings.Trim(prefix, "/")
+}
+
+/*
+This will check if a key matches any protected glob pattern
+*/
+func isProtected(key string, protected []string) bool {
+  for _, pattern := range protected {
+    if GlobMatch(pattern, key) {
+      return true
+    }
+  }
+  return false
+}
+
+/*
+This will check if a key could be a single secret, a key
+with only a mount or that ends with a / is a folder
+*/
+func isSecretKey(key string) bool {
+  trimmed := strings.TrimPrefix(key, "/")
+  return strings.Contains(trimmed, "/") && !strings.HasSuffix(trimmed, "/")
+}
+
+/*
+This will join a folder key and a relative key
+*/
+func joinKey(folderKey string, relKey string) string {
+  return strings.TrimSuffix(folderKey, "/") + "/" + strings.TrimPrefix(relKey, "/")
+}
@@ -0,0 +1,164 @@
+package app
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for copying secrets between vaults
+*/
+func planActions(plan SecretPlan) map[string]string {
+  actions := make(map[string]string)
+  for _, entry := range plan.Entries {
+    actions[entry.Secret.VaultKey] = entry.Action
+  }
+  return actions
+}
+
+func TestBuildCopyPlanFolder(t *testing.T) {
+  from, fromClient := newFakeVault(t, map[string]string{"src/": "2"})
+  to, toClient := newFakeVault(t, map[string]string{"dst/": "2"})
+
+  from.put("src/app/db", map[string]interface{}{"password": "a"})
+  from.put("src/app/api", map[string]interface{}{"token": "b"})
+  from.put("src/app/nested/cache", map[string]interface{}{"url": "c"})
+  to.put("dst/app/api", map[string]interface{}{"token": "old"})
+  to.put("dst/app/nested/cache", map[string]interface{}{"url": "c"})
+
+  plan, err := BuildCopyPlan(fromClient, toClient, CopyOptions{
+    FromPath: "src/app",
+    ToPath: "dst/app",
+    MaxDeletions: -1,
+  })
+  assert.NoError(t, err)
+  assert.Equal(t, map[string]string{
+    "dst/app/db": PlanActionCreate,
+    "dst/app/api": PlanActionUpdate,
+    "dst/app/nested/cache": PlanActionUnchanged,
+  }, planActions(plan))
+
+  result := plan.Apply(toClient)
+  assert.Empty(t, result.Errors)
+  assert.Equal(t, map[string]interface{}{"password": "a"}, to.current("dst/app/db"))
+  assert.Equal(t, map[string]interface{}{"token": "b"}, to.current("dst/app/api"))
+  assert.Equal(t, 1, to.versionCount("dst/app/nested/cache"))
+}
+
+func TestBuildCopyPlanSingleSecret(t *testing.T) {
+  from, fromClient := newFakeVault(t, map[string]string{"src/": "2"})
+  _, toClient := newFakeVault(t, map[string]string{"dst/": "2"})
+
+  from.put("src/app/db", map[string]interface{}{"password": "a"})
+
+  plan, err := BuildCopyPlan(fromClient, toClient, CopyOptions{
+    FromPath: "src/app/db",
+    ToPath: "dst/other/db",
+  })
+  assert.NoError(t, err)
+  assert.Equal(t, map[string]string{"dst/other/db": PlanActionCreate}, planActions(plan))
+}
+
+func TestBuildCopyPlanFilters(t *testing.T) {
+  from, fromClient := newFakeVault(t, map[string]string{"src/": "2"})
+  to, toClient := newFakeVault(t, map[string]string{"dst/": "2"})
+
+  from.put("src/app/db", map[string]interface{}{"password": "a"})
+  from.put("src/app/api", map[string]interface{}{"token": "b"})
+  from.put("src/app/test/db", map[string]interface{}{"password": "c"})
+  to.put("dst/app/old", map[string]interface{}{"key": "d"})
+  to.put("dst/app/test/old", map[string]interface{}{"key": "e"})
+
+  plan, err := BuildCopyPlan(fromClient, toClient, CopyOptions{
+    FromPath: "src/app",
+    ToPath: "dst/app",
+    Include: []string{"*"},
+    Exclude: []string{"api"},
+    Prune: true,
+    MaxDeletions: -1,
+  })
+  assert.NoError(t, err)
+
+  // excluded and filtered out keys are not copied or pruned
+  assert.Equal(t, map[string]string{
+    "dst/app/db": PlanActionCreate,
+    "dst/app/old": PlanActionDelete,
+  }, planActions(plan))
+
+  _, err = BuildCopyPlan(fromClient, toClient, CopyOptions{
+    FromPath: "src/app",
+    ToPath: "dst/app",
+    Include: []string{"nothing*"},
+    Prune: true,
+  })
+  assert.Error(t, err)
+}
+
+func TestBuildCopyPlanKvVersions(t *testing.T) {
+  for _, versions := range [][2]string{{"1", "2"}, {"2", "1"}} {
+    from, fromClient := newFakeVault(t, map[string]string{"src/": versions[0]})
+    to, toClient := newFakeVault(t, map[string]string{"dst/": versions[1]})
+
+    from.put("src/app/db", map[string]interface{}{"password": "a"})
+    from.put("src/app/api", map[string]interface{}{"token": "b"})
+
+    plan, err := BuildCopyPlan(fromClient, toClient, CopyOptions{
+      FromPath: "src/app",
+      ToPath: "dst/app",
+    })
+    assert.NoError(t, err)
+
+    var keys []string
+    for _, entry := range plan.Entries {
+      keys = append(keys, entry.Secret.VaultKey)
+      assert.Equal(t, versions[1], entry.Secret.KvVersion)
+    }
+    sort.Strings(keys)
+    assert.Equal(t, []string{"dst/app/api", "dst/app/db"}, keys)
+
+    result := plan.Apply(toClient)
+    assert.Empty(t, result.Errors)
+    assert.Equal(t, map[string]interface{}{"password": "a"}, to.current("dst/app/db"))
+  }
+}
+
+func TestCopyPruneSoftDeletes(t *testing.T) {
+  from, fromClient := newFakeVault(t, map[string]string{"src/": "2"})
+  to, toClient := newFakeVault(t, map[string]string{"dst/": "2"})
+
+  from.put("src/app/db", map[string]interface{}{"password": "a"})
+  to.put("dst/app/old", map[string]interface{}{"key": "1"})
+  to.put("dst/app/old", map[string]interface{}{"key": "2"})
+  to.put("dst/app/gone", map[string]interface{}{"key": "3"})
+
+  opts := CopyOptions{FromPath: "src/app", ToPath: "dst/app", Prune: true, MaxDeletions: 1}
+  _, err := BuildCopyPlan(fromClient, toClient, opts)
+  assert.Error(t, err)
+
+  opts.MaxDeletions = 2
+  plan, err := BuildCopyPlan(fromClient, toClient, opts)
+  assert.NoError(t, err)
+
+  result := plan.Apply(toClient)
+  assert.Empty(t, result.Errors)
+  assert.Len(t, result.Deleted, 2)
+  assert.Nil(t, to.current("dst/app/old"))
+  assert.Equal(t, 2, to.versionCount("dst/app/old"))
+
+  // destroy removes every version and the metadata
+  to.put("dst/app/gone", map[string]interface{}{"key": "4"})
+  opts.Destroy = true
+  plan, err = BuildCopyPlan(fromClient, toClient, opts)
+  assert.NoError(t, err)
+  assert.Equal(t, map[string]string{
+    "dst/app/db": PlanActionUnchanged,
+    "dst/app/gone": PlanActionDelete,
+  }, planActions(plan))
+
+  result = plan.Apply(toClient)
+  assert.Empty(t, result.Errors)
+  assert.Equal(t, 0, to.versionCount("dst/app/gone"))
+  assert.Equal(t, 2, to.versionCount("dst/app/old"))
+}
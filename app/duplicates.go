@@ -0,0 +1,129 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"sync"
+
+	"github.com/dgutierrez1287/vault-util/logger"
+)
+
+/*
+DuplicateLocation - a field in a secret
+*/
+type DuplicateLocation struct {
+  Key string                    `json:"key"`
+  Field string                  `json:"field"`
+}
+
+/*
+DuplicateGroup - the fields that hold the same value, the
+id is part of a keyed hash of the value so groups can be told
+apart without showing the value
+*/
+type DuplicateGroup struct {
+  Id string                     `json:"id"`
+  Locations []DuplicateLocation `json:"locations"`
+}
+
+/*
+This will find field values that are stored in more than one
+place in the mounts, or every kv mount when no mounts are passed.
+Values are hashed with a random key that only lives for this run
+and values shorter than the min length are ignored. Secrets that
+cannot be read are returned as errors
+*/
+func FindDuplicates(client *VaultClient, mountNames []string,
+  minLength int) ([]DuplicateGroup, []SecretActionError, error) {
+
+  hmacKey := make([]byte, 32)
+  _, err := rand.Read(hmacKey)
+  if err != nil {
+    logger.LogError("Error creating hmac key")
+    return nil, nil, err
+  }
+
+  var lock sync.Mutex
+  locationsByHash := make(map[string][]DuplicateLocation)
+
+  secretErrors, err := forEachKvSecret(client, mountNames, func(key string) error {
+    secret, err := NewSecret(key, "", "", nil, *client)
+    if err != nil {
+      return err
+    }
+
+    data, _, err := secret.ReadCurrentData(client)
+    if err != nil {
+      return err
+    }
+
+    walkLeaves(data, "", func(field string, name string, value interface{}) {
+      text := searchText(value)
+      if len(text) < minLength {
+        return
+      }
+
+      hash := valueHmac(hmacKey, text)
+      lock.Lock()
+      locationsByHash[hash] = append(locationsByHash[hash], DuplicateLocation{
+        Key: key,
+        Field: field,
+      })
+      lock.Unlock()
+    })
+    return nil
+  })
+  if err != nil {
+    return nil, nil, err
+  }
+
+  return duplicateGroups(locationsByHash), secretErrors, nil
+}
+
+/*
+This will get the groups of locations that share a hash,
+the largest groups are first
+*/
+func duplicateGroups(locationsByHash map[string][]DuplicateLocation) []DuplicateGroup {
+  var groups []DuplicateGroup
+
+  for hash, locations := range locationsByHash {
+    if len(locations) < 2 {
+      continue
+    }
+
+    sort.Slice(locations, func(i, j int) bool {
+      if locations[i].Key != locations[j].Key {
+        return locations[i].Key < locations[j].Key
+      }
+      return locations[i].Field < locations[j].Field
+    })
+    groups = append(groups, DuplicateGroup{
+      Id: hash[:12],
+      Locations: locations,
+    })
+  }
+
+  sort.Slice(groups, func(i, j int) bool {
+    if len(groups[i].Locations) != len(groups[j].Locations) {
+      return len(groups[i].Locations) > len(groups[j].Locations)
+    }
+    if groups[i].Locations[0].Key != groups[j].Locations[0].Key {
+      return groups[i].Locations[0].Key < groups[j].Locations[0].Key
+    }
+    return groups[i].Id < groups[j].Id
+  })
+  return groups
+}
+
+/*
+This will hash a value with the hmac key
+*/
+func valueHmac(key []byte, value string) string {
+  mac := hmac.New(sha256.New, key)
+  mac.Write([]byte(value))
+  return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for duplicate detection
+*/
+func TestValueHmac(t *testing.T) {
+  key := []byte("0123456789abcdef0123456789abcdef")
+
+  assert.Equal(t, valueHmac(key, "hunter22"), valueHmac(key, "hunter22"))
+  assert.NotEqual(t, valueHmac(key, "hunter22"), valueHmac(key, "hunter23"))
+  assert.NotEqual(t, valueHmac(key, "hunter22"), valueHmac([]byte("other key"), "hunter22"))
+  assert.NotContains(t, valueHmac(key, "hunter22"), "hunter22")
+}
+
+func TestDuplicateGroups(t *testing.T) {
+  locationsByHash := map[string][]DuplicateLocation{
+    "aaaaaaaaaaaaaaaa": {
+      {Key: "secret/b", Field: "password"},
+      {Key: "secret/a", Field: "password"},
+    },
+    "bbbbbbbbbbbbbbbb": {
+      {Key: "secret/c", Field: "token"},
+    },
+    "cccccccccccccccc": {
+      {Key: "secret/d", Field: "key"},
+      {Key: "secret/c", Field: "api.key"},
+      {Key: "secret/c", Field: "api.backup"},
+    },
+  }
+
+  groups := duplicateGroups(locationsByHash)
+  assert.Len(t, groups, 2)
+
+  assert.Equal(t, "cccccccccccc", groups[0].Id)
+  assert.Equal(t, []DuplicateLocation{
+    {Key: "secret/c", Field: "api.backup"},
+    {Key: "secret/c", Field: "api.key"},
+    {Key: "secret/d", Field: "key"},
+  }, groups[0].Locations)
+
+  assert.Equal(t, "aaaaaaaaaaaa", groups[1].Id)
+  assert.Equal(t, "secret/a", groups[1].Locations[0].Key)
+}
+
+func TestFindDuplicates(t *testing.T) {
+  fake, client := newFakeVault(t, map[string]string{"secret/": "2", "legacy/": "1"})
+
+  fake.put("secret/app/db", map[string]interface{}{"password": "hunter22", "user": "app"})
+  fake.put("secret/app/cache", map[string]interface{}{"password": "hunter22", "user": "app"})
+  fake.put("legacy/app/db", map[string]interface{}{"password": "hunter22"})
+
+  groups, secretErrors, err := FindDuplicates(client, nil, 4)
+  assert.NoError(t, err)
+  assert.Empty(t, secretErrors)
+  assert.Len(t, groups, 1)
+  assert.Equal(t, []DuplicateLocation{
+    {Key: "legacy/app/db", Field: "password"},
+    {Key: "secret/app/cache", Field: "password"},
+    {Key: "secret/app/db", Field: "password"},
+  }, groups[0].Locations)
+
+  groups, _, err = FindDuplicates(client, []string{"secret"}, 3)
+  assert.NoError(t, err)
+  assert.Len(t, groups, 2)
+}
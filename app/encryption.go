@@ -0,0 +1,595 @@
+package app
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/dgutierrez1287/vault-util/logger"
+)
+
+// version of the secrets file encryption format
+const EncryptionFormatVersion = 1
+
+// prefix and suffix of an encrypted value
+const encryptedValuePrefix = "ENC[AES256_GCM,"
+const encryptedValueSuffix = "]"
+
+/*
+The age identity file used to decrypt secrets files,
+this is set from the command line or the
+VAULT_UTIL_AGE_IDENTITY_FILE environment variable
+*/
+var AgeIdentityFile = os.Getenv("VAULT_UTIL_AGE_IDENTITY_FILE")
+
+/*
+EncryptionMetadata - how the values in a secrets
+file are encrypted, the values are encrypted with a
+data key and the data key is encrypted to age
+recipients and/or a vault transit key, the mac covers
+every secret name, vault key, field and encrypted value
+so entries cannot be moved, added or removed
+*/
+type EncryptionMetadata struct {
+  Version int                     `json:"version"`
+  LastModified time.Time          `json:"lastModified"`
+  MAC string                      `json:"mac,omitempty"`
+  Age *AgeDataKey                 `json:"age,omitempty"`
+  Transit *TransitDataKey         `json:"transit,omitempty"`
+}
+
+/*
+AgeDataKey - the data key encrypted to
+age recipients
+*/
+type AgeDataKey struct {
+  Recipients []string             `json:"recipients"`
+  EncryptedKey string             `json:"encryptedKey"`
+}
+
+/*
+TransitDataKey - the data key encrypted with
+a vault transit key
+*/
+type TransitDataKey struct {
+  Key string                      `json:"key"`
+  EncryptedKey string             `json:"encryptedKey"`
+}
+
+/*
+EncryptionOptions - options for encrypting
+a secrets file
+*/
+type EncryptionOptions struct {
+  AgeRecipients []string
+  TransitKey string
+}
+
+/*
+This will get the encryption options that were used for
+a secrets file so it can be encrypted again the same way
+*/
+func (m EncryptionMetadata) Options() EncryptionOptions {
+  var opts EncryptionOptions
+
+  if m.Age != nil {
+    opts.AgeRecipients = m.Age.Recipients
+  }
+  if m.Transit != nil {
+    opts.TransitKey = m.Transit.Key
+  }
+  return opts
+}
+
+/*
+Checks if any encryption is set in the options
+*/
+func (o EncryptionOptions) Enabled() bool {
+  return len(o.AgeRecipients) > 0 || o.TransitKey != ""
+}
+
+/*
+This will encrypt every value in the secrets, secret names,
+vault keys and field names are left visible. The client is
+only needed when a transit key is used
+*/
+func (vs *VaultSecrets) Encrypt(opts EncryptionOptions, client *VaultClient) error {
+  metadata, dataKey, err := NewDataKey(opts, client)
+  if err != nil {
+    return err
+  }
+
+  for name, secret := range vs.Secrets {
+    data, err := transformValues(secret.SecretData, []string{name}, func(value interface{},
+      path []string) (interface{}, error) {
+      return encryptValue(dataKey, value, path)
+    })
+    if err != nil {
+      return fmt.Errorf("%s: %w", name, err)
+    }
+    secret.SecretData = data
+    vs.Secrets[name] = secret
+  }
+
+  metadata.MAC, err = secretsMAC(dataKey, vs.Secrets)
+  if err != nil {
+    return err
+  }
+
+  vs.Encryption = metadata
+  return nil
+}
+
+/*
+This will generate a random data key and encrypt it to the
+age recipients and/or transit key, the metadata holds the
+encrypted data key so it can be decrypted later
+*/
+func NewDataKey(opts EncryptionOptions, client *VaultClient) (*EncryptionMetadata,
+  []byte, error) {
+
+  if !opts.Enabled() {
+    logger.LogError("Error no age recipients or transit key set")
+    return nil, nil, errors.New("an age recipient or transit key is required to encrypt")
+  }
+
+  logger.LogDebug("Generating data key")
+  dataKey := make([]byte, 32)
+  _, err := rand.Read(dataKey)
+  if err != nil {
+    return nil, nil, err
+  }
+
+  metadata := &EncryptionMetadata{
+    Version: EncryptionFormatVersion,
+    LastModified: time.Now().UTC(),
+  }
+
+  if len(opts.AgeRecipients) > 0 {
+    logger.LogDebug("Encrypting data key to age recipients")
+    encryptedKey, err := ageEncryptKey(dataKey, opts.AgeRecipients)
+    if err != nil {
+      logger.LogError("Error encrypting data key with age")
+      return nil, nil, err
+    }
+    metadata.Age = &AgeDataKey{
+      Recipients: opts.AgeRecipients,
+      EncryptedKey: encryptedKey,
+    }
+  }
+
+  if opts.TransitKey != "" {
+    if client == nil {
+      return nil, nil, errors.New("a vault connection is required to use a transit key")
+    }
+
+    logger.LogDebug("Encrypting data key with transit key", "key", opts.TransitKey)
+    mount, keyName, err := splitTransitKey(opts.TransitKey)
+    if err != nil {
+      return nil, nil, err
+    }
+    encryptedKey, err := client.TransitEncrypt(mount, keyName, dataKey)
+    if err != nil {
+      logger.LogError("Error encrypting data key with transit")
+      return nil, nil, err
+    }
+    metadata.Transit = &TransitDataKey{
+      Key: opts.TransitKey,
+      EncryptedKey: encryptedKey,
+    }
+  }
+  return metadata, dataKey, nil
+}
+
+/*
+This will decrypt the data key in the metadata
+*/
+func (m EncryptionMetadata) DataKey(client *VaultClient) ([]byte, error) {
+  if m.Version != EncryptionFormatVersion {
+    logger.LogError("Error unsupported encryption format version")
+    return nil, fmt.Errorf("unsupported encryption format version %d", m.Version)
+  }
+  return m.dataKey(client)
+}
+
+/*
+This will decrypt every value in the secrets if the secrets
+are encrypted, the data key is decrypted with the age identity
+file if there is one otherwise with the transit key. The mac is
+checked before anything is decrypted
+*/
+func (vs *VaultSecrets) Decrypt(client *VaultClient) error {
+  if vs.Encryption == nil {
+    logger.LogDebug("Secrets are not encrypted")
+    return nil
+  }
+
+  if vs.Encryption.Version != EncryptionFormatVersion {
+    logger.LogError("Error unsupported encryption format version")
+    return fmt.Errorf("unsupported encryption format version %d", vs.Encryption.Version)
+  }
+
+  dataKey, err := vs.Encryption.dataKey(client)
+  if err != nil {
+    logger.LogError("Error decrypting the data key")
+    return err
+  }
+
+  err = vs.verifyMAC(dataKey)
+  if err != nil {
+    logger.LogError("Error the secrets file mac does not match")
+    return err
+  }
+
+  for name, secret := range vs.Secrets {
+    data, err := transformValues(secret.SecretData, []string{name}, func(value interface{},
+      path []string) (interface{}, error) {
+      return decryptValue(dataKey, value, path)
+    })
+    if err != nil {
+      return fmt.Errorf("%s: %w", name, err)
+    }
+    secret.SecretData = data
+    vs.Secrets[name] = secret
+  }
+
+  vs.Encryption = nil
+  return nil
+}
+
+/*
+This will decrypt the data key, age is tried first if
+there is an identity file
+*/
+func (m EncryptionMetadata) dataKey(client *VaultClient) ([]byte, error) {
+  var errs []string
+
+  if m.Age != nil && AgeIdentityFile != "" {
+    logger.LogDebug("Decrypting data key with age identity", "file", AgeIdentityFile)
+    dataKey, err := ageDecryptKey(m.Age.EncryptedKey, AgeIdentityFile)
+    if err == nil {
+      return dataKey, nil
+    }
+    errs = append(errs, fmt.Sprintf("age: %s", err))
+  }
+
+  if m.Transit != nil && client != nil {
+    logger.LogDebug("Decrypting data key with transit key", "key", m.Transit.Key)
+    mount, keyName, err := splitTransitKey(m.Transit.Key)
+    if err != nil {
+      return nil, err
+    }
+    dataKey, err := client.TransitDecrypt(mount, keyName, m.Transit.EncryptedKey)
+    if err == nil {
+      return dataKey, nil
+    }
+    errs = append(errs, fmt.Sprintf("transit: %s", err))
+  }
+
+  if len(errs) == 0 {
+    return nil, errors.New("no age identity file or vault connection to decrypt the data key")
+  }
+  return nil, fmt.Errorf("cannot decrypt data key: %s", strings.Join(errs, "; "))
+}
+
+/*
+This will encrypt the data key to age recipients and
+return it armored
+*/
+func ageEncryptKey(dataKey []byte, recipients []string) (string, error) {
+  var ageRecipients []age.Recipient
+  for _, recipient := range recipients {
+    parsed, err := age.ParseX25519Recipient(recipient)
+    if err != nil {
+      return "", fmt.Errorf("invalid age recipient %s: %w", recipient, err)
+    }
+    ageRecipients = append(ageRecipients, parsed)
+  }
+
+  var out bytes.Buffer
+  armorWriter := armor.NewWriter(&out)
+  writer, err := age.Encrypt(armorWriter, ageRecipients...)
+  if err != nil {
+    return "", err
+  }
+  if _, err := writer.Write(dataKey); err != nil {
+    return "", err
+  }
+  if err := writer.Close(); err != nil {
+    return "", err
+  }
+  if err := armorWriter.Close(); err != nil {
+    return "", err
+  }
+  return out.String(), nil
+}
+
+/*
+This will decrypt the armored data key with the
+identities in an age identity file
+*/
+func ageDecryptKey(encryptedKey string, identityFile string) ([]byte, error) {
+  file, err := os.Open(identityFile)
+  if err != nil {
+    return nil, err
+  }
+  defer file.Close()
+
+  identities, err := age.ParseIdentities(file)
+  if err != nil {
+    return nil, err
+  }
+
+  reader, err := age.Decrypt(armor.NewReader(strings.NewReader(encryptedKey)), identities...)
+  if err != nil {
+    return nil, err
+  }
+  return io.ReadAll(reader)
+}
+
+/*
+This will split a transit key in the form mount/key
+*/
+func splitTransitKey(transitKey string) (string, string, error) {
+  index := strings.LastIndex(transitKey, "/")
+  if index < 1 || index == len(transitKey)-1 {
+    return "", "", fmt.Errorf("transit key %s must be in the form mount/key", transitKey)
+  }
+  return transitKey[:index], transitKey[index+1:], nil
+}
+
+/*
+This will apply a function to every leaf value in the secret
+data, the path of the value is passed so encrypted values are
+tied to where they are in the file
+*/
+func transformValues(data map[string]interface{}, path []string,
+  transform func(interface{}, []string) (interface{}, error)) (map[string]interface{}, error) {
+
+  if data == nil {
+    return nil, nil
+  }
+
+  result := make(map[string]interface{}, len(data))
+  for key, value := range data {
+    newValue, err := transformValue(value, appendPath(path, key), transform)
+    if err != nil {
+      return nil, err
+    }
+    result[key] = newValue
+  }
+  return result, nil
+}
+
+/*
+This will apply a function to a value or, for maps and
+arrays, every value inside it
+*/
+func transformValue(value interface{}, path []string,
+  transform func(interface{}, []string) (interface{}, error)) (interface{}, error) {
+
+  switch typed := value.(type) {
+  case map[string]interface{}:
+    return transformValues(typed, path, transform)
+  case []interface{}:
+    result := make([]interface{}, len(typed))
+    for i, item := range typed {
+      newItem, err := transformValue(item, appendPath(path, strconv.Itoa(i)), transform)
+      if err != nil {
+        return nil, err
+      }
+      result[i] = newItem
+    }
+    return result, nil
+  case nil:
+    return nil, nil
+  default:
+    return transform(value, path)
+  }
+}
+
+/*
+This will encode a path as a json array, names and keys can
+have any character in them so joining them with a separator
+would let two different paths encode the same
+*/
+func encodePath(path []string) []byte {
+  encoded, _ := json.Marshal(path)
+  return encoded
+}
+
+/*
+This will format a path for error messages
+*/
+func displayPath(path []string) string {
+  return strings.Join(path, ":")
+}
+
+/*
+This will encrypt a single value with the data key, the
+value is json encoded so its type is kept
+*/
+func encryptValue(dataKey []byte, value interface{}, path []string) (interface{}, error) {
+  plaintext, err := json.Marshal(value)
+  if err != nil {
+    return nil, err
+  }
+
+  gcm, err := newGCM(dataKey)
+  if err != nil {
+    return nil, err
+  }
+
+  nonce := make([]byte, gcm.NonceSize())
+  _, err = rand.Read(nonce)
+  if err != nil {
+    return nil, err
+  }
+
+  sealed := gcm.Seal(nonce, nonce, plaintext, encodePath(path))
+  return encryptedValuePrefix + base64.StdEncoding.EncodeToString(sealed) +
+    encryptedValueSuffix, nil
+}
+
+/*
+This will decrypt a single value with the data key, every
+value in an encrypted file has to be encrypted
+*/
+func decryptValue(dataKey []byte, value interface{}, path []string) (interface{}, error) {
+  text, ok := value.(string)
+  if !ok || !IsEncryptedValue(text) {
+    return nil, fmt.Errorf("value at %s is not encrypted", displayPath(path))
+  }
+
+  sealed, err := base64.StdEncoding.DecodeString(
+    strings.TrimSuffix(strings.TrimPrefix(text, encryptedValuePrefix), encryptedValueSuffix))
+  if err != nil {
+    return nil, fmt.Errorf("invalid encrypted value at %s", displayPath(path))
+  }
+
+  gcm, err := newGCM(dataKey)
+  if err != nil {
+    return nil, err
+  }
+
+  if len(sealed) < gcm.NonceSize() {
+    return nil, fmt.Errorf("invalid encrypted value at %s", displayPath(path))
+  }
+
+  plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():],
+    encodePath(path))
+  if err != nil {
+    return nil, fmt.Errorf("cannot decrypt value at %s", displayPath(path))
+  }
+
+  var decrypted interface{}
+  err = json.Unmarshal(plaintext, &decrypted)
+  if err != nil {
+    return nil, err
+  }
+  return decrypted, nil
+}
+
+/*
+This will check the mac in the metadata against the
+secrets, a file without a mac is rejected
+*/
+func (vs *VaultSecrets) verifyMAC(dataKey []byte) error {
+  if vs.Encryption.MAC == "" {
+    return errors.New("secrets file has no mac")
+  }
+
+  expected, err := secretsMAC(dataKey, vs.Secrets)
+  if err != nil {
+    return err
+  }
+
+  if !hmac.Equal([]byte(expected), []byte(vs.Encryption.MAC)) {
+    return errors.New("secrets file mac does not match, the file has been changed")
+  }
+  return nil
+}
+
+/*
+This will compute a mac over the secrets, every secret name,
+vault key, field path and value is added as a json encoded
+entry and the entries are sorted so map order does not matter. The mac key
+is derived from the data key
+*/
+func secretsMAC(dataKey []byte, secrets map[string]VaultSecret) (string, error) {
+  var entries []string
+
+  for name, secret := range secrets {
+    entry, err := json.Marshal([]interface{}{"key", name, secret.VaultKey})
+    if err != nil {
+      return "", err
+    }
+    entries = append(entries, string(entry))
+
+    for key, value := range secret.SecretData {
+      err = macEntries(value, []string{name, key}, &entries)
+      if err != nil {
+        return "", err
+      }
+    }
+  }
+  sort.Strings(entries)
+
+  keyHash := hmac.New(sha256.New, dataKey)
+  keyHash.Write([]byte("vault-util secrets file mac"))
+
+  mac := hmac.New(sha256.New, keyHash.Sum(nil))
+  for _, entry := range entries {
+    mac.Write([]byte(entry))
+    mac.Write([]byte("\n"))
+  }
+  return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+/*
+This will add the mac entries for a value, maps and arrays
+get an entry of their own so empty ones are covered too
+*/
+func macEntries(value interface{}, path []string, entries *[]string) error {
+  var entry []byte
+  var err error
+
+  switch typed := value.(type) {
+  case map[string]interface{}:
+    entry, err = json.Marshal([]interface{}{"map", path})
+    for key, item := range typed {
+      if err == nil {
+        err = macEntries(item, appendPath(path, key), entries)
+      }
+    }
+  case []interface{}:
+    entry, err = json.Marshal([]interface{}{"array", path, len(typed)})
+    for i, item := range typed {
+      if err == nil {
+        err = macEntries(item, appendPath(path, strconv.Itoa(i)), entries)
+      }
+    }
+  default:
+    entry, err = json.Marshal([]interface{}{"value", path, typed})
+  }
+  if err != nil {
+    return err
+  }
+
+  *entries = append(*entries, string(entry))
+  return nil
+}
+
+/*
+Checks if a value is an encrypted value
+*/
+func IsEncryptedValue(value string) bool {
+  return strings.HasPrefix(value, encryptedValuePrefix) &&
+    strings.HasSuffix(value, encryptedValueSuffix)
+}
+
+/*
+This will create an AES-GCM cipher for the data key
+*/
+func newGCM(dataKey []byte) (cipher.AEAD, error) {
+  block, err := aes.NewCipher(dataKey)
+  if err != nil {
+    return nil, err
+  }
+  return cipher.NewGCM(block)
+}
@@ -0,0 +1,191 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/dgutierrez1287/vault-util/util"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for secrets file encryption
+*/
+func TestEncryptDecryptAge(t *testing.T) {
+  identityFile := filepath.Join(util.MockHomeDir, "age-keys.txt")
+
+  err := util.MockHomeSetup()
+  assert.NoError(t, err)
+
+  identity, err := age.GenerateX25519Identity()
+  assert.NoError(t, err)
+
+  err = os.WriteFile(identityFile, []byte(identity.String()+"\n"), 0600)
+  assert.NoError(t, err)
+
+  previousIdentity := AgeIdentityFile
+  AgeIdentityFile = identityFile
+  defer func() {
+    AgeIdentityFile = previousIdentity
+  }()
+
+  secrets := VaultSecrets{
+    Secrets: map[string]VaultSecret{
+      "app": {
+        VaultKey: "secret/app",
+        SecretData: map[string]interface{}{
+          "password": "hunter2",
+          "port": float64(5432),
+          "nested": map[string]interface{}{"enabled": true},
+        },
+      },
+    },
+  }
+
+  err = secrets.Encrypt(EncryptionOptions{
+    AgeRecipients: []string{identity.Recipient().String()},
+  }, nil)
+  assert.NoError(t, err)
+  assert.NotNil(t, secrets.Encryption)
+  assert.True(t, IsEncryptedValue(secrets.Secrets["app"].SecretData["password"].(string)))
+  assert.Equal(t, "secret/app", secrets.Secrets["app"].VaultKey)
+
+  secretsFile := filepath.Join(util.MockHomeDir, "secrets.json")
+  err = WriteSecretsFile(secretsFile, secrets)
+  assert.NoError(t, err)
+
+  loaded, err := LoadSecretsFile(secretsFile)
+  assert.NoError(t, err)
+
+  err = loaded.Decrypt(nil)
+  assert.NoError(t, err)
+  assert.Nil(t, loaded.Encryption)
+  assert.Equal(t, "hunter2", loaded.Secrets["app"].SecretData["password"])
+  assert.Equal(t, float64(5432), loaded.Secrets["app"].SecretData["port"])
+  assert.Equal(t, true, loaded.Secrets["app"].SecretData["nested"].(map[string]interface{})["enabled"])
+
+  err = util.MockHomeCleanup()
+  assert.NoError(t, err)
+}
+
+func TestDecryptValueWrongPath(t *testing.T) {
+  dataKey := make([]byte, 32)
+
+  encrypted, err := encryptValue(dataKey, "value", []string{"app", "password"})
+  assert.NoError(t, err)
+
+  _, err = decryptValue(dataKey, encrypted, []string{"app", "other"})
+  assert.Error(t, err)
+
+  decrypted, err := decryptValue(dataKey, encrypted, []string{"app", "password"})
+  assert.NoError(t, err)
+  assert.Equal(t, "value", decrypted)
+}
+
+func TestDecryptChecksMAC(t *testing.T) {
+  identityFile := filepath.Join(util.MockHomeDir, "age-keys.txt")
+
+  err := util.MockHomeSetup()
+  assert.NoError(t, err)
+
+  identity, err := age.GenerateX25519Identity()
+  assert.NoError(t, err)
+
+  err = os.WriteFile(identityFile, []byte(identity.String()+"\n"), 0600)
+  assert.NoError(t, err)
+
+  previousIdentity := AgeIdentityFile
+  AgeIdentityFile = identityFile
+  defer func() {
+    AgeIdentityFile = previousIdentity
+  }()
+
+  encrypted := func() VaultSecrets {
+    secrets := VaultSecrets{
+      Secrets: map[string]VaultSecret{
+        "app": {
+          VaultKey: "secret/app",
+          SecretData: map[string]interface{}{"password": "hunter2"},
+        },
+        "db": {
+          VaultKey: "secret/db",
+          SecretData: map[string]interface{}{"password": "letmein"},
+        },
+      },
+    }
+    err := secrets.Encrypt(EncryptionOptions{
+      AgeRecipients: []string{identity.Recipient().String()},
+    }, nil)
+    assert.NoError(t, err)
+    assert.NotEmpty(t, secrets.Encryption.MAC)
+    return secrets
+  }
+
+  // moving an entry to another vault path
+  secrets := encrypted()
+  app := secrets.Secrets["app"]
+  app.VaultKey = "secret/other"
+  secrets.Secrets["app"] = app
+  assert.Error(t, secrets.Decrypt(nil))
+
+  // replacing a value with plaintext
+  secrets = encrypted()
+  secrets.Secrets["app"].SecretData["password"] = "plaintext"
+  assert.Error(t, secrets.Decrypt(nil))
+
+  // adding a field
+  secrets = encrypted()
+  secrets.Secrets["db"].SecretData["extra"] = secrets.Secrets["db"].SecretData["password"]
+  assert.Error(t, secrets.Decrypt(nil))
+
+  // removing a secret
+  secrets = encrypted()
+  delete(secrets.Secrets, "db")
+  assert.Error(t, secrets.Decrypt(nil))
+
+  // no mac
+  secrets = encrypted()
+  secrets.Encryption.MAC = ""
+  assert.Error(t, secrets.Decrypt(nil))
+
+  secrets = encrypted()
+  assert.NoError(t, secrets.Decrypt(nil))
+  assert.Equal(t, "hunter2", secrets.Secrets["app"].SecretData["password"])
+
+  err = util.MockHomeCleanup()
+  assert.NoError(t, err)
+}
+
+func TestEncodePathIsUnambiguous(t *testing.T) {
+  dataKey := make([]byte, 32)
+
+  assert.NotEqual(t, encodePath([]string{"a:b", "c"}), encodePath([]string{"a", "b:c"}))
+
+  encrypted, err := encryptValue(dataKey, "value", []string{"a:b", "c"})
+  assert.NoError(t, err)
+
+  _, err = decryptValue(dataKey, encrypted, []string{"a", "b:c"})
+  assert.Error(t, err)
+}
+
+func TestDecryptValueRejectsPlaintext(t *testing.T) {
+  dataKey := make([]byte, 32)
+
+  _, err := decryptValue(dataKey, "plaintext", []string{"app", "password"})
+  assert.Error(t, err)
+
+  _, err = decryptValue(dataKey, float64(1), []string{"app", "port"})
+  assert.Error(t, err)
+}
+
+func TestSplitTransitKey(t *testing.T) {
+  mount, key, err := splitTransitKey("transit/app-key")
+  assert.NoError(t, err)
+  assert.Equal(t, "transit", mount)
+  assert.Equal(t, "app-key", key)
+
+  _, _, err = splitTransitKey("app-key")
+  assert.Error(t, err)
+}
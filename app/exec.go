@@ -0,0 +1,203 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/dgutierrez1287/vault-util/logger"
+)
+
+// how long a child has to stop before it is killed on restart
+const execStopTimeout = 10 * time.Second
+
+/*
+ExecOptions - the secrets to put in the environment, how
+their fields are named and the command to run. When restart
+is set the secret versions are polled and the command is
+restarted with the new values when a kv v2 secret changes
+*/
+type ExecOptions struct {
+  SecretKeys []string
+  Rules MappingRules
+  Command []string
+  Restart bool
+  PollInterval time.Duration
+}
+
+/*
+This will read the secrets and map their fields to env
+variables, when a name is used twice the later secret wins
+*/
+func ReadSecretEnv(client *VaultClient, secrets []VaultSecret,
+  rules MappingRules) (map[string]string, error) {
+
+  fields, err := ReadMappedFields(client, secrets, rules)
+  if err != nil {
+    return nil, err
+  }
+
+  env := make(map[string]string)
+  for _, field := range fields {
+    env[field.Name] = field.Text()
+  }
+  return env, nil
+}
+
+/*
+This will run a command with secrets in its environment, the
+environment of vault-util is passed on with the secrets added.
+Signals are forwarded to the command and its exit code is
+returned once it stops
+*/
+func RunExec(client *VaultClient, opts ExecOptions) (int, error) {
+  if len(opts.Command) == 0 {
+    return 0, errors.New("a command to run is required")
+  }
+
+  secrets, err := KvSecrets(client, opts.SecretKeys)
+  if err != nil {
+    return 0, err
+  }
+
+  var watcher *VersionWatcher
+  if opts.Restart {
+    if opts.PollInterval <= 0 {
+      return 0, errors.New("the poll interval must be more than 0")
+    }
+    watcher, err = NewVersionWatcher(client, secrets)
+    if err != nil {
+      return 0, err
+    }
+  }
+
+  env, err := ReadSecretEnv(client, secrets, opts.Rules)
+  if err != nil {
+    return 0, err
+  }
+
+  signals := make(chan os.Signal, 1)
+  signal.Notify(signals, forwardedSignals...)
+  defer signal.Stop(signals)
+
+  child, exited, err := startChild(opts.Command, env)
+  if err != nil {
+    return 0, err
+  }
+
+  var poll <-chan time.Time
+  if watcher != nil {
+    ticker := time.NewTicker(opts.PollInterval)
+    defer ticker.Stop()
+    poll = ticker.C
+  }
+
+  for {
+    select {
+    case sig := <-signals:
+      logger.LogDebug("Forwarding signal to command", "signal", sig)
+      child.Process.Signal(sig)
+
+    case err := <-exited:
+      return exitCode(err)
+
+    case <-poll:
+      changed, err := watcher.Changed()
+      if err != nil {
+        logger.LogError(fmt.Sprintf("Error checking secret versions, will check again: %s", err))
+        continue
+      }
+      if len(changed) == 0 {
+        continue
+      }
+
+      logger.LogInfo("Secrets changed, restarting command", "keys", changed)
+      newEnv, err := ReadSecretEnv(client, secrets, opts.Rules)
+      if err != nil {
+        logger.LogError(fmt.Sprintf("Error reading changed secrets, keeping the command running: %s", err))
+        continue
+      }
+
+      stopErr := stopChild(child, exited)
+      if stopErr != nil {
+        logger.LogDebug("Command stopped with an error", "error", stopErr)
+      }
+      env = newEnv
+      child, exited, err = startChild(opts.Command, env)
+      if err != nil {
+        return 0, err
+      }
+    }
+  }
+}
+
+/*
+This will start the command with the secrets in its env,
+the error it exits with is sent on the returned channel
+*/
+func startChild(command []string, env map[string]string) (*exec.Cmd, chan error, error) {
+  child := exec.Command(command[0], command[1:]...)
+  child.Stdin = os.Stdin
+  child.Stdout = os.Stdout
+  child.Stderr = os.Stderr
+  child.Env = os.Environ()
+  for name, value := range env {
+    child.Env = append(child.Env, name+"="+value)
+  }
+
+  logger.LogDebug("Starting command", "command", command[0])
+  err := child.Start()
+  if err != nil {
+    logger.LogError("Error starting the command")
+    return nil, nil, err
+  }
+
+  exited := make(chan error, 1)
+  go func() {
+    exited <- child.Wait()
+  }()
+  return child, exited, nil
+}
+
+/*
+This will ask the command to stop and kill it if it
+is still running after the stop timeout
+*/
+func stopChild(child *exec.Cmd, exited chan error) error {
+  logger.LogDebug("Stopping command", "pid", child.Process.Pid)
+  child.Process.Signal(syscall.SIGTERM)
+
+  select {
+  case err := <-exited:
+    return err
+  case <-time.After(execStopTimeout):
+    logger.LogDebug("Command did not stop, killing it", "pid", child.Process.Pid)
+    child.Process.Kill()
+    return <-exited
+  }
+}
+
+/*
+This will get the exit code of a command from the error
+it stopped with, a command killed by a signal exits with
+128 plus the signal the same way a shell reports it
+*/
+func exitCode(err error) (int, error) {
+  if err == nil {
+    return 0, nil
+  }
+
+  var exitErr *exec.ExitError
+  if !errors.As(err, &exitErr) {
+    return 0, err
+  }
+
+  if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+    return 128 + int(status.Signal()), nil
+  }
+  return exitErr.ExitCode(), nil
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package app
+
+import (
+	"os"
+	"syscall"
+)
+
+// the signals that are passed on to the child
+var forwardedSignals = []os.Signal{
+  syscall.SIGINT,
+  syscall.SIGTERM,
+  syscall.SIGHUP,
+  syscall.SIGQUIT,
+  syscall.SIGUSR1,
+  syscall.SIGUSR2,
+}
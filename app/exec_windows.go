@@ -0,0 +1,17 @@
+//go:build windows
+
+package app
+
+import (
+	"os"
+	"syscall"
+)
+
+// the signals that are passed on to the child, windows
+// has no user signals
+var forwardedSignals = []os.Signal{
+  syscall.SIGINT,
+  syscall.SIGTERM,
+  syscall.SIGHUP,
+  syscall.SIGQUIT,
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/dgutierrez1287/vault-util/logger"
+)
+
+/*
+This will read every secret under a folder in the secrets
+file format, the secret names are the keys relative to the
+folder so the file can be loaded back with bulk-load
+*/
+func ExportSecrets(client *VaultClient, folderKey string) (VaultSecrets,
+  []SecretActionError, error) {
+
+  return ExportSecretsWithCheckpoint(client, folderKey, nil)
+}
+
+/*
+This will export secrets like ExportSecrets, secrets the
+checkpoint has as done are skipped since they are already
+in the output file
+*/
+func ExportSecretsWithCheckpoint(client *VaultClient, folderKey string,
+  checkpoint *Checkpoint) (VaultSecrets, []SecretActionError, error) {
+
+  secrets := VaultSecrets{Secrets: make(map[string]VaultSecret)}
+  var secretErrors []SecretActionError
+
+  logger.LogDebug("Listing secrets to export", "folder", folderKey)
+  keys, err := listSubtree(client, folderKey)
+  if err != nil {
+    logger.LogError("Error listing secrets to export")
+    return secrets, secretErrors, err
+  }
+
+  names := make([]string, 0, len(keys))
+  for name := range keys {
+    if checkpoint != nil && checkpoint.IsDone(name) {
+      logger.LogDebug("Secret already exported, skipping", "name", name)
+      continue
+    }
+    names = append(names, name)
+  }
+
+  var lock sync.Mutex
+  results := RunBulk(names, client.Concurrency(), func(name string) error {
+    logger.LogDebug("Exporting secret", "key", keys[name])
+    secret, err := NewSecret(keys[name], "", "", nil, *client)
+    if err != nil {
+      return err
+    }
+
+    data, exists, err := secret.ReadCurrentData(client)
+    if err != nil {
+      return err
+    }
+    if !exists {
+      return errors.New("secret does not exist")
+    }
+
+    lock.Lock()
+    secrets.Secrets[name] = VaultSecret{
+      VaultKey: keys[name],
+      SecretData: data,
+    }
+    lock.Unlock()
+    return nil
+  })
+
+  _, failed := SplitBulkResults(results)
+  for _, failure := range failed {
+    logger.LogError("Error exporting secret")
+    secretErrors = append(secretErrors, SecretActionError{
+      VaultKey: keys[failure.Key],
+      Error: failure.Err,
+    })
+  }
+  return secrets, secretErrors, nil
+}
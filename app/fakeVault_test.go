@@ -0,0 +1,369 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+   A fake vault for tests, it has kv v1 and v2 mounts and
+   keeps every kv v2 version so deletes and history can be
+   checked. Keys are stored relative to their mount
+*/
+type fakeVersion struct {
+  Data map[string]interface{}
+  DeletionTime string
+  Destroyed bool
+}
+
+type fakeVault struct {
+  lock sync.Mutex
+  mounts map[string]string
+  v1 map[string]map[string]interface{}
+  v2 map[string][]*fakeVersion
+  failWrites map[string]bool
+}
+
+/*
+This will start a fake vault with mounts of name to kv
+version, the server is stopped when the test ends
+*/
+func newFakeVault(t *testing.T, mounts map[string]string) (*fakeVault, *VaultClient) {
+  fake := &fakeVault{
+    mounts: mounts,
+    v1: make(map[string]map[string]interface{}),
+    v2: make(map[string][]*fakeVersion),
+    failWrites: make(map[string]bool),
+  }
+
+  server := httptest.NewServer(http.HandlerFunc(fake.handle))
+  t.Cleanup(server.Close)
+
+  ctx := context.Background()
+  client, err := NewClient(VaultInstance{Url: server.URL, Token: "test"}, &ctx)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return fake, client
+}
+
+/*
+This will write a secret, a new version for kv v2
+*/
+func (f *fakeVault) put(key string, data map[string]interface{}) {
+  f.lock.Lock()
+  defer f.lock.Unlock()
+
+  mount, rel := f.split(key)
+  if f.mounts[mount] == "1" {
+    f.v1[mount+rel] = data
+    return
+  }
+  f.v2[mount+rel] = append(f.v2[mount+rel], &fakeVersion{Data: data})
+}
+
+/*
+This will make every write to a secret fail
+*/
+func (f *fakeVault) failWrite(key string) {
+  f.lock.Lock()
+  defer f.lock.Unlock()
+
+  mount, rel := f.split(key)
+  f.failWrites[mount+rel] = true
+}
+
+/*
+This will soft delete the current kv v2 version
+*/
+func (f *fakeVault) softDelete(key string) {
+  f.setDeletionTime(key, f.versionCount(key), time.Now().Add(-time.Second))
+}
+
+/*
+This will set the deletion time of a kv v2 version
+*/
+func (f *fakeVault) setDeletionTime(key string, version int, deletionTime time.Time) {
+  f.lock.Lock()
+  defer f.lock.Unlock()
+
+  mount, rel := f.split(key)
+  f.v2[mount+rel][version-1].DeletionTime = deletionTime.UTC().Format(time.RFC3339Nano)
+}
+
+/*
+This will get the current data of a secret, nil if it does
+not exist or the current kv v2 version is deleted
+*/
+func (f *fakeVault) current(key string) map[string]interface{} {
+  f.lock.Lock()
+  defer f.lock.Unlock()
+
+  mount, rel := f.split(key)
+  if f.mounts[mount] == "1" {
+    return f.v1[mount+rel]
+  }
+  versions := f.v2[mount+rel]
+  if len(versions) == 0 || !versionReadable(versions[len(versions)-1]) {
+    return nil
+  }
+  return versions[len(versions)-1].Data
+}
+
+/*
+This will get the number of kv v2 versions of a secret, 0
+if the metadata does not exist
+*/
+func (f *fakeVault) versionCount(key string) int {
+  f.lock.Lock()
+  defer f.lock.Unlock()
+
+  mount, rel := f.split(key)
+  return len(f.v2[mount+rel])
+}
+
+/*
+This will split a key into the mount and the key relative
+to it, kv v2 api paths with the data or metadata part are
+handled
+*/
+func (f *fakeVault) split(key string) (string, string) {
+  key = strings.TrimLeft(key, "/")
+  for mount, version := range f.mounts {
+    if !strings.HasPrefix(key, mount) {
+      continue
+    }
+    rel := strings.TrimLeft(strings.TrimPrefix(key, mount), "/")
+    if version == "2" {
+      rel = strings.TrimPrefix(rel, "data/")
+    }
+    return mount, rel
+  }
+  return "", key
+}
+
+func versionReadable(version *fakeVersion) bool {
+  if version.Destroyed {
+    return false
+  }
+  if version.DeletionTime == "" {
+    return true
+  }
+  deletionTime, _ := time.Parse(time.RFC3339Nano, version.DeletionTime)
+  return deletionTime.After(time.Now())
+}
+
+func (f *fakeVault) handle(w http.ResponseWriter, r *http.Request) {
+  f.lock.Lock()
+  defer f.lock.Unlock()
+
+  path := strings.TrimPrefix(r.URL.Path, "/v1/")
+  if path == "sys/mounts" {
+    mounts := make(map[string]interface{})
+    for mount, version := range f.mounts {
+      mounts[mount] = map[string]interface{}{
+        "type": "kv",
+        "description": "",
+        "options": map[string]interface{}{"version": version},
+      }
+    }
+    writeFakeResponse(w, http.StatusOK, mounts)
+    return
+  }
+
+  for mount, version := range f.mounts {
+    if !strings.HasPrefix(path, mount) {
+      continue
+    }
+    rest := strings.TrimLeft(strings.TrimPrefix(path, mount), "/")
+    list := r.URL.Query().Get("list") == "true"
+
+    if version == "1" {
+      _, rel := f.split(rest)
+      f.handleV1(w, r, mount, rel, list)
+      return
+    }
+
+    switch {
+    case strings.HasPrefix(rest, "data/"):
+      _, rel := f.split(strings.TrimPrefix(rest, "data/"))
+      f.handleV2Data(w, r, mount+rel)
+    case strings.HasPrefix(rest, "metadata/"):
+      _, rel := f.split(strings.TrimPrefix(rest, "metadata/"))
+      f.handleV2Metadata(w, r, mount, rel, list)
+    default:
+      writeFakeError(w, http.StatusNotFound, "unsupported path")
+    }
+    return
+  }
+  writeFakeError(w, http.StatusNotFound, "no handler for route")
+}
+
+func (f *fakeVault) handleV1(w http.ResponseWriter, r *http.Request, mount string,
+  rel string, list bool) {
+
+  key := mount + strings.TrimSuffix(rel, "/")
+  switch {
+  case list:
+    var keys []string
+    for existing := range f.v1 {
+      keys = append(keys, existing)
+    }
+    f.writeList(w, mount, rel, keys)
+  case r.Method == http.MethodGet:
+    data, ok := f.v1[key]
+    if !ok {
+      writeFakeError(w, http.StatusNotFound)
+      return
+    }
+    writeFakeResponse(w, http.StatusOK, data)
+  case r.Method == http.MethodPost || r.Method == http.MethodPut:
+    var data map[string]interface{}
+    json.NewDecoder(r.Body).Decode(&data)
+    f.v1[key] = data
+    w.WriteHeader(http.StatusNoContent)
+  case r.Method == http.MethodDelete:
+    delete(f.v1, key)
+    w.WriteHeader(http.StatusNoContent)
+  }
+}
+
+func (f *fakeVault) handleV2Data(w http.ResponseWriter, r *http.Request, key string) {
+  versions := f.v2[key]
+
+  switch r.Method {
+  case http.MethodGet:
+    number := len(versions)
+    if requested := r.URL.Query().Get("version"); requested != "" {
+      number, _ = strconv.Atoi(requested)
+    }
+    if number < 1 || number > len(versions) || !versionReadable(versions[number-1]) {
+      writeFakeError(w, http.StatusNotFound)
+      return
+    }
+    writeFakeResponse(w, http.StatusOK, map[string]interface{}{
+      "data": versions[number-1].Data,
+      "metadata": map[string]interface{}{"version": number},
+    })
+  case http.MethodPost, http.MethodPut:
+    var body struct {
+      Data map[string]interface{}     `json:"data"`
+      Options map[string]interface{}  `json:"options"`
+    }
+    json.NewDecoder(r.Body).Decode(&body)
+    if f.failWrites[key] {
+      writeFakeError(w, http.StatusBadRequest, "write failed")
+      return
+    }
+    if cas, ok := body.Options["cas"].(float64); ok && int(cas) != len(versions) {
+      writeFakeError(w, http.StatusBadRequest,
+        "check-and-set parameter did not match the current version")
+      return
+    }
+    f.v2[key] = append(versions, &fakeVersion{Data: body.Data})
+    writeFakeResponse(w, http.StatusOK, map[string]interface{}{"version": len(f.v2[key])})
+  case http.MethodDelete:
+    if len(versions) > 0 {
+      versions[len(versions)-1].DeletionTime = time.Now().Add(-time.Second).UTC().
+        Format(time.RFC3339Nano)
+    }
+    w.WriteHeader(http.StatusNoContent)
+  }
+}
+
+func (f *fakeVault) handleV2Metadata(w http.ResponseWriter, r *http.Request, mount string,
+  rel string, list bool) {
+
+  key := mount + strings.TrimSuffix(rel, "/")
+  versions := f.v2[key]
+
+  switch {
+  case list:
+    var keys []string
+    for existing := range f.v2 {
+      keys = append(keys, existing)
+    }
+    f.writeList(w, mount, rel, keys)
+  case r.Method == http.MethodGet:
+    if len(versions) == 0 {
+      writeFakeError(w, http.StatusNotFound)
+      return
+    }
+    versionInfo := make(map[string]interface{})
+    for i, version := range versions {
+      versionInfo[strconv.Itoa(i+1)] = map[string]interface{}{
+        "created_time": time.Now().UTC().Format(time.RFC3339Nano),
+        "deletion_time": version.DeletionTime,
+        "destroyed": version.Destroyed,
+      }
+    }
+    writeFakeResponse(w, http.StatusOK, map[string]interface{}{
+      "current_version": len(versions),
+      "versions": versionInfo,
+    })
+  case r.Method == http.MethodPost || r.Method == http.MethodPut:
+    w.WriteHeader(http.StatusNoContent)
+  case r.Method == http.MethodDelete:
+    delete(f.v2, key)
+    w.WriteHeader(http.StatusNoContent)
+  }
+}
+
+/*
+This will write the list of direct children of a folder,
+folders end with a slash like they do in vault
+*/
+func (f *fakeVault) writeList(w http.ResponseWriter, mount string, folder string,
+  existing []string) {
+
+  base := mount
+  if folder = strings.Trim(folder, "/"); folder != "" {
+    base = mount + folder + "/"
+  }
+
+  seen := make(map[string]bool)
+  var keys []string
+  for _, key := range existing {
+    if !strings.HasPrefix(key, base) {
+      continue
+    }
+    child := strings.TrimPrefix(key, base)
+    if index := strings.Index(child, "/"); index >= 0 {
+      child = child[:index+1]
+    }
+    if !seen[child] {
+      seen[child] = true
+      keys = append(keys, child)
+    }
+  }
+
+  if len(keys) == 0 {
+    writeFakeError(w, http.StatusNotFound)
+    return
+  }
+  sort.Strings(keys)
+  writeFakeResponse(w, http.StatusOK, map[string]interface{}{"keys": keys})
+}
+
+func writeFakeResponse(w http.ResponseWriter, status int, data interface{}) {
+  w.Header().Set("Content-Type", "application/json")
+  w.WriteHeader(status)
+  json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+func writeFakeError(w http.ResponseWriter, status int, errors ...string) {
+  w.Header().Set("Content-Type", "application/json")
+  w.WriteHeader(status)
+  if errors == nil {
+    errors = []string{}
+  }
+  json.NewEncoder(w).Encode(map[string]interface{}{"errors": errors})
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// the formats a single field value can be printed in
+const (
+  FieldFormatRaw = "raw"
+  FieldFormatBase64 = "base64"
+)
+
+/*
+FieldNotFoundError - the error returned when a
+field is not in the secret data
+*/
+type FieldNotFoundError struct {
+  Field string
+}
+
+func (e *FieldNotFoundError) Error() string {
+  return fmt.Sprintf("field %s not found in secret", e.Field)
+}
+
+/*
+This will get a field from secret data, the field can be a
+top level key, a dot path like db.hosts.0 or a JSONPath like
+$.db.hosts[0] or $['db']['password']. A top level key is tried
+first so keys like tls.crt are found as they are
+*/
+func ExtractField(data map[string]interface{}, field string) (interface{}, error) {
+  if value, ok := data[field]; ok && value != nil {
+    return value, nil
+  }
+
+  path, err := ParseFieldPath(field)
+  if err != nil {
+    return nil, err
+  }
+
+  value, ok := lookupPath(data, path)
+  if !ok {
+    return nil, &FieldNotFoundError{Field: field}
+  }
+  return value, nil
+}
+
+/*
+This will split a dot path or a JSONPath into its parts,
+only child names and array indexes are supported
+*/
+func ParseFieldPath(field string) ([]string, error) {
+  var parts []string
+
+  expr := strings.TrimSpace(field)
+  if strings.HasPrefix(expr, "$") {
+    expr = strings.TrimPrefix(expr[1:], ".")
+  }
+  if expr == "" {
+    return nil, fmt.Errorf("field %s is empty", field)
+  }
+
+  for i := 0; i < len(expr); {
+    switch expr[i] {
+    case '.':
+      i++
+      if i == len(expr) || expr[i] == '.' {
+        return nil, fmt.Errorf("field %s has an empty part", field)
+      }
+
+    case '[':
+      end := strings.IndexByte(expr[i:], ']')
+      if end < 0 {
+        return nil, fmt.Errorf("field %s has a [ without a ]", field)
+      }
+      part := expr[i+1 : i+end]
+      i += end + 1
+
+      if unquoted, err := unquoteFieldPart(part); err == nil {
+        parts = append(parts, unquoted)
+        continue
+      }
+      if _, err := strconv.Atoi(part); err != nil {
+        return nil, fmt.Errorf("field %s has a bad index %s, only names and numbers are supported", field, part)
+      }
+      parts = append(parts, part)
+
+    default:
+      end := strings.IndexAny(expr[i:], ".[")
+      if end < 0 {
+        end = len(expr) - i
+      }
+      parts = append(parts, expr[i:i+end])
+      i += end
+    }
+  }
+  return parts, nil
+}
+
+/*
+This will unquote a name in brackets, names can be
+in single or double quotes
+*/
+func unquoteFieldPart(part string) (string, error) {
+  if len(part) >= 2 && part[0] == '\'' && part[len(part)-1] == '\'' {
+    return strings.ReplaceAll(part[1:len(part)-1], `\'`, `'`), nil
+  }
+  if len(part) >= 2 && part[0] == '"' {
+    return strconv.Unquote(part)
+  }
+  return "", fmt.Errorf("%s is not quoted", part)
+}
+
+/*
+This will format a field value for printing, strings are
+printed as they are and other values as json. With base64
+the printed text is base64 encoded
+*/
+func FormatFieldValue(value interface{}, format string) (string, error) {
+  var text string
+  if str, ok := value.(string); ok {
+    text = str
+  } else {
+    jsonBytes, err := json.Marshal(value)
+    if err != nil {
+      return "", err
+    }
+    text = string(jsonBytes)
+  }
+
+  switch strings.ToLower(format) {
+  case "", FieldFormatRaw:
+    return text, nil
+  case FieldFormatBase64:
+    return base64.StdEncoding.EncodeToString([]byte(text)), nil
+  }
+  return "", fmt.Errorf("unknown field format %s, must be raw or base64", format)
+}
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for field extraction
+*/
+func testFieldData() map[string]interface{} {
+  return map[string]interface{}{
+    "password": "hunter2",
+    "tls.crt": "cert",
+    "db": map[string]interface{}{
+      "port": float64(5432),
+      "hosts": []interface{}{"a.example.com", "b.example.com"},
+      "user name": "admin",
+    },
+  }
+}
+
+func TestParseFieldPath(t *testing.T) {
+  path, err := ParseFieldPath("db.hosts.0")
+  assert.NoError(t, err)
+  assert.Equal(t, []string{"db", "hosts", "0"}, path)
+
+  path, err = ParseFieldPath("$.db.hosts[1]")
+  assert.NoError(t, err)
+  assert.Equal(t, []string{"db", "hosts", "1"}, path)
+
+  path, err = ParseFieldPath(`$['db']["user name"]`)
+  assert.NoError(t, err)
+  assert.Equal(t, []string{"db", "user name"}, path)
+
+  _, err = ParseFieldPath("db..port")
+  assert.Error(t, err)
+  _, err = ParseFieldPath("db[*]")
+  assert.Error(t, err)
+  _, err = ParseFieldPath("db[0")
+  assert.Error(t, err)
+  _, err = ParseFieldPath("$")
+  assert.Error(t, err)
+}
+
+func TestExtractField(t *testing.T) {
+  data := testFieldData()
+
+  value, err := ExtractField(data, "password")
+  assert.NoError(t, err)
+  assert.Equal(t, "hunter2", value)
+
+  value, err = ExtractField(data, "tls.crt")
+  assert.NoError(t, err)
+  assert.Equal(t, "cert", value)
+
+  value, err = ExtractField(data, "$.db.hosts[1]")
+  assert.NoError(t, err)
+  assert.Equal(t, "b.example.com", value)
+
+  _, err = ExtractField(data, "db.missing")
+  var notFound *FieldNotFoundError
+  assert.True(t, errors.As(err, &notFound))
+}
+
+func TestFormatFieldValue(t *testing.T) {
+  text, err := FormatFieldValue("hunter2", FieldFormatRaw)
+  assert.NoError(t, err)
+  assert.Equal(t, "hunter2", text)
+
+  text, err = FormatFieldValue("hunter2", FieldFormatBase64)
+  assert.NoError(t, err)
+  assert.Equal(t, "aHVudGVyMg==", text)
+
+  text, err = FormatFieldValue(map[string]interface{}{"port": float64(5432)}, FieldFormatRaw)
+  assert.NoError(t, err)
+  assert.Equal(t, `{"port":5432}`, text)
+
+  _, err = FormatFieldValue("hunter2", "hex")
+  assert.Error(t, err)
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/dgutierrez1287/vault-util/logger"
+)
+
+/*
+This will check if a name matches a glob pattern, a * will
+match anything but a / and a ** will match across folders
+*/
+func GlobMatch(pattern string, name string) bool {
+  matched, err := regexp.MatchString(GlobToRegexp(pattern, true), name)
+  if err != nil {
+    logger.LogDebug("Error matching glob pattern", "pattern", pattern, "error", err)
+    return false
+  }
+  return matched
+}
+
+/*
+This will convert a glob pattern to an anchored regular
+expression, when the pattern is for a path * and ? do not
+match a / otherwise they match anything
+*/
+func GlobToRegexp(pattern string, path bool) string {
+  var builder strings.Builder
+
+  many, single := ".*", "."
+  if path {
+    many, single = "[^/]*", "[^/]"
+  }
+
+  builder.WriteString("^")
+  for i := 0; i < len(pattern); i++ {
+    char := pattern[i]
+    switch {
+    case char == '*' && i+1 < len(pattern) && pattern[i+1] == '*':
+      builder.WriteString(".*")
+      i++
+    case char == '*':
+      builder.WriteString(many)
+    case char == '?':
+      builder.WriteString(single)
+    default:
+      builder.WriteString(regexp.QuoteMeta(string(char)))
+    }
+  }
+  builder.WriteString("$")
+  return builder.String()
+}
+
+/*
+This will check a secret key against include and exclude
+glob patterns, if there are no include patterns everything
+is included, excludes always win
+*/
+func MatchesFilters(key string, include []string, exclude []string) bool {
+  for _, pattern := range exclude {
+    if GlobMatch(pattern, key) {
+      logger.LogDebug("Key excluded by pattern", "key", key, "pattern", pattern)
+      return false
+    }
+  }
+
+  if len(include) == 0 {
+    return true
+  }
+
+  for _, pattern := range include {
+    if GlobMatch(pattern, key) {
+      return true
+    }
+  }
+  logger.LogDebug("Key not matched by any include pattern", "key", key)
+  return false
+}
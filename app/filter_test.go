@@ -0,0 +1,56 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for GlobMatch
+*/
+func TestGlobMatch(t *testing.T) {
+  assert.True(t, GlobMatch("db/*", "db/password"))
+  assert.False(t, GlobMatch("db/*", "db/prod/password"))
+  assert.True(t, GlobMatch("db/**", "db/prod/password"))
+  assert.True(t, GlobMatch("**/password", "db/prod/password"))
+  assert.True(t, GlobMatch("app?", "app1"))
+  assert.False(t, GlobMatch("app?", "app/"))
+  assert.True(t, GlobMatch("app.config", "app.config"))
+  assert.False(t, GlobMatch("app.config", "appxconfig"))
+}
+
+/*
+   Tests for MatchesFilters
+*/
+func TestMatchesFiltersNoPatterns(t *testing.T) {
+  assert.True(t, MatchesFilters("app/config", nil, nil))
+}
+
+func TestMatchesFiltersIncludeExclude(t *testing.T) {
+  include := []string{"app/**"}
+  exclude := []string{"app/tmp/*"}
+
+  assert.True(t, MatchesFilters("app/config", include, exclude))
+  assert.False(t, MatchesFilters("app/tmp/cache", include, exclude))
+  assert.False(t, MatchesFilters("db/config", include, exclude))
+}
+
+/*
+   Tests for key helpers
+*/
+func TestMountFromKey(t *testing.T) {
+  assert.Equal(t, "secret/", MountFromKey("secret/app/config"))
+  assert.Equal(t, "secret/", MountFromKey("/secret"))
+}
+
+func TestIsSecretKey(t *testing.T) {
+  assert.True(t, isSecretKey("secret/app"))
+  assert.False(t, isSecretKey("secret"))
+  assert.False(t, isSecretKey("secret/app/"))
+}
+
+func TestJoinKey(t *testing.T) {
+  assert.Equal(t, "secret/app/db", joinKey("secret/app/", "db"))
+  assert.Equal(t, "secret/app/db", joinKey("secret/app", "/db"))
+}
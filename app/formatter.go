@@ -0,0 +1,353 @@
+package app
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/yaml.v3"
+)
+
+// the output format used when none is picked
+const DefaultOutputFormat = "table"
+
+/*
+CommandResult - the result of a command, the output is what
+every format renders and the console function, when there is
+one, is the hand written table output. The console function
+always writes to stdout
+*/
+type CommandResult struct {
+  Output MachineOutput
+  Console func()
+}
+
+/*
+TabularOutput - output that can be shown as rows, it is
+used for csv and for table output without a console function
+*/
+type TabularOutput interface {
+  Rows() ([]string, [][]string)
+}
+
+/*
+EnvOutput - output that has its own data for env
+output, other output is flattened
+*/
+type EnvOutput interface {
+  EnvData() map[string]interface{}
+}
+
+/*
+FormatterOptions - options for making a formatter
+*/
+type FormatterOptions struct {
+  Template string
+}
+
+/*
+Formatter - renders a command result in an output format
+*/
+type Formatter interface {
+  Format(w io.Writer, result CommandResult) error
+}
+
+/*
+FormatterFunc - a function that is a formatter
+*/
+type FormatterFunc func(w io.Writer, result CommandResult) error
+
+func (f FormatterFunc) Format(w io.Writer, result CommandResult) error {
+  return f(w, result)
+}
+
+var formatters = map[string]func(opts FormatterOptions) (Formatter, error){
+  "table": func(opts FormatterOptions) (Formatter, error) { return FormatterFunc(formatTable), nil },
+  "json": func(opts FormatterOptions) (Formatter, error) { return FormatterFunc(formatJson), nil },
+  "yaml": func(opts FormatterOptions) (Formatter, error) { return FormatterFunc(formatYaml), nil },
+  "csv": func(opts FormatterOptions) (Formatter, error) { return FormatterFunc(formatCsv), nil },
+  "env": func(opts FormatterOptions) (Formatter, error) { return FormatterFunc(formatEnv), nil },
+  "template": newTemplateFormatter,
+}
+
+/*
+This will add a formatter to the registry, a formatter
+with the same name is replaced
+*/
+func RegisterFormatter(name string, newFormatter func(opts FormatterOptions) (Formatter, error)) {
+  formatters[strings.ToLower(name)] = newFormatter
+}
+
+/*
+This will get the names of every output format
+*/
+func FormatterNames() []string {
+  var names []string
+  for name := range formatters {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+  return names
+}
+
+/*
+This will make the formatter for an output format
+*/
+func NewFormatter(name string, opts FormatterOptions) (Formatter, error) {
+  newFormatter, ok := formatters[strings.ToLower(name)]
+  if !ok {
+    return nil, fmt.Errorf("unknown output format %s, must be one of %s",
+      name, strings.Join(FormatterNames(), ", "))
+  }
+  return newFormatter(opts)
+}
+
+/*
+This will render a result as a table, the console function
+is used when there is one
+*/
+func formatTable(w io.Writer, result CommandResult) error {
+  if result.Console != nil {
+    result.Console()
+    return nil
+  }
+
+  header, rows, err := outputRows(result.Output)
+  if err != nil {
+    return err
+  }
+
+  table := tablewriter.NewWriter(w)
+  table.SetHeader(header)
+  table.SetAlignment(tablewriter.ALIGN_LEFT)
+  table.SetAutoWrapText(false)
+  table.AppendBulk(rows)
+  table.Render()
+  return nil
+}
+
+/*
+This will render a result as json, it is the same
+json the machine output has always been
+*/
+func formatJson(w io.Writer, result CommandResult) error {
+  jsonBytes, err := json.Marshal(result.Output)
+  if err != nil {
+    return err
+  }
+  _, err = fmt.Fprintln(w, string(jsonBytes))
+  return err
+}
+
+/*
+This will render a result as yaml with the
+same names the json has
+*/
+func formatYaml(w io.Writer, result CommandResult) error {
+  data, err := outputData(result.Output)
+  if err != nil {
+    return err
+  }
+
+  yamlBytes, err := yaml.Marshal(data)
+  if err != nil {
+    return err
+  }
+  _, err = w.Write(yamlBytes)
+  return err
+}
+
+/*
+This will render a result as csv
+*/
+func formatCsv(w io.Writer, result CommandResult) error {
+  header, rows, err := outputRows(result.Output)
+  if err != nil {
+    return err
+  }
+
+  writer := csv.NewWriter(w)
+  err = writer.Write(header)
+  if err != nil {
+    return err
+  }
+  err = writer.WriteAll(rows)
+  if err != nil {
+    return err
+  }
+  return writer.Error()
+}
+
+/*
+This will render a result as shell env lines, the
+names are the upper case dotted field paths
+*/
+func formatEnv(w io.Writer, result CommandResult) error {
+  var data interface{}
+  var err error
+
+  if envOutput, ok := result.Output.(EnvOutput); ok {
+    data = envOutput.EnvData()
+  } else {
+    data, err = outputData(result.Output)
+    if err != nil {
+      return err
+    }
+  }
+
+  values := make(map[string]string)
+  walkLeaves(data, "", func(field string, name string, value interface{}) {
+    values[EnvName(field)] = searchText(value)
+  })
+
+  var names []string
+  for name := range values {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+
+  for _, name := range names {
+    _, err = fmt.Fprintf(w, "%s=%s\n", name, ShellQuote(values[name]))
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+/*
+This will make a formatter that runs a go template against
+the result, the template sees the same names the json has
+*/
+func newTemplateFormatter(opts FormatterOptions) (Formatter, error) {
+  if opts.Template == "" {
+    return nil, errors.New("a template is required for template output")
+  }
+
+  tmpl, err := template.New("output").Funcs(template.FuncMap{
+    "toJSON": toJSON,
+  }).Parse(opts.Template)
+  if err != nil {
+    return nil, err
+  }
+
+  return FormatterFunc(func(w io.Writer, result CommandResult) error {
+    data, err := outputData(result.Output)
+    if err != nil {
+      return err
+    }
+    return tmpl.Execute(w, data)
+  }), nil
+}
+
+/*
+This will get the rows of an output, output that is not
+tabular is flattened to a row for every field
+*/
+func outputRows(output MachineOutput) ([]string, [][]string, error) {
+  if tabular, ok := output.(TabularOutput); ok {
+    header, rows := tabular.Rows()
+    return header, rows, nil
+  }
+
+  data, err := outputData(output)
+  if err != nil {
+    return nil, nil, err
+  }
+
+  var rows [][]string
+  walkLeaves(data, "", func(field string, name string, value interface{}) {
+    rows = append(rows, []string{field, searchText(value)})
+  })
+  sort.Slice(rows, func(i, j int) bool { return rows[i][0] < rows[j][0] })
+  return []string{"field", "value"}, rows, nil
+}
+
+/*
+This will turn an output into plain maps and slices with
+the json names, whole numbers stay whole numbers
+*/
+func outputData(output MachineOutput) (interface{}, error) {
+  jsonBytes, err := json.Marshal(output)
+  if err != nil {
+    return nil, err
+  }
+
+  var data interface{}
+  decoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+  decoder.UseNumber()
+  err = decoder.Decode(&data)
+  if err != nil {
+    return nil, err
+  }
+  return plainNumbers(data), nil
+}
+
+/*
+This will replace json numbers with ints or floats
+*/
+func plainNumbers(value interface{}) interface{} {
+  switch typed := value.(type) {
+  case map[string]interface{}:
+    for key, item := range typed {
+      typed[key] = plainNumbers(item)
+    }
+  case []interface{}:
+    for i, item := range typed {
+      typed[i] = plainNumbers(item)
+    }
+  case json.Number:
+    if number, err := typed.Int64(); err == nil {
+      return number
+    }
+    if number, err := typed.Float64(); err == nil {
+      return number
+    }
+    return typed.String()
+  }
+  return value
+}
+
+/*
+This will make an env variable name from a field path
+*/
+func EnvName(field string) string {
+  return sanitizeEnvName(strings.ToUpper(field))
+}
+
+/*
+This will replace everything that cannot be in an env
+variable name with an underscore, names cannot start
+with a number
+*/
+func sanitizeEnvName(name string) string {
+  var builder strings.Builder
+  for _, char := range name {
+    if (char >= 'A' && char <= 'Z') || (char >= 'a' && char <= 'z') ||
+      (char >= '0' && char <= '9') || char == '_' {
+      builder.WriteRune(char)
+    } else {
+      builder.WriteRune('_')
+    }
+  }
+
+  sanitized := builder.String()
+  if sanitized != "" && sanitized[0] >= '0' && sanitized[0] <= '9' {
+    sanitized = "_" + sanitized
+  }
+  return sanitized
+}
+
+/*
+This will quote a value for a posix shell
+*/
+func ShellQuote(value string) string {
+  return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
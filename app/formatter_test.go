@@ -0,0 +1,93 @@
+package app
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for output formatters
+*/
+func formatOutput(t *testing.T, name string, opts FormatterOptions, result CommandResult) string {
+  formatter, err := NewFormatter(name, opts)
+  assert.NoError(t, err)
+
+  var buffer bytes.Buffer
+  assert.NoError(t, formatter.Format(&buffer, result))
+  return buffer.String()
+}
+
+func TestFormatJson(t *testing.T) {
+  output := SecretListOutput{Secrets: []string{"secret/a"}}
+  expected, _ := output.GetOutputJson()
+
+  assert.Equal(t, expected+"\n", formatOutput(t, "json", FormatterOptions{}, CommandResult{Output: output}))
+}
+
+func TestFormatYaml(t *testing.T) {
+  output := SecretListOutput{ExitCode: 0, Secrets: []string{"secret/a"}}
+
+  assert.Equal(t, "exitCode: 0\nsecrets:\n    - secret/a\n",
+    formatOutput(t, "yaml", FormatterOptions{}, CommandResult{Output: output}))
+}
+
+func TestFormatCsv(t *testing.T) {
+  output := SecretListOutput{Secrets: []string{"secret/a", "secret/b"}}
+  assert.Equal(t, "secret\nsecret/a\nsecret/b\n",
+    formatOutput(t, "csv", FormatterOptions{}, CommandResult{Output: output}))
+
+  // output that is not tabular is flattened
+  flat := AddRemoveOutput{ExitCode: 0, Message: "done"}
+  assert.Equal(t, "field,value\nexitCode,0\nmessage,done\n",
+    formatOutput(t, "csv", FormatterOptions{}, CommandResult{Output: flat}))
+}
+
+func TestFormatEnv(t *testing.T) {
+  output := GetSecretOutput{
+    SecretExists: true,
+    Data: map[string]interface{}{
+      "db-user": "admin",
+      "password": "it's",
+      "nested": map[string]interface{}{"port": float64(5432)},
+    },
+  }
+
+  assert.Equal(t, "DB_USER='admin'\nNESTED_PORT='5432'\nPASSWORD='it'\\''s'\n",
+    formatOutput(t, "env", FormatterOptions{}, CommandResult{Output: output}))
+}
+
+func TestFormatTemplate(t *testing.T) {
+  output := SecretListOutput{Secrets: []string{"secret/a", "secret/b"}}
+  opts := FormatterOptions{Template: "{{range .secrets}}{{.}};{{end}}"}
+
+  assert.Equal(t, "secret/a;secret/b;", formatOutput(t, "template", opts, CommandResult{Output: output}))
+
+  _, err := NewFormatter("template", FormatterOptions{})
+  assert.Error(t, err)
+  _, err = NewFormatter("template", FormatterOptions{Template: "{{"})
+  assert.Error(t, err)
+}
+
+func TestFormatTableConsole(t *testing.T) {
+  called := false
+  result := CommandResult{
+    Output: SecretListOutput{},
+    Console: func() { called = true },
+  }
+
+  formatOutput(t, "table", FormatterOptions{}, result)
+  assert.True(t, called)
+}
+
+func TestNewFormatterUnknown(t *testing.T) {
+  _, err := NewFormatter("xml", FormatterOptions{})
+  assert.Error(t, err)
+}
+
+func TestEnvName(t *testing.T) {
+  assert.Equal(t, "DB_USER", EnvName("db-user"))
+  assert.Equal(t, "API_KEYS_0", EnvName("api.keys.0"))
+  assert.Equal(t, "_1PASSWORD", EnvName("1password"))
+}
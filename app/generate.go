@@ -0,0 +1,296 @@
+package app
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/dgutierrez1287/vault-util/util"
+	"gopkg.in/yaml.v3"
+)
+
+// the artifacts generate can make
+const (
+  GenerateKubernetesSecret = "kubernetes-secret"
+  GenerateExternalSecret = "external-secret"
+  GenerateDockerEnv = "docker-env"
+  GenerateSystemdCredentials = "systemd-credentials"
+)
+
+// the api version of the ExternalSecret resource
+const ExternalSecretApiVersion = "external-secrets.io/v1beta1"
+
+// the names that can be kubernetes secret keys and credential names
+var keyNameRegexp = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+/*
+GenerateOptions - the names used in the generated artifacts,
+the credentials dir is only used for systemd credentials
+*/
+type GenerateOptions struct {
+  Name string
+  Namespace string
+  SecretStore string
+  SecretStoreKind string
+  RefreshInterval string
+  CredentialsDir string
+}
+
+/*
+kubernetesMetadata - the metadata of a kubernetes resource
+*/
+type kubernetesMetadata struct {
+  Name string                           `yaml:"name"`
+  Namespace string                      `yaml:"namespace,omitempty"`
+}
+
+/*
+kubernetesSecret - a kubernetes Secret manifest
+*/
+type kubernetesSecret struct {
+  ApiVersion string                     `yaml:"apiVersion"`
+  Kind string                           `yaml:"kind"`
+  Metadata kubernetesMetadata           `yaml:"metadata"`
+  Type string                           `yaml:"type"`
+  Data map[string]string                `yaml:"data"`
+}
+
+/*
+externalSecret - an external secrets operator ExternalSecret
+manifest that reads the fields from vault
+*/
+type externalSecret struct {
+  ApiVersion string                     `yaml:"apiVersion"`
+  Kind string                           `yaml:"kind"`
+  Metadata kubernetesMetadata           `yaml:"metadata"`
+  Spec externalSecretSpec               `yaml:"spec"`
+}
+
+type externalSecretSpec struct {
+  RefreshInterval string                `yaml:"refreshInterval,omitempty"`
+  SecretStoreRef externalSecretStoreRef `yaml:"secretStoreRef"`
+  Target externalSecretTarget           `yaml:"target"`
+  Data []externalSecretData             `yaml:"data"`
+}
+
+type externalSecretStoreRef struct {
+  Name string                           `yaml:"name"`
+  Kind string                           `yaml:"kind"`
+}
+
+type externalSecretTarget struct {
+  Name string                           `yaml:"name"`
+}
+
+type externalSecretData struct {
+  SecretKey string                      `yaml:"secretKey"`
+  RemoteRef externalSecretRemoteRef     `yaml:"remoteRef"`
+}
+
+type externalSecretRemoteRef struct {
+  Key string                            `yaml:"key"`
+  Property string                       `yaml:"property"`
+}
+
+/*
+This will get the names of the artifacts that can be generated
+*/
+func GenerateKinds() []string {
+  return []string{
+    GenerateDockerEnv,
+    GenerateExternalSecret,
+    GenerateKubernetesSecret,
+    GenerateSystemdCredentials,
+  }
+}
+
+/*
+This will make an artifact from mapped secret fields, every
+kind uses the same names for the fields. Systemd credentials
+are written to the credentials dir and the LoadCredential
+lines for the unit are returned
+*/
+func Generate(kind string, fields []MappedField, opts GenerateOptions) ([]byte, error) {
+  if len(fields) == 0 {
+    return nil, errors.New("the secrets have no fields to generate from")
+  }
+
+  switch kind {
+  case GenerateKubernetesSecret:
+    return KubernetesSecretManifest(fields, opts)
+  case GenerateExternalSecret:
+    return ExternalSecretManifest(fields, opts)
+  case GenerateDockerEnv:
+    return DockerEnvFile(fields)
+  case GenerateSystemdCredentials:
+    return WriteSystemdCredentials(fields, opts.CredentialsDir)
+  }
+  return nil, fmt.Errorf("unknown kind %s, must be one of %s", kind,
+    strings.Join(GenerateKinds(), ", "))
+}
+
+/*
+This will make a kubernetes Secret manifest with the
+field values base64 encoded in its data
+*/
+func KubernetesSecretManifest(fields []MappedField, opts GenerateOptions) ([]byte, error) {
+  if opts.Name == "" {
+    return nil, errors.New("a name is required for a kubernetes secret")
+  }
+
+  manifest := kubernetesSecret{
+    ApiVersion: "v1",
+    Kind: "Secret",
+    Metadata: kubernetesMetadata{Name: opts.Name, Namespace: opts.Namespace},
+    Type: "Opaque",
+    Data: make(map[string]string),
+  }
+  for _, field := range fields {
+    err := checkKubernetesKey(field.Name)
+    if err != nil {
+      return nil, err
+    }
+    manifest.Data[field.Name] = base64.StdEncoding.EncodeToString([]byte(field.Text()))
+  }
+  return marshalManifest(manifest)
+}
+
+/*
+This will make an ExternalSecret manifest that has the
+operator read every field from vault, the remote key is
+the full secret key so the secret store should not set
+a path
+*/
+func ExternalSecretManifest(fields []MappedField, opts GenerateOptions) ([]byte, error) {
+  if opts.Name == "" {
+    return nil, errors.New("a name is required for an external secret")
+  }
+  if opts.SecretStore == "" {
+    return nil, errors.New("a secret store is required for an external secret")
+  }
+
+  storeKind := opts.SecretStoreKind
+  if storeKind == "" {
+    storeKind = "SecretStore"
+  }
+
+  manifest := externalSecret{
+    ApiVersion: ExternalSecretApiVersion,
+    Kind: "ExternalSecret",
+    Metadata: kubernetesMetadata{Name: opts.Name, Namespace: opts.Namespace},
+    Spec: externalSecretSpec{
+      RefreshInterval: opts.RefreshInterval,
+      SecretStoreRef: externalSecretStoreRef{Name: opts.SecretStore, Kind: storeKind},
+      Target: externalSecretTarget{Name: opts.Name},
+    },
+  }
+  for _, field := range fields {
+    err := checkKubernetesKey(field.Name)
+    if err != nil {
+      return nil, err
+    }
+    manifest.Spec.Data = append(manifest.Spec.Data, externalSecretData{
+      SecretKey: field.Name,
+      RemoteRef: externalSecretRemoteRef{
+        Key: strings.Trim(field.SecretKey, "/"),
+        Property: field.Field,
+      },
+    })
+  }
+  return marshalManifest(manifest)
+}
+
+/*
+This will make a file for docker --env-file, docker uses
+the values as they are so values cannot be quoted and
+cannot have new lines
+*/
+func DockerEnvFile(fields []MappedField) ([]byte, error) {
+  var buffer bytes.Buffer
+  for _, field := range fields {
+    value := field.Text()
+    if strings.ContainsAny(value, "\r\n") {
+      return nil, fmt.Errorf("%s: field %s has new lines, docker env files cannot have them",
+        field.SecretKey, field.Field)
+    }
+    fmt.Fprintf(&buffer, "%s=%s\n", field.Name, value)
+  }
+  return buffer.Bytes(), nil
+}
+
+/*
+This will write a file for every field to a systemd credentials
+dir and return the LoadCredential lines for the unit. The dir
+and the files can only be read by the owner
+*/
+func WriteSystemdCredentials(fields []MappedField, credentialsDir string) ([]byte, error) {
+  if credentialsDir == "" {
+    return nil, errors.New("a credentials dir is required for systemd credentials")
+  }
+
+  absDir, err := filepath.Abs(credentialsDir)
+  if err != nil {
+    return nil, err
+  }
+
+  err = os.MkdirAll(absDir, 0700)
+  if err != nil {
+    logger.LogError("Error creating the credentials dir")
+    return nil, err
+  }
+
+  names := make([]string, 0, len(fields))
+  var buffer bytes.Buffer
+  for _, field := range fields {
+    if !keyNameRegexp.MatchString(field.Name) || field.Name == "." || field.Name == ".." {
+      return nil, fmt.Errorf("%s is not a valid credential name", field.Name)
+    }
+
+    credentialPath := filepath.Join(absDir, field.Name)
+    logger.LogDebug("Writing credential", "file", credentialPath)
+    err = util.WriteFileAtomic(credentialPath, []byte(field.Text()), 0400)
+    if err != nil {
+      logger.LogError("Error writing credential")
+      return nil, err
+    }
+    names = append(names, field.Name)
+  }
+
+  sort.Strings(names)
+  for _, name := range names {
+    fmt.Fprintf(&buffer, "LoadCredential=%s:%s\n", name, filepath.Join(absDir, name))
+  }
+  return buffer.Bytes(), nil
+}
+
+/*
+This will check a name can be a key in a kubernetes secret
+*/
+func checkKubernetesKey(name string) error {
+  if !keyNameRegexp.MatchString(name) {
+    return fmt.Errorf("%s is not a valid kubernetes secret key", name)
+  }
+  return nil
+}
+
+/*
+This will marshal a manifest to yaml with two space indents
+*/
+func marshalManifest(manifest interface{}) ([]byte, error) {
+  var buffer bytes.Buffer
+  encoder := yaml.NewEncoder(&buffer)
+  encoder.SetIndent(2)
+  err := encoder.Encode(manifest)
+  if err != nil {
+    return nil, err
+  }
+  err = encoder.Close()
+  return buffer.Bytes(), err
+}
@@ -0,0 +1,133 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for generating deployment files
+*/
+func generateFields() []MappedField {
+  rules := MappingRules{
+    Uppercase: true,
+    Mappings: []FieldMapping{{Field: "db.password", Name: "DB_PASSWORD"}},
+  }
+  return mergeMappedFields(rules.MapFields("kv/app/config", map[string]interface{}{
+    "db": map[string]interface{}{"password": "hunter2", "port": float64(5432)},
+    "token": "abc",
+  }))
+}
+
+func TestGenerateKubernetesSecret(t *testing.T) {
+  content, err := Generate(GenerateKubernetesSecret, generateFields(),
+    GenerateOptions{Name: "app", Namespace: "prod"})
+  assert.NoError(t, err)
+  assert.Equal(t, `apiVersion: v1
+kind: Secret
+metadata:
+  name: app
+  namespace: prod
+type: Opaque
+data:
+  DB_PASSWORD: aHVudGVyMg==
+  DB_PORT: NTQzMg==
+  TOKEN: YWJj
+`, string(content))
+
+  _, err = Generate(GenerateKubernetesSecret, generateFields(), GenerateOptions{})
+  assert.Error(t, err)
+}
+
+func TestGenerateExternalSecret(t *testing.T) {
+  content, err := Generate(GenerateExternalSecret, generateFields(), GenerateOptions{
+    Name: "app",
+    SecretStore: "vault",
+    RefreshInterval: "1h",
+  })
+  assert.NoError(t, err)
+  assert.Equal(t, `apiVersion: external-secrets.io/v1beta1
+kind: ExternalSecret
+metadata:
+  name: app
+spec:
+  refreshInterval: 1h
+  secretStoreRef:
+    name: vault
+    kind: SecretStore
+  target:
+    name: app
+  data:
+    - secretKey: DB_PASSWORD
+      remoteRef:
+        key: kv/app/config
+        property: db.password
+    - secretKey: DB_PORT
+      remoteRef:
+        key: kv/app/config
+        property: db.port
+    - secretKey: TOKEN
+      remoteRef:
+        key: kv/app/config
+        property: token
+`, string(content))
+
+  _, err = Generate(GenerateExternalSecret, generateFields(), GenerateOptions{Name: "app"})
+  assert.Error(t, err)
+}
+
+func TestGenerateDockerEnv(t *testing.T) {
+  content, err := Generate(GenerateDockerEnv, generateFields(), GenerateOptions{})
+  assert.NoError(t, err)
+  assert.Equal(t, "DB_PASSWORD=hunter2\nDB_PORT=5432\nTOKEN=abc\n", string(content))
+
+  fields := []MappedField{{SecretKey: "kv/app", Field: "cert", Name: "CERT", Value: "a\nb"}}
+  _, err = Generate(GenerateDockerEnv, fields, GenerateOptions{})
+  assert.Error(t, err)
+}
+
+func TestGenerateSystemdCredentials(t *testing.T) {
+  dir := filepath.Join(t.TempDir(), "credentials")
+  content, err := Generate(GenerateSystemdCredentials, generateFields(),
+    GenerateOptions{CredentialsDir: dir})
+  assert.NoError(t, err)
+  assert.Equal(t, "LoadCredential=DB_PASSWORD:"+filepath.Join(dir, "DB_PASSWORD")+"\n"+
+    "LoadCredential=DB_PORT:"+filepath.Join(dir, "DB_PORT")+"\n"+
+    "LoadCredential=TOKEN:"+filepath.Join(dir, "TOKEN")+"\n", string(content))
+
+  value, err := os.ReadFile(filepath.Join(dir, "DB_PASSWORD"))
+  assert.NoError(t, err)
+  assert.Equal(t, "hunter2", string(value))
+  info, err := os.Stat(filepath.Join(dir, "DB_PASSWORD"))
+  assert.NoError(t, err)
+  assert.Equal(t, os.FileMode(0400), info.Mode().Perm())
+
+  // credentials can be written again over read only files
+  _, err = Generate(GenerateSystemdCredentials, generateFields(), GenerateOptions{CredentialsDir: dir})
+  assert.NoError(t, err)
+
+  _, err = Generate(GenerateSystemdCredentials, generateFields(), GenerateOptions{})
+  assert.Error(t, err)
+}
+
+func TestGenerateUnknownKind(t *testing.T) {
+  _, err := Generate("helm", generateFields(), GenerateOptions{})
+  assert.Error(t, err)
+
+  _, err = Generate(GenerateDockerEnv, nil, GenerateOptions{})
+  assert.Error(t, err)
+}
+
+func TestMergeMappedFields(t *testing.T) {
+  merged := mergeMappedFields(
+    []MappedField{{SecretKey: "a", Name: "USER", Value: "one"}, {SecretKey: "a", Name: "HOST", Value: "h"}},
+    []MappedField{{SecretKey: "b", Name: "USER", Value: "two"}},
+  )
+  assert.Equal(t, []MappedField{
+    {SecretKey: "a", Name: "HOST", Value: "h"},
+    {SecretKey: "b", Name: "USER", Value: "two"},
+  }, merged)
+}
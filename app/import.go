@@ -0,0 +1,391 @@
+package app
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dgutierrez1287/vault-util/logger"
+	"gopkg.in/yaml.v3"
+)
+
+// the formats secrets can be imported from
+const (
+  ImportFormatAuto = "auto"
+  ImportFormatDotenv = "dotenv"
+  ImportFormatKubernetes = "kubernetes-secret"
+)
+
+// the placeholders a target key can have
+const (
+  ImportNamePlaceholder = "{name}"
+  ImportNamespacePlaceholder = "{namespace}"
+)
+
+// the names that can be keys in a dotenv file
+var dotenvKeyRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+/*
+ImportedSecret - a secret read from a source file, the name
+and namespace come from the manifest for kubernetes secrets
+and the name is the file name for dotenv files
+*/
+type ImportedSecret struct {
+  Source string
+  Name string
+  Namespace string
+  Data map[string]interface{}
+}
+
+/*
+kubernetesManifest - the parts of a kubernetes manifest
+that are needed to import a Secret, a List has the
+manifests in its items
+*/
+type kubernetesManifest struct {
+  Kind string                           `yaml:"kind"`
+  Metadata kubernetesMetadata           `yaml:"metadata"`
+  Data map[string]string                `yaml:"data"`
+  StringData map[string]string          `yaml:"stringData"`
+  Items []kubernetesManifest            `yaml:"items"`
+}
+
+/*
+This will get the formats that can be imported
+*/
+func ImportFormats() []string {
+  return []string{ImportFormatAuto, ImportFormatDotenv, ImportFormatKubernetes}
+}
+
+/*
+This will read the secrets in a source file, with the auto
+format yaml and json files are kubernetes manifests and
+every other file is a dotenv file
+*/
+func ReadImportSource(sourcePath string, format string) ([]ImportedSecret, error) {
+  data, err := os.ReadFile(sourcePath)
+  if err != nil {
+    logger.LogError("Error reading import file")
+    return nil, err
+  }
+
+  if format == "" || format == ImportFormatAuto {
+    format = ImportFormatDotenv
+    switch strings.ToLower(filepath.Ext(sourcePath)) {
+    case ".yaml", ".yml", ".json":
+      format = ImportFormatKubernetes
+    }
+    logger.LogDebug("Detected import format", "file", sourcePath, "format", format)
+  }
+
+  switch format {
+  case ImportFormatDotenv:
+    values, err := ParseDotenv(data)
+    if err != nil {
+      return nil, fmt.Errorf("%s: %w", sourcePath, err)
+    }
+    return []ImportedSecret{{
+      Source: sourcePath,
+      Name: dotenvName(sourcePath),
+      Data: values,
+    }}, nil
+
+  case ImportFormatKubernetes:
+    secrets, err := ParseKubernetesSecrets(data)
+    if err != nil {
+      return nil, fmt.Errorf("%s: %w", sourcePath, err)
+    }
+    for i := range secrets {
+      secrets[i].Source = sourcePath
+    }
+    return secrets, nil
+  }
+  return nil, fmt.Errorf("unknown import format %s, must be one of %s", format,
+    strings.Join(ImportFormats(), ", "))
+}
+
+/*
+This will get the name of a dotenv file for the target
+key, prod.env is prod and .env is env
+*/
+func dotenvName(sourcePath string) string {
+  base := filepath.Base(sourcePath)
+  name := strings.TrimSuffix(base, filepath.Ext(base))
+  if name == "" {
+    name = strings.TrimPrefix(base, ".")
+  }
+  return name
+}
+
+/*
+This will parse a dotenv file, lines are KEY=value with an
+optional export in front. Single quoted values are used as
+they are, double quoted values can have \n, \t, \" and \\
+escapes and both can go over more than one line. A # after
+a space starts a comment in values that are not quoted.
+Variables in values are not expanded. When a key is set
+twice the last value is used
+*/
+func ParseDotenv(data []byte) (map[string]interface{}, error) {
+  values := make(map[string]interface{})
+  lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+
+  for i := 0; i < len(lines); i++ {
+    lineNumber := i + 1
+    line := strings.TrimSpace(lines[i])
+    if line == "" || strings.HasPrefix(line, "#") {
+      continue
+    }
+
+    line = strings.TrimPrefix(line, "export ")
+    key, value, found := strings.Cut(line, "=")
+    key = strings.TrimSpace(key)
+    if !found {
+      return nil, fmt.Errorf("line %d: expected KEY=value", lineNumber)
+    }
+    if !dotenvKeyRegexp.MatchString(key) {
+      return nil, fmt.Errorf("line %d: %s is not a valid key", lineNumber, key)
+    }
+    trimmed := strings.TrimLeft(value, " \t")
+    if trimmed != value && strings.HasPrefix(trimmed, "#") {
+      // KEY= # comment has no value
+      trimmed = ""
+    }
+    value = trimmed
+
+    var parsed string
+    var err error
+    if strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'") {
+      parsed, i, err = parseDotenvQuoted(lines, i, value)
+      if err != nil {
+        return nil, fmt.Errorf("line %d: %s: %w", lineNumber, key, err)
+      }
+    } else {
+      if comment := strings.Index(value, " #"); comment != -1 {
+        value = value[:comment]
+      }
+      parsed = strings.TrimSpace(value)
+    }
+
+    if _, exists := values[key]; exists {
+      logger.LogDebug("Key is set more than once, using the last value", "key", key)
+    }
+    values[key] = parsed
+  }
+  return values, nil
+}
+
+/*
+This will parse a quoted dotenv value that starts on a line,
+the value can go on to later lines. The index of the line the
+value ends on is returned
+*/
+func parseDotenvQuoted(lines []string, lineIndex int, value string) (string, int, error) {
+  quote := value[0]
+  rest := value[1:]
+  var builder strings.Builder
+
+  for {
+    for j := 0; j < len(rest); j++ {
+      char := rest[j]
+      if char == quote {
+        trailing := strings.TrimSpace(rest[j+1:])
+        if trailing != "" && !strings.HasPrefix(trailing, "#") {
+          return "", lineIndex, fmt.Errorf("unexpected text after the closing quote")
+        }
+        return builder.String(), lineIndex, nil
+      }
+
+      if quote == '"' && char == '\\' && j+1 < len(rest) {
+        j++
+        switch rest[j] {
+        case 'n':
+          builder.WriteByte('\n')
+        case 'r':
+          builder.WriteByte('\r')
+        case 't':
+          builder.WriteByte('\t')
+        case '"', '\\', '$':
+          builder.WriteByte(rest[j])
+        default:
+          builder.WriteByte('\\')
+          builder.WriteByte(rest[j])
+        }
+        continue
+      }
+      builder.WriteByte(char)
+    }
+
+    // the value goes on to the next line
+    lineIndex++
+    if lineIndex >= len(lines) {
+      return "", lineIndex, errors.New("the quoted value is not closed")
+    }
+    builder.WriteByte('\n')
+    rest = lines[lineIndex]
+  }
+}
+
+/*
+This will parse kubernetes Secret manifests, a file can have
+more than one yaml document and List manifests. The base64
+data is decoded and stringData is used over data the same way
+kubernetes does. Manifests that are not Secrets are skipped
+*/
+func ParseKubernetesSecrets(data []byte) ([]ImportedSecret, error) {
+  var secrets []ImportedSecret
+  decoder := yaml.NewDecoder(bytes.NewReader(data))
+
+  for {
+    var manifest kubernetesManifest
+    err := decoder.Decode(&manifest)
+    if errors.Is(err, io.EOF) {
+      break
+    }
+    if err != nil {
+      logger.LogError("Error parsing kubernetes manifest")
+      return nil, err
+    }
+
+    found, err := kubernetesSecrets(manifest)
+    if err != nil {
+      return nil, err
+    }
+    secrets = append(secrets, found...)
+  }
+
+  if len(secrets) == 0 {
+    return nil, errors.New("no kubernetes Secret manifests were found")
+  }
+  return secrets, nil
+}
+
+/*
+This will get the secrets in a manifest
+*/
+func kubernetesSecrets(manifest kubernetesManifest) ([]ImportedSecret, error) {
+  switch manifest.Kind {
+  case "Secret":
+  case "List":
+    var secrets []ImportedSecret
+    for _, item := range manifest.Items {
+      found, err := kubernetesSecrets(item)
+      if err != nil {
+        return nil, err
+      }
+      secrets = append(secrets, found...)
+    }
+    return secrets, nil
+  default:
+    logger.LogDebug("Skipping manifest that is not a Secret", "kind", manifest.Kind,
+      "name", manifest.Metadata.Name)
+    return nil, nil
+  }
+
+  name := manifest.Metadata.Name
+  if name == "" {
+    return nil, errors.New("a Secret manifest has no name")
+  }
+
+  values := make(map[string]interface{})
+  for key, encoded := range manifest.Data {
+    decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+    if err != nil {
+      return nil, fmt.Errorf("secret %s: data %s is not valid base64", name, key)
+    }
+    if !utf8.Valid(decoded) {
+      return nil, fmt.Errorf("secret %s: data %s is binary, only text values can be imported", name, key)
+    }
+    values[key] = string(decoded)
+  }
+  for key, value := range manifest.StringData {
+    values[key] = value
+  }
+
+  return []ImportedSecret{{
+    Name: name,
+    Namespace: manifest.Metadata.Namespace,
+    Data: values,
+  }}, nil
+}
+
+/*
+This will get the vault key a secret is imported to, the
+target key can have {name} and {namespace} in it. Secrets
+without a namespace are in the default namespace
+*/
+func (s ImportedSecret) TargetKey(targetKey string) string {
+  namespace := s.Namespace
+  if namespace == "" {
+    namespace = "default"
+  }
+  key := strings.ReplaceAll(targetKey, ImportNamePlaceholder, s.Name)
+  key = strings.ReplaceAll(key, ImportNamespacePlaceholder, namespace)
+  return strings.Trim(key, "/")
+}
+
+/*
+This will make the secrets to write from imported secrets,
+they are named by their target key. No two secrets can go
+to the same key so the target key needs {name} when there
+is more than one secret
+*/
+func ImportToVaultSecrets(imported []ImportedSecret, targetKey string) (VaultSecrets, error) {
+  secrets := VaultSecrets{Secrets: make(map[string]VaultSecret)}
+
+  if strings.Trim(targetKey, "/") == "" {
+    return secrets, errors.New("a target key is required")
+  }
+
+  sources := make(map[string]ImportedSecret)
+  for _, secret := range imported {
+    key := secret.TargetKey(targetKey)
+    if existing, ok := sources[key]; ok {
+      return secrets, fmt.Errorf("%s and %s would both be imported to %s, use %s or %s in the target key",
+        importLabel(existing), importLabel(secret), key, ImportNamePlaceholder, ImportNamespacePlaceholder)
+    }
+    if len(secret.Data) == 0 {
+      return secrets, fmt.Errorf("%s has no values to import", importLabel(secret))
+    }
+    sources[key] = secret
+
+    secrets.Secrets[key] = VaultSecret{
+      VaultKey: key,
+      SecretData: secret.Data,
+    }
+  }
+  return secrets, nil
+}
+
+/*
+This will get a label for an imported secret for errors
+*/
+func importLabel(secret ImportedSecret) string {
+  if secret.Namespace != "" {
+    return fmt.Sprintf("%s (%s/%s)", secret.Source, secret.Namespace, secret.Name)
+  }
+  return fmt.Sprintf("%s (%s)", secret.Source, secret.Name)
+}
+
+/*
+This will check imported secrets against vault the same way
+a secrets file is checked before it is loaded, the secret
+details are filled in
+*/
+func CheckImportSecrets(secrets *VaultSecrets, client *VaultClient, sources []string) error {
+  issues := secrets.preflight(client, fileLocations{offsets: make(map[string]int)})
+  if len(issues) > 0 {
+    logger.LogError("Error imported secrets failed the pre-flight checks")
+    sort.Strings(sources)
+    return &ValidationError{SecretsFile: strings.Join(sources, ", "), Issues: issues}
+  }
+  return nil
+}
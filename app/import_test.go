@@ -0,0 +1,148 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for importing secrets
+*/
+func TestParseDotenv(t *testing.T) {
+  values, err := ParseDotenv([]byte(`# database settings
+DB_USER=admin
+export DB_PASSWORD = "p@ss \"word\"\n"
+DB_HOST=db.local # the host
+DB_URL=postgres://db.local/app#main
+EMPTY=
+COMMENTED= # nothing here
+SINGLE='$HOME stays \n'
+CERT="-----BEGIN-----
+abc
+-----END-----"
+DB_USER=root
+`))
+  assert.NoError(t, err)
+  assert.Equal(t, map[string]interface{}{
+    "DB_USER": "root",
+    "DB_PASSWORD": "p@ss \"word\"\n",
+    "DB_HOST": "db.local",
+    "DB_URL": "postgres://db.local/app#main",
+    "EMPTY": "",
+    "COMMENTED": "",
+    "SINGLE": `$HOME stays \n`,
+    "CERT": "-----BEGIN-----\nabc\n-----END-----",
+  }, values)
+
+  bad := []string{
+    "NO_EQUALS",
+    "1KEY=value",
+    "BAD KEY=value",
+    `OPEN="not closed`,
+    `TRAILING="value" extra`,
+  }
+  for _, data := range bad {
+    _, err = ParseDotenv([]byte(data))
+    assert.Error(t, err, data)
+  }
+}
+
+func TestParseKubernetesSecrets(t *testing.T) {
+  secrets, err := ParseKubernetesSecrets([]byte(`apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  namespace: prod
+type: Opaque
+data:
+  password: aHVudGVyMg==
+  user: cm9vdA==
+stringData:
+  user: admin
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  debug: "true"
+---
+apiVersion: v1
+kind: List
+items:
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+      name: cache
+    stringData:
+      url: redis://cache
+`))
+  assert.NoError(t, err)
+  assert.Equal(t, []ImportedSecret{
+    {Name: "db", Namespace: "prod", Data: map[string]interface{}{"password": "hunter2", "user": "admin"}},
+    {Name: "cache", Data: map[string]interface{}{"url": "redis://cache"}},
+  }, secrets)
+
+  bad := []string{
+    "kind: ConfigMap\nmetadata:\n  name: a\n",
+    "kind: Secret\ndata:\n  a: YQ==\n",
+    "kind: Secret\nmetadata:\n  name: a\ndata:\n  a: not base64!\n",
+    "kind: Secret\nmetadata:\n  name: a\ndata:\n  a: //79\n",
+    "kind: [",
+  }
+  for _, data := range bad {
+    _, err = ParseKubernetesSecrets([]byte(data))
+    assert.Error(t, err, data)
+  }
+}
+
+func TestReadImportSource(t *testing.T) {
+  dir := t.TempDir()
+  envFile := filepath.Join(dir, ".env")
+  assert.NoError(t, os.WriteFile(envFile, []byte("A=1\n"), 0600))
+  manifestFile := filepath.Join(dir, "secrets.yaml")
+  assert.NoError(t, os.WriteFile(manifestFile, []byte("kind: Secret\nmetadata:\n  name: app\nstringData:\n  a: b\n"), 0600))
+
+  secrets, err := ReadImportSource(envFile, ImportFormatAuto)
+  assert.NoError(t, err)
+  assert.Equal(t, []ImportedSecret{{Source: envFile, Name: "env", Data: map[string]interface{}{"A": "1"}}}, secrets)
+
+  secrets, err = ReadImportSource(manifestFile, "")
+  assert.NoError(t, err)
+  assert.Equal(t, "app", secrets[0].Name)
+  assert.Equal(t, manifestFile, secrets[0].Source)
+
+  // the format can be forced
+  _, err = ReadImportSource(manifestFile, ImportFormatDotenv)
+  assert.Error(t, err)
+  _, err = ReadImportSource(envFile, "xml")
+  assert.Error(t, err)
+
+  assert.Equal(t, "prod", dotenvName("/app/prod.env"))
+}
+
+func TestImportToVaultSecrets(t *testing.T) {
+  imported := []ImportedSecret{
+    {Source: "a.yaml", Name: "db", Namespace: "prod", Data: map[string]interface{}{"user": "admin"}},
+    {Source: "a.yaml", Name: "cache", Data: map[string]interface{}{"url": "redis://cache"}},
+  }
+
+  secrets, err := ImportToVaultSecrets(imported, "kv/apps/{namespace}/{name}/")
+  assert.NoError(t, err)
+  assert.Equal(t, []string{"kv/apps/default/cache", "kv/apps/prod/db"}, secrets.SecretNames())
+  assert.Equal(t, "kv/apps/prod/db", secrets.Secrets["kv/apps/prod/db"].VaultKey)
+  assert.Equal(t, "admin", secrets.Secrets["kv/apps/prod/db"].SecretData["user"])
+
+  // two secrets cannot go to the same key
+  _, err = ImportToVaultSecrets(imported, "kv/apps/app")
+  assert.Error(t, err)
+
+  _, err = ImportToVaultSecrets(imported, "")
+  assert.Error(t, err)
+
+  _, err = ImportToVaultSecrets([]ImportedSecret{{Source: ".env", Name: "env"}}, "kv/app")
+  assert.Error(t, err)
+}
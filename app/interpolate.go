@@ -0,0 +1,421 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dgutierrez1287/vault-util/logger"
+)
+
+var lookupEnvFunc = os.LookupEnv
+var readFileFunc = os.ReadFile
+
+// matches ${source:argument} references, $${ is an escaped literal
+var referencePattern = regexp.MustCompile(`\$?\$\{([a-z]+):([^}]*)\}`)
+
+/*
+Character sets that can be used by the random generator
+*/
+var randomCharsets = map[string]string{
+  "alnum": "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789",
+  "alpha": "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ",
+  "numeric": "0123456789",
+  "hex": "0123456789abcdef",
+  "symbols": "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!@#$%^&*()-_=+[]{}<>?",
+}
+
+/*
+referenceResolver - resolves references in secret
+values, vault reads are cached so the same secret is
+only read once
+*/
+type referenceResolver struct {
+  client *VaultClient
+  baseDir string
+  current map[string]interface{}
+  vaultCache map[string]map[string]interface{}
+  lock *sync.Mutex
+}
+
+/*
+This will resolve all references in the secrets, every
+reference is checked before anything is returned so a
+file with an unresolvable reference fails before any
+secret is written. Generators only apply when the secret
+or field does not exist yet, otherwise the current value
+is kept
+*/
+func (vs VaultSecrets) ResolveReferences(client *VaultClient, baseDir string) error {
+  var unresolved []string
+  var lock sync.Mutex
+  vaultCache := make(map[string]map[string]interface{})
+
+  results := RunBulk(vs.SecretNames(), client.Concurrency(), func(name string) error {
+    lock.Lock()
+    secret := vs.Secrets[name]
+    lock.Unlock()
+
+    if !hasReferences(secret.SecretData) {
+      return nil
+    }
+
+    resolver := referenceResolver{
+      client: client,
+      baseDir: baseDir,
+      vaultCache: vaultCache,
+      lock: &lock,
+    }
+
+    if hasGenerators(secret.SecretData) {
+      logger.LogDebug("Secret has generators, reading current data", "name", name)
+      current, _, err := secret.ReadCurrentData(client)
+      if err != nil {
+        return err
+      }
+      resolver.current = current
+    }
+
+    data, errs := resolver.resolveMap(secret.SecretData, nil)
+    if len(errs) > 0 {
+      lock.Lock()
+      for _, err := range errs {
+        unresolved = append(unresolved, fmt.Sprintf("%s: %s", name, err))
+      }
+      lock.Unlock()
+      return errors.New("unresolved references")
+    }
+
+    secret.SecretData = data
+    lock.Lock()
+    vs.Secrets[name] = secret
+    lock.Unlock()
+    return nil
+  })
+
+  for _, result := range results {
+    if result.Err != nil && len(unresolved) == 0 {
+      return fmt.Errorf("%s: %w", result.Key, result.Err)
+    }
+  }
+
+  if len(unresolved) > 0 {
+    sort.Strings(unresolved)
+    logger.LogError("Error secrets file has unresolved references")
+    return fmt.Errorf("unresolved references: %s", strings.Join(unresolved, "; "))
+  }
+  return nil
+}
+
+/*
+This will resolve references in every value of a map,
+the path is where the map is in the secret data
+*/
+func (r referenceResolver) resolveMap(data map[string]interface{},
+  path []string) (map[string]interface{}, []error) {
+
+  var errs []error
+  resolved := make(map[string]interface{}, len(data))
+
+  for key, value := range data {
+    newValue, valueErrs := r.resolveValue(value, appendPath(path, key))
+    errs = append(errs, valueErrs...)
+    resolved[key] = newValue
+  }
+  return resolved, errs
+}
+
+/*
+This will resolve references in a single value, maps
+and arrays are resolved recursively
+*/
+func (r referenceResolver) resolveValue(value interface{},
+  path []string) (interface{}, []error) {
+
+  switch typed := value.(type) {
+  case string:
+    return r.resolveString(typed, path)
+  case map[string]interface{}:
+    return r.resolveMap(typed, path)
+  case []interface{}:
+    var errs []error
+    resolved := make([]interface{}, len(typed))
+    for i, item := range typed {
+      newItem, itemErrs := r.resolveValue(item, appendPath(path, strconv.Itoa(i)))
+      errs = append(errs, itemErrs...)
+      resolved[i] = newItem
+    }
+    return resolved, errs
+  default:
+    return value, nil
+  }
+}
+
+/*
+This will resolve the references in a string, if the whole
+string is a single reference the resolved value keeps its
+type otherwise it is formatted into the string. A string with
+a generator keeps the current value if there is one
+*/
+func (r referenceResolver) resolveString(value string,
+  path []string) (interface{}, []error) {
+
+  var errs []error
+
+  if hasGenerators(value) {
+    if current, ok := lookupPath(r.current, path); ok {
+      logger.LogDebug("Value already exists, keeping current value", "field", strings.Join(path, "."))
+      return current, nil
+    }
+  }
+
+  matches := referencePattern.FindAllStringSubmatchIndex(value, -1)
+  if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(value) &&
+    !strings.HasPrefix(value, "$$") {
+    resolved, err := r.resolveReference(value[matches[0][2]:matches[0][3]],
+      value[matches[0][4]:matches[0][5]])
+    if err != nil {
+      return value, []error{err}
+    }
+    return resolved, nil
+  }
+
+  result := referencePattern.ReplaceAllStringFunc(value, func(match string) string {
+    if strings.HasPrefix(match, "$$") {
+      return match[1:]
+    }
+
+    parts := referencePattern.FindStringSubmatch(match)
+    resolved, err := r.resolveReference(parts[1], parts[2])
+    if err != nil {
+      errs = append(errs, err)
+      return match
+    }
+    return fmt.Sprint(resolved)
+  })
+  return result, errs
+}
+
+/*
+This will resolve a single reference
+*/
+func (r referenceResolver) resolveReference(source string,
+  argument string) (interface{}, error) {
+
+  logger.LogDebug("Resolving reference", "source", source)
+  switch source {
+  case "env":
+    value, ok := lookupEnvFunc(argument)
+    if !ok {
+      return nil, fmt.Errorf("environment variable %s is not set", argument)
+    }
+    return value, nil
+
+  case "file":
+    return r.resolveFile(argument)
+
+  case "vault":
+    return r.resolveVault(argument)
+
+  case "random":
+    return generateRandom(argument)
+
+  default:
+    return nil, fmt.Errorf("unknown reference type %s", source)
+  }
+}
+
+/*
+This will read a file reference, paths are relative to the
+secrets file and a :base64 suffix will base64 encode the file
+*/
+func (r referenceResolver) resolveFile(argument string) (interface{}, error) {
+  filePath := argument
+  encode := false
+
+  if strings.HasSuffix(argument, ":base64") {
+    filePath = strings.TrimSuffix(argument, ":base64")
+    encode = true
+  }
+
+  if !filepath.IsAbs(filePath) {
+    filePath = filepath.Join(r.baseDir, filePath)
+  }
+
+  content, err := readFileFunc(filePath)
+  if err != nil {
+    return nil, fmt.Errorf("cannot read file %s: %w", filePath, err)
+  }
+
+  if encode {
+    return base64.StdEncoding.EncodeToString(content), nil
+  }
+  return string(content), nil
+}
+
+/*
+This will read a field from another secret in vault, the
+argument is in the form mount/path#field
+*/
+func (r referenceResolver) resolveVault(argument string) (interface{}, error) {
+  parts := strings.SplitN(argument, "#", 2)
+  if len(parts) != 2 || parts[1] == "" {
+    return nil, fmt.Errorf("vault reference %s must be in the form mount/path#field", argument)
+  }
+  key, field := parts[0], parts[1]
+
+  r.lock.Lock()
+  data, cached := r.vaultCache[key]
+  r.lock.Unlock()
+
+  if !cached {
+    secret, err := NewSecret(key, "", "", nil, *r.client)
+    if err != nil {
+      return nil, fmt.Errorf("cannot get details for %s: %w", key, err)
+    }
+
+    current, exists, err := secret.ReadCurrentData(r.client)
+    if err != nil {
+      return nil, fmt.Errorf("cannot read %s: %w", key, err)
+    }
+    if !exists {
+      return nil, fmt.Errorf("vault secret %s does not exist", key)
+    }
+
+    data = current
+    r.lock.Lock()
+    r.vaultCache[key] = data
+    r.lock.Unlock()
+  }
+
+  value, ok := data[field]
+  if !ok {
+    return nil, fmt.Errorf("vault secret %s has no field %s", key, field)
+  }
+  return value, nil
+}
+
+/*
+This will generate a random string, the argument is
+length:charset and the charset defaults to alnum
+*/
+func generateRandom(argument string) (string, error) {
+  parts := strings.SplitN(argument, ":", 2)
+
+  length, err := strconv.Atoi(parts[0])
+  if err != nil || length < 1 {
+    return "", fmt.Errorf("random length %s must be a positive number", parts[0])
+  }
+
+  charsetName := "alnum"
+  if len(parts) == 2 {
+    charsetName = parts[1]
+  }
+
+  charset, ok := randomCharsets[charsetName]
+  if !ok {
+    return "", fmt.Errorf("unknown random charset %s", charsetName)
+  }
+
+  var builder strings.Builder
+  max := big.NewInt(int64(len(charset)))
+  for i := 0; i < length; i++ {
+    n, err := rand.Int(rand.Reader, max)
+    if err != nil {
+      return "", err
+    }
+    builder.WriteByte(charset[n.Int64()])
+  }
+  return builder.String(), nil
+}
+
+/*
+This will check if any value in the data has a reference
+*/
+func hasReferences(data map[string]interface{}) bool {
+  return anyString(data, func(value string) bool {
+    return referencePattern.MatchString(value)
+  })
+}
+
+/*
+This will check if any value in the data has a generator
+*/
+func hasGenerators(data interface{}) bool {
+  return anyString(data, func(value string) bool {
+    for _, match := range referencePattern.FindAllStringSubmatch(value, -1) {
+      if match[1] == "random" && !strings.HasPrefix(match[0], "$$") {
+        return true
+      }
+    }
+    return false
+  })
+}
+
+/*
+This will find a value in secret data by its path, array
+items use their index in the path
+*/
+func lookupPath(data interface{}, path []string) (interface{}, bool) {
+  current := data
+  for _, part := range path {
+    switch typed := current.(type) {
+    case map[string]interface{}:
+      value, ok := typed[part]
+      if !ok {
+        return nil, false
+      }
+      current = value
+    case []interface{}:
+      index, err := strconv.Atoi(part)
+      if err != nil || index < 0 || index >= len(typed) {
+        return nil, false
+      }
+      current = typed[index]
+    default:
+      return nil, false
+    }
+  }
+  return current, current != nil
+}
+
+/*
+This will return a new path with a part added
+*/
+func appendPath(path []string, part string) []string {
+  newPath := make([]string, len(path), len(path)+1)
+  copy(newPath, path)
+  return append(newPath, part)
+}
+
+/*
+This will check if any string in a value matches
+*/
+func anyString(value interface{}, check func(string) bool) bool {
+  switch typed := value.(type) {
+  case string:
+    return check(typed)
+  case map[string]interface{}:
+    for _, item := range typed {
+      if anyString(item, check) {
+        return true
+      }
+    }
+  case []interface{}:
+    for _, item := range typed {
+      if anyString(item, check) {
+        return true
+      }
+    }
+  }
+  return false
+}
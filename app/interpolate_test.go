@@ -0,0 +1,111 @@
+package app
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for reference resolution
+*/
+func TestResolveEnvAndFile(t *testing.T) {
+  lookupEnvFunc = func(name string) (string, bool) {
+    if name == "DB_USER" {
+      return "admin", true
+    }
+    return "", false
+  }
+  readFileFunc = func(path string) ([]byte, error) {
+    if path == "certs/ca.pem" {
+      return []byte("cert"), nil
+    }
+    return nil, errors.New("not found")
+  }
+  defer func() {
+    lookupEnvFunc = mockRestoreLookupEnv
+    readFileFunc = mockRestoreReadFile
+  }()
+
+  resolver := referenceResolver{baseDir: "certs"}
+  data := map[string]interface{}{
+    "user": "${env:DB_USER}",
+    "url": "postgres://${env:DB_USER}@db",
+    "ca": "${file:ca.pem}",
+    "caEncoded": "${file:ca.pem:base64}",
+    "literal": "$${env:DB_USER}",
+    "port": float64(5432),
+  }
+
+  resolved, errs := resolver.resolveMap(data, nil)
+  assert.Empty(t, errs)
+  assert.Equal(t, "admin", resolved["user"])
+  assert.Equal(t, "postgres://admin@db", resolved["url"])
+  assert.Equal(t, "cert", resolved["ca"])
+  assert.Equal(t, "Y2VydA==", resolved["caEncoded"])
+  assert.Equal(t, "${env:DB_USER}", resolved["literal"])
+  assert.Equal(t, float64(5432), resolved["port"])
+}
+
+func TestResolveUnresolvable(t *testing.T) {
+  lookupEnvFunc = func(name string) (string, bool) {
+    return "", false
+  }
+  defer func() {
+    lookupEnvFunc = mockRestoreLookupEnv
+  }()
+
+  resolver := referenceResolver{}
+  data := map[string]interface{}{
+    "user": "${env:MISSING}",
+    "nested": map[string]interface{}{
+      "other": []interface{}{"${unknown:x}"},
+    },
+  }
+
+  _, errs := resolver.resolveMap(data, nil)
+  assert.Len(t, errs, 2)
+}
+
+func TestResolveRandomKeepsCurrent(t *testing.T) {
+  resolver := referenceResolver{
+    current: map[string]interface{}{
+      "password": "existing",
+      "nested": map[string]interface{}{"token": "kept"},
+    },
+  }
+  data := map[string]interface{}{
+    "password": "${random:16}",
+    "nested": map[string]interface{}{"token": "tok-${random:8:hex}"},
+    "apiKey": "${random:24:numeric}",
+  }
+
+  resolved, errs := resolver.resolveMap(data, nil)
+  assert.Empty(t, errs)
+  assert.Equal(t, "existing", resolved["password"])
+  assert.Equal(t, "kept", resolved["nested"].(map[string]interface{})["token"])
+  assert.Regexp(t, "^[0-9]{24}$", resolved["apiKey"])
+}
+
+/*
+   Tests for generateRandom
+*/
+func TestGenerateRandom(t *testing.T) {
+  value, err := generateRandom("32:alnum")
+  assert.NoError(t, err)
+  assert.Regexp(t, "^[a-zA-Z0-9]{32}$", value)
+
+  value, err = generateRandom("8")
+  assert.NoError(t, err)
+  assert.Len(t, value, 8)
+
+  _, err = generateRandom("0")
+  assert.Error(t, err)
+
+  _, err = generateRandom("8:emoji")
+  assert.Error(t, err)
+}
+
+var mockRestoreLookupEnv = lookupEnvFunc
+var mockRestoreReadFile = readFileFunc
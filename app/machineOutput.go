@@ -2,6 +2,9 @@ package app
 
 import (
 	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 /*
@@ -30,6 +33,14 @@ func (v VaultListOutput) GetOutputJson() (string, int) {
   return string(jsonBytes), 0
 }
 
+func (v VaultListOutput) Rows() ([]string, [][]string) {
+  var rows [][]string
+  for _, vault := range v.Vaults {
+    rows = append(rows, []string{vault})
+  }
+  return []string{"vault"}, rows
+}
+
 /*
 MountListOutput - Machine output for 
 listing secret mounts 
@@ -48,6 +59,28 @@ func (m MountListOutput) GetOutputJson() (string, int) {
   return string(jsonBytes), 0
 }
 
+func (m MountListOutput) Rows() ([]string, [][]string) {
+  var rows [][]string
+  if m.MountsWithData == nil {
+    for _, name := range m.MountNames {
+      rows = append(rows, []string{name})
+    }
+    return []string{"mount"}, rows
+  }
+
+  var names []string
+  for name := range m.MountsWithData {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+
+  for _, name := range names {
+    mountData, _ := m.MountsWithData[name].(map[string]string)
+    rows = append(rows, []string{name, mountData["type"], mountData["version"], mountData["description"]})
+  }
+  return []string{"mount", "type", "version", "description"}, rows
+}
+
 /*
 GetSecretOutput - Machine output for 
 get secret
@@ -67,6 +100,10 @@ func (s GetSecretOutput) GetOutputJson() (string, int) {
   return string(jsonBytes), 0
 }
 
+func (s GetSecretOutput) EnvData() map[string]interface{} {
+  return s.Data
+}
+
 /*
 SecretListOutput - machine output for 
 secret list
@@ -74,6 +111,9 @@ secret list
 type SecretListOutput struct {
   ExitCode int              `json:"exitCode"`
   Secrets []string          `json:"secrets"`
+  Folders []string          `json:"foldersNotWalked,omitempty"`
+  Skipped []SkippedFolder   `json:"skippedFolders,omitempty"`
+  Tree *SecretFolder        `json:"tree,omitempty"`
 }
 
 func (s SecretListOutput) GetOutputJson() (string, int) {
@@ -84,6 +124,14 @@ func (s SecretListOutput) GetOutputJson() (string, int) {
   return string(jsonBytes), 0
 }
 
+func (s SecretListOutput) Rows() ([]string, [][]string) {
+  var rows [][]string
+  for _, secret := range s.Secrets {
+    rows = append(rows, []string{secret})
+  }
+  return []string{"secret"}, rows
+}
+
 /*
 AddRemoveOuput - Machine output for
 adding and removing vaults from config
@@ -109,7 +157,16 @@ type BulkActionOutput struct {
   ExitCode int                  `json:"exitCode"`
   SecretsAdded []string         `json:"secretsAdded,omitempty"`
   SecretsRemoved []string       `json:"secretsRemoved,omitempty"`
+  SecretsUnchanged []string     `json:"secretsUnchanged,omitempty"`
+  SecretsExisting []string      `json:"secretsExisting,omitempty"`
+  SecretsSkipped []string       `json:"secretsSkipped,omitempty"`
   Errors []SecretActionError    `json:"Errors,omitempty"`
+  CheckpointFile string         `json:"checkpointFile,omitempty"`
+  RolledBack bool               `json:"rolledBack,omitempty"`
+  SecretsRestored []string      `json:"secretsRestored,omitempty"`
+  SecretsDeleted []string       `json:"secretsDeleted,omitempty"`
+  RollbackConflicts []string    `json:"rollbackConflicts,omitempty"`
+  RollbackErrors []SecretActionError `json:"rollbackErrors,omitempty"`
 }
 
 func (b BulkActionOutput) GetOutputJson() (string, int) {
@@ -121,3 +178,302 @@ func (b BulkActionOutput) GetOutputJson() (string, int) {
 }
 
 
+
+/*
+PlanOutput - Machine output for a bulk
+load plan, the plan never contains secret data
+*/
+type PlanOutput struct {
+  ExitCode int                  `json:"exitCode"`
+  PlanFile string               `json:"planFile,omitempty"`
+  Plan SecretPlan               `json:"plan"`
+}
+
+func (p PlanOutput) GetOutputJson() (string, int) {
+  jsonBytes, err := json.Marshal(p)
+  if err != nil {
+    return "{\"exitCode\": 100, \"errorMessage\": \"Error marshaling machine output\"}", 100
+  }
+  return string(jsonBytes), 0
+}
+
+/*
+MoveOutput - Machine output for moving
+secrets
+*/
+type MoveOutput struct {
+  ExitCode int                  `json:"exitCode"`
+  JournalFile string            `json:"journalFile"`
+  SecretsMoved []string         `json:"secretsMoved,omitempty"`
+  SecretsReversed []string      `json:"secretsReversed,omitempty"`
+  Errors []SecretActionError    `json:"Errors,omitempty"`
+}
+
+func (m MoveOutput) GetOutputJson() (string, int) {
+  jsonBytes, err := json.Marshal(m)
+  if err != nil {
+    return "{\"exitCode\": 100, \"errorMessage\": \"Error marshaling machine output\"}", 100
+  }
+  return string(jsonBytes), 0
+}
+
+/*
+ExportOutput - Machine output for
+exporting secrets
+*/
+type ExportOutput struct {
+  ExitCode int                  `json:"exitCode"`
+  OutputFile string             `json:"outputFile"`
+  Encrypted bool                `json:"encrypted"`
+  SecretsExported []string      `json:"secretsExported,omitempty"`
+  Errors []SecretActionError    `json:"Errors,omitempty"`
+  CheckpointFile string         `json:"checkpointFile,omitempty"`
+}
+
+func (e ExportOutput) GetOutputJson() (string, int) {
+  jsonBytes, err := json.Marshal(e)
+  if err != nil {
+    return "{\"exitCode\": 100, \"errorMessage\": \"Error marshaling machine output\"}", 100
+  }
+  return string(jsonBytes), 0
+}
+
+/*
+ValidateOutput - Machine output for
+validating a secrets file
+*/
+type ValidateOutput struct {
+  ExitCode int                  `json:"exitCode"`
+  SecretsFile string            `json:"secretsFile"`
+  Valid bool                    `json:"valid"`
+  Issues []ValidationIssue      `json:"issues,omitempty"`
+}
+
+func (v ValidateOutput) GetOutputJson() (string, int) {
+  jsonBytes, err := json.Marshal(v)
+  if err != nil {
+    return "{\"exitCode\": 100, \"errorMessage\": \"Error marshaling machine output\"}", 100
+  }
+  return string(jsonBytes), 0
+}
+
+/*
+BackupOutput - Machine output for
+backing up kv mounts
+*/
+type BackupOutput struct {
+  ExitCode int                  `json:"exitCode"`
+  BackupFile string             `json:"backupFile"`
+  Mounts []BackupMount          `json:"mounts,omitempty"`
+  SecretCount int               `json:"secretCount"`
+  Errors []SecretActionError    `json:"Errors,omitempty"`
+}
+
+func (b BackupOutput) GetOutputJson() (string, int) {
+  jsonBytes, err := json.Marshal(b)
+  if err != nil {
+    return "{\"exitCode\": 100, \"errorMessage\": \"Error marshaling machine output\"}", 100
+  }
+  return string(jsonBytes), 0
+}
+
+/*
+SearchOutput - Machine output for
+searching secrets
+*/
+type SearchOutput struct {
+  ExitCode int                  `json:"exitCode"`
+  Matches []SearchMatch         `json:"matches"`
+  Errors []SecretActionError    `json:"Errors,omitempty"`
+}
+
+func (s SearchOutput) GetOutputJson() (string, int) {
+  jsonBytes, err := json.Marshal(s)
+  if err != nil {
+    return "{\"exitCode\": 100, \"errorMessage\": \"Error marshaling machine output\"}", 100
+  }
+  return string(jsonBytes), 0
+}
+
+func (s SearchOutput) Rows() ([]string, [][]string) {
+  var rows [][]string
+  for _, match := range s.Matches {
+    rows = append(rows, []string{
+      match.Key,
+      strconv.FormatBool(match.PathMatched),
+      strings.Join(match.Fields, " "),
+      strings.Join(match.ValueFields, " "),
+    })
+  }
+  return []string{"secret", "pathMatched", "fields", "valueFields"}, rows
+}
+
+/*
+StreamedSecretOutput - Machine output for a
+secret found while streaming a secret list, one
+is printed per line
+*/
+type StreamedSecretOutput struct {
+  Secret string             `json:"secret"`
+}
+
+func (s StreamedSecretOutput) GetOutputJson() (string, int) {
+  jsonBytes, err := json.Marshal(s)
+  if err != nil {
+    return "{\"exitCode\": 100, \"errorMessage\": \"Error marshaling machine output\"}", 100
+  }
+  return string(jsonBytes), 0
+}
+
+/*
+SecretDetailListOutput - Machine output for
+secret list with metadata
+*/
+type SecretDetailListOutput struct {
+  ExitCode int                  `json:"exitCode"`
+  Mount string                  `json:"mount"`
+  Secrets []SecretDetail        `json:"secrets"`
+  Skipped []SkippedFolder       `json:"skippedFolders,omitempty"`
+  Errors []SecretActionError    `json:"Errors,omitempty"`
+}
+
+func (s SecretDetailListOutput) GetOutputJson() (string, int) {
+  jsonBytes, err := json.Marshal(s)
+  if err != nil {
+    return "{\"exitCode\": 100, \"errorMessage\": \"Error marshaling machine output\"}", 100
+  }
+  return string(jsonBytes), 0
+}
+
+func (s SecretDetailListOutput) Rows() ([]string, [][]string) {
+  var rows [][]string
+  for _, detail := range s.Secrets {
+    rows = append(rows, []string{
+      detail.Key,
+      strconv.FormatInt(detail.CurrentVersion, 10),
+      strconv.Itoa(detail.VersionCount),
+      formatTime(detail.CreatedTime),
+      formatTime(detail.UpdatedTime),
+      detail.DeletionState,
+      detail.Owner,
+    })
+  }
+  return []string{"secret", "version", "versions", "created", "updated", "state", "owner"}, rows
+}
+
+/*
+DuplicatesOutput - Machine output for
+auditing duplicate secret values
+*/
+type DuplicatesOutput struct {
+  ExitCode int                  `json:"exitCode"`
+  Groups []DuplicateGroup       `json:"groups"`
+  Errors []SecretActionError    `json:"Errors,omitempty"`
+}
+
+func (d DuplicatesOutput) GetOutputJson() (string, int) {
+  jsonBytes, err := json.Marshal(d)
+  if err != nil {
+    return "{\"exitCode\": 100, \"errorMessage\": \"Error marshaling machine output\"}", 100
+  }
+  return string(jsonBytes), 0
+}
+
+func (d DuplicatesOutput) Rows() ([]string, [][]string) {
+  var rows [][]string
+  for _, group := range d.Groups {
+    for _, location := range group.Locations {
+      rows = append(rows, []string{group.Id, location.Key, location.Field})
+    }
+  }
+  return []string{"group", "secret", "field"}, rows
+}
+
+/*
+StaleOutput - Machine output for
+reporting stale secrets
+*/
+type StaleOutput struct {
+  ExitCode int                  `json:"exitCode"`
+  WarnDays int                  `json:"warnDays"`
+  CriticalDays int              `json:"criticalDays"`
+  Report StaleReport            `json:"report"`
+}
+
+func (s StaleOutput) GetOutputJson() (string, int) {
+  jsonBytes, err := json.Marshal(s)
+  if err != nil {
+    return "{\"exitCode\": 100, \"errorMessage\": \"Error marshaling machine output\"}", 100
+  }
+  return string(jsonBytes), 0
+}
+
+func (s StaleOutput) Rows() ([]string, [][]string) {
+  var rows [][]string
+  for _, group := range s.Report.Groups {
+    for _, secret := range group.Secrets {
+      rows = append(rows, []string{
+        group.Mount,
+        group.Owner,
+        secret.Key,
+        formatTime(secret.UpdatedTime),
+        strconv.Itoa(secret.AgeDays),
+        secret.Status,
+      })
+    }
+  }
+  return []string{"mount", "owner", "secret", "updated", "ageDays", "status"}, rows
+}
+
+/*
+RenderOutput - Machine output for rendering
+templates, in watch mode one is printed per line
+every time the templates are rendered
+*/
+type RenderOutput struct {
+  ExitCode int                  `json:"exitCode"`
+  Files []RenderResult          `json:"files"`
+}
+
+func (r RenderOutput) GetOutputJson() (string, int) {
+  jsonBytes, err := json.Marshal(r)
+  if err != nil {
+    return "{\"exitCode\": 100, \"errorMessage\": \"Error marshaling machine output\"}", 100
+  }
+  return string(jsonBytes), r.ExitCode
+}
+
+func (r RenderOutput) Rows() ([]string, [][]string) {
+  var rows [][]string
+  for _, file := range r.Files {
+    rows = append(rows, []string{file.Source, file.Destination, strconv.FormatBool(file.Changed)})
+  }
+  return []string{"source", "destination", "changed"}, rows
+}
+
+/*
+GenerateOutput - Machine output for generating
+an artifact to a file
+*/
+type GenerateOutput struct {
+  ExitCode int                  `json:"exitCode"`
+  Kind string                   `json:"kind"`
+  OutputFile string             `json:"outputFile"`
+  Names []string                `json:"names"`
+}
+
+func (g GenerateOutput) GetOutputJson() (string, int) {
+  jsonBytes, err := json.Marshal(g)
+  if err != nil {
+    return "{\"exitCode\": 100, \"errorMessage\": \"Error marshaling machine output\"}", 100
+  }
+  return string(jsonBytes), g.ExitCode
+}
+
+func (g GenerateOutput) Rows() ([]string, [][]string) {
+  var rows [][]string
+  for _, name := range g.Names {
+    rows = append(rows, []string{g.Kind, g.OutputFile, name})
+  }
+  return []string{"kind", "outputFile", "name"}, rows
+}
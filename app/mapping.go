@@ -0,0 +1,223 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dgutierrez1287/vault-util/logger"
+)
+
+/*
+FieldMapping - an explicit name for a field, when the
+secret key is empty the mapping is for the field in
+every secret
+*/
+type FieldMapping struct {
+  SecretKey string              `json:"secretKey,omitempty"`
+  Field string                  `json:"field"`
+  Name string                   `json:"name"`
+}
+
+/*
+MappingRules - how secret fields are named when they are
+turned into env variables or file keys. Fields without a
+mapping are named from their dot path with the prefix in
+front, when only mapped is set they are left out
+*/
+type MappingRules struct {
+  Prefix string                 `json:"prefix,omitempty"`
+  Uppercase bool                `json:"uppercase,omitempty"`
+  OnlyMapped bool               `json:"onlyMapped,omitempty"`
+  Mappings []FieldMapping       `json:"mappings,omitempty"`
+}
+
+/*
+MappedField - a secret field and the name it is mapped to
+*/
+type MappedField struct {
+  SecretKey string
+  Field string
+  Name string
+  Value interface{}
+}
+
+/*
+This will parse a mapping in the form field=NAME or
+secret/key:field=NAME
+*/
+func ParseFieldMapping(mapping string) (FieldMapping, error) {
+  source, name, found := strings.Cut(mapping, "=")
+  if !found || source == "" || name == "" {
+    return FieldMapping{}, fmt.Errorf("mapping %s must be field=NAME or secret/key:field=NAME", mapping)
+  }
+
+  secretKey, field, found := strings.Cut(source, ":")
+  if !found {
+    return FieldMapping{Field: source, Name: name}, nil
+  }
+  if secretKey == "" || field == "" {
+    return FieldMapping{}, fmt.Errorf("mapping %s must be field=NAME or secret/key:field=NAME", mapping)
+  }
+  return FieldMapping{SecretKey: strings.Trim(secretKey, "/"), Field: field, Name: name}, nil
+}
+
+/*
+This will parse a list of mappings
+*/
+func ParseFieldMappings(mappings []string) ([]FieldMapping, error) {
+  var parsed []FieldMapping
+  for _, mapping := range mappings {
+    fieldMapping, err := ParseFieldMapping(mapping)
+    if err != nil {
+      return nil, err
+    }
+    parsed = append(parsed, fieldMapping)
+  }
+  return parsed, nil
+}
+
+/*
+This will get the kv secrets for a list of keys
+*/
+func KvSecrets(client *VaultClient, keys []string) ([]VaultSecret, error) {
+  if len(keys) == 0 {
+    return nil, errors.New("at least one secret key is required")
+  }
+
+  var secrets []VaultSecret
+  for _, key := range keys {
+    secret, err := NewSecret(key, "", "", nil, *client)
+    if err != nil {
+      return nil, fmt.Errorf("%s: %w", key, err)
+    }
+    if secret.SecretType != "kv" {
+      return nil, fmt.Errorf("%s: only kv secrets can be used", key)
+    }
+    secrets = append(secrets, secret)
+  }
+  return secrets, nil
+}
+
+/*
+This will read the secrets and map their fields, when a
+name is used twice the later secret wins. The fields are
+returned sorted by name
+*/
+func ReadMappedFields(client *VaultClient, secrets []VaultSecret,
+  rules MappingRules) ([]MappedField, error) {
+
+  var sources [][]MappedField
+  for _, secret := range secrets {
+    err := secret.ReadSecret(client)
+    if err != nil {
+      return nil, fmt.Errorf("%s: %w", secret.VaultKey, err)
+    }
+    sources = append(sources, rules.MapFields(secret.VaultKey, secret.SecretData))
+  }
+  return mergeMappedFields(sources...), nil
+}
+
+/*
+This will merge mapped fields from several secrets, a
+later field replaces an earlier one with the same name
+*/
+func mergeMappedFields(sources ...[]MappedField) []MappedField {
+  byName := make(map[string]MappedField)
+  for _, fields := range sources {
+    for _, field := range fields {
+      if existing, exists := byName[field.Name]; exists {
+        logger.LogDebug("Name is set by more than one secret, using the later one",
+          "name", field.Name, "key", field.SecretKey, "replaced", existing.SecretKey)
+      }
+      byName[field.Name] = field
+    }
+  }
+
+  merged := make([]MappedField, 0, len(byName))
+  for _, field := range byName {
+    merged = append(merged, field)
+  }
+  sort.Slice(merged, func(i, j int) bool { return merged[i].Name < merged[j].Name })
+  return merged
+}
+
+/*
+This will map the fields of a secret to names, nested values
+are mapped by their dot path and a mapping for a field that
+has values under it maps the whole value. The fields are
+returned sorted by name
+*/
+func (r MappingRules) MapFields(secretKey string, data map[string]interface{}) []MappedField {
+  var mapped []MappedField
+  secretKey = strings.Trim(secretKey, "/")
+  mappedPaths := make(map[string]bool)
+
+  for _, mapping := range r.Mappings {
+    if mapping.SecretKey != "" && mapping.SecretKey != secretKey {
+      continue
+    }
+
+    value, err := ExtractField(data, mapping.Field)
+    if err != nil {
+      continue
+    }
+    mapped = append(mapped, MappedField{
+      SecretKey: secretKey,
+      Field: mapping.Field,
+      Name: mapping.Name,
+      Value: value,
+    })
+    mappedPaths[mapping.Field] = true
+  }
+
+  if !r.OnlyMapped {
+    walkLeaves(data, "", func(field string, name string, value interface{}) {
+      if r.isMapped(field, mappedPaths) {
+        return
+      }
+      mapped = append(mapped, MappedField{
+        SecretKey: secretKey,
+        Field: field,
+        Name: r.Name(field),
+        Value: value,
+      })
+    })
+  }
+
+  sort.Slice(mapped, func(i, j int) bool { return mapped[i].Name < mapped[j].Name })
+  return mapped
+}
+
+/*
+This will get the name of a field that has no mapping
+*/
+func (r MappingRules) Name(field string) string {
+  name := r.Prefix + field
+  if r.Uppercase {
+    name = strings.ToUpper(name)
+  }
+  return sanitizeEnvName(name)
+}
+
+/*
+This will check if a field or a field above it
+has a mapping
+*/
+func (r MappingRules) isMapped(field string, mappedPaths map[string]bool) bool {
+  for mappedPath := range mappedPaths {
+    if field == mappedPath || strings.HasPrefix(field, mappedPath+".") {
+      return true
+    }
+  }
+  return false
+}
+
+/*
+This will get the text of a mapped value, strings are
+used as they are and other values as json
+*/
+func (m MappedField) Text() string {
+  return searchText(m.Value)
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for mapping secret fields to names
+*/
+func TestParseFieldMapping(t *testing.T) {
+  mapping, err := ParseFieldMapping("password=DB_PASSWORD")
+  assert.NoError(t, err)
+  assert.Equal(t, FieldMapping{Field: "password", Name: "DB_PASSWORD"}, mapping)
+
+  mapping, err = ParseFieldMapping("/app/db/:creds.user=DB_USER")
+  assert.NoError(t, err)
+  assert.Equal(t, FieldMapping{SecretKey: "app/db", Field: "creds.user", Name: "DB_USER"}, mapping)
+
+  for _, bad := range []string{"password", "=NAME", "password=", ":field=NAME", "app/db:=NAME"} {
+    _, err = ParseFieldMapping(bad)
+    assert.Error(t, err, bad)
+  }
+}
+
+func TestMapFields(t *testing.T) {
+  data := map[string]interface{}{
+    "password": "hunter2",
+    "port": float64(5432),
+    "db": map[string]interface{}{
+      "user": "admin",
+      "hosts": []interface{}{"a", "b"},
+    },
+    "tls-cert": "cert",
+  }
+
+  rules := MappingRules{Prefix: "app_", Uppercase: true}
+  names := make(map[string]string)
+  for _, field := range rules.MapFields("app/config", data) {
+    names[field.Name] = field.Text()
+  }
+  assert.Equal(t, map[string]string{
+    "APP_PASSWORD": "hunter2",
+    "APP_PORT": "5432",
+    "APP_DB_USER": "admin",
+    "APP_DB_HOSTS_0": "a",
+    "APP_DB_HOSTS_1": "b",
+    "APP_TLS_CERT": "cert",
+  }, names)
+
+  // mappings replace the generated names and a mapped
+  // field with values under it is mapped whole
+  rules = MappingRules{
+    Mappings: []FieldMapping{
+      {Field: "password", Name: "DB_PASSWORD"},
+      {SecretKey: "app/config", Field: "db.hosts", Name: "DB_HOSTS"},
+      {SecretKey: "app/other", Field: "port", Name: "OTHER_PORT"},
+      {Field: "missing", Name: "MISSING"},
+    },
+  }
+  names = make(map[string]string)
+  for _, field := range rules.MapFields("/app/config", data) {
+    names[field.Name] = field.Text()
+  }
+  assert.Equal(t, map[string]string{
+    "DB_PASSWORD": "hunter2",
+    "DB_HOSTS": `["a","b"]`,
+    "port": "5432",
+    "db_user": "admin",
+    "tls_cert": "cert",
+  }, names)
+
+  rules.OnlyMapped = true
+  mapped := rules.MapFields("app/config", data)
+  assert.Len(t, mapped, 2)
+  assert.Equal(t, "DB_HOSTS", mapped[0].Name)
+  assert.Equal(t, "DB_PASSWORD", mapped[1].Name)
+}
+
+func TestMappingRulesName(t *testing.T) {
+  assert.Equal(t, "db_user", MappingRules{}.Name("db.user"))
+  assert.Equal(t, "MY_DB_USER", MappingRules{Prefix: "my-", Uppercase: true}.Name("db.user"))
+  assert.Equal(t, "_1PASSWORD", MappingRules{Uppercase: true}.Name("1password"))
+  assert.Equal(t, "_1PASSWORD", EnvName("1password"))
+}
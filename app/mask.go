@@ -0,0 +1,72 @@
+package app
+
+import (
+	"strconv"
+	"strings"
+)
+
+// what a hidden value is shown as, it is always the same length
+const MaskedValue = "********"
+
+/*
+RevealOptions - which secret values are shown in console
+output, a field reveals every value under it
+*/
+type RevealOptions struct {
+  All bool
+  Fields []string
+}
+
+/*
+This will check if the value at a field path is shown
+*/
+func (o RevealOptions) Reveals(field string) bool {
+  if o.All {
+    return true
+  }
+
+  for _, revealed := range o.Fields {
+    revealed = strings.Trim(revealed, ".")
+    if field == revealed || strings.HasPrefix(field, revealed+".") {
+      return true
+    }
+  }
+  return false
+}
+
+/*
+This will copy secret data with every value that is not
+revealed replaced by the mask, maps and arrays are kept so
+the shape of the data can still be seen
+*/
+func MaskSecretData(data map[string]interface{}, opts RevealOptions) map[string]interface{} {
+  masked, _ := maskValue(data, "", opts).(map[string]interface{})
+  return masked
+}
+
+/*
+This will mask a single value and everything under it
+*/
+func maskValue(value interface{}, field string, opts RevealOptions) interface{} {
+  switch typed := value.(type) {
+  case map[string]interface{}:
+    masked := make(map[string]interface{}, len(typed))
+    for key, item := range typed {
+      masked[key] = maskValue(item, joinField(field, key), opts)
+    }
+    return masked
+  case []interface{}:
+    masked := make([]interface{}, len(typed))
+    for i, item := range typed {
+      masked[i] = maskValue(item, joinField(field, strconv.Itoa(i)), opts)
+    }
+    return masked
+  case nil:
+    return nil
+  }
+
+  if opts.Reveals(field) {
+    return value
+  }
+  return MaskedValue
+}
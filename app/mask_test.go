@@ -0,0 +1,55 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for masking secret values
+*/
+func TestMaskSecretData(t *testing.T) {
+  data := map[string]interface{}{
+    "password": "hunter2",
+    "port": float64(5432),
+    "enabled": true,
+    "db": map[string]interface{}{
+      "user": "admin",
+      "hosts": []interface{}{"a", "b"},
+    },
+    "empty": nil,
+  }
+
+  masked := MaskSecretData(data, RevealOptions{})
+  assert.Equal(t, map[string]interface{}{
+    "password": MaskedValue,
+    "port": MaskedValue,
+    "enabled": MaskedValue,
+    "db": map[string]interface{}{
+      "user": MaskedValue,
+      "hosts": []interface{}{MaskedValue, MaskedValue},
+    },
+    "empty": nil,
+  }, masked)
+
+  // the data that was masked is not changed
+  assert.Equal(t, "hunter2", data["password"])
+
+  masked = MaskSecretData(data, RevealOptions{Fields: []string{"db", "port"}})
+  assert.Equal(t, MaskedValue, masked["password"])
+  assert.Equal(t, float64(5432), masked["port"])
+  assert.Equal(t, []interface{}{"a", "b"}, masked["db"].(map[string]interface{})["hosts"])
+
+  masked = MaskSecretData(data, RevealOptions{All: true})
+  assert.Equal(t, data, masked)
+}
+
+func TestRevealOptions(t *testing.T) {
+  opts := RevealOptions{Fields: []string{"db.hosts"}}
+
+  assert.True(t, opts.Reveals("db.hosts"))
+  assert.True(t, opts.Reveals("db.hosts.0"))
+  assert.False(t, opts.Reveals("db.hostsbackup"))
+  assert.False(t, opts.Reveals("db"))
+}
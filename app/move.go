@@ -0,0 +1,632 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/dgutierrez1287/vault-util/util"
+	"github.com/hashicorp/vault-client-go/schema"
+)
+
+// version of the move journal format
+const MoveJournalFormatVersion = 1
+
+/*
+States a move can be in, they are saved to the
+journal after every step so a move can be resumed
+or reversed
+*/
+const (
+  MoveStatePending = "pending"
+  MoveStateCopied = "copied"
+  MoveStateVerified = "verified"
+  MoveStateDone = "done"
+  MoveStateReversed = "reversed"
+)
+
+/*
+MoveOptions - options for moving secrets, a secret is
+only moved onto an existing secret with force
+*/
+type MoveOptions struct {
+  AllVersions bool                `json:"allVersions"`
+  Metadata bool                   `json:"metadata"`
+  Force bool                      `json:"force,omitempty"`
+}
+
+/*
+MoveJournalEntry - a single secret that is
+being moved and how far the move got. When the
+destination existed before the move its kv v2
+version is kept so a reverse can restore it
+*/
+type MoveJournalEntry struct {
+  From string                     `json:"from"`
+  To string                       `json:"to"`
+  State string                    `json:"state"`
+  SourceHash string               `json:"sourceHash,omitempty"`
+  DestChecked bool                `json:"destChecked,omitempty"`
+  DestExisted bool                `json:"destExisted,omitempty"`
+  DestVersion int64               `json:"destVersion,omitempty"`
+  Error string                    `json:"error,omitempty"`
+}
+
+/*
+MoveJournal - the journal for a move, this never
+contains secret values
+*/
+type MoveJournal struct {
+  FormatVersion int               `json:"formatVersion"`
+  CreatedAt time.Time             `json:"createdAt"`
+  FromPath string                 `json:"fromPath"`
+  ToPath string                   `json:"toPath"`
+  Options MoveOptions             `json:"options"`
+  Entries []MoveJournalEntry      `json:"entries"`
+
+  journalPath string
+  lock sync.Mutex
+}
+
+/*
+MoveResult - the results of running or
+reversing a move
+*/
+type MoveResult struct {
+  Completed []string
+  Errors []SecretActionError
+}
+
+/*
+This will create a journal for moving a single secret or
+a folder of secrets, the journal is written before anything
+is moved
+*/
+func NewMoveJournal(client *VaultClient, fromPath string, toPath string,
+  tree bool, opts MoveOptions, journalPath string) (*MoveJournal, error) {
+
+  journal := &MoveJournal{
+    FormatVersion: MoveJournalFormatVersion,
+    CreatedAt: time.Now().UTC(),
+    FromPath: fromPath,
+    ToPath: toPath,
+    Options: opts,
+    journalPath: journalPath,
+  }
+
+  if !tree {
+    logger.LogDebug("Moving a single secret", "from", fromPath, "to", toPath)
+    journal.Entries = append(journal.Entries, MoveJournalEntry{
+      From: fromPath,
+      To: toPath,
+      State: MoveStatePending,
+    })
+  } else {
+    logger.LogDebug("Listing secrets to move", "from", fromPath)
+    sourceKeys, err := listSubtree(client, fromPath)
+    if err != nil {
+      logger.LogError("Error listing secrets to move")
+      return nil, err
+    }
+
+    if len(sourceKeys) == 0 {
+      logger.LogError("Error no secrets found to move")
+      return nil, errors.New("no secrets found at from path")
+    }
+
+    relKeys := make([]string, 0, len(sourceKeys))
+    for relKey := range sourceKeys {
+      relKeys = append(relKeys, relKey)
+    }
+    sort.Strings(relKeys)
+
+    for _, relKey := range relKeys {
+      journal.Entries = append(journal.Entries, MoveJournalEntry{
+        From: sourceKeys[relKey],
+        To: joinKey(toPath, relKey),
+        State: MoveStatePending,
+      })
+    }
+  }
+
+  err := journal.save()
+  if err != nil {
+    return nil, err
+  }
+  return journal, nil
+}
+
+/*
+This will run the move for every secret in the journal that
+is not done, each secret is copied, verified and then the
+source is deleted
+*/
+func (j *MoveJournal) Run(client *VaultClient) MoveResult {
+  return j.runEntries(client, MoveStateDone, j.moveEntry)
+}
+
+/*
+This will reverse a move, secrets that were already moved
+are copied back to the source and copies that were made
+are deleted
+*/
+func (j *MoveJournal) Reverse(client *VaultClient) MoveResult {
+  return j.runEntries(client, MoveStateReversed, j.reverseEntry)
+}
+
+/*
+This will run a step function for every entry that is not
+already in the final state using the client's worker pool
+*/
+func (j *MoveJournal) runEntries(client *VaultClient, finalState string,
+  step func(*VaultClient, int) error) MoveResult {
+
+  var result MoveResult
+  indexes := make(map[string]int)
+  var keys []string
+
+  for i, entry := range j.Entries {
+    if entry.State == finalState {
+      result.Completed = append(result.Completed, entry.From)
+      continue
+    }
+    indexes[entry.From] = i
+    keys = append(keys, entry.From)
+  }
+
+  results := RunBulk(keys, client.Concurrency(), func(key string) error {
+    err := step(client, indexes[key])
+    if err != nil {
+      j.setError(indexes[key], err)
+    }
+    return err
+  })
+
+  succeeded, failed := SplitBulkResults(results)
+  result.Completed = append(result.Completed, succeeded...)
+  sort.Strings(result.Completed)
+  for _, failure := range failed {
+    result.Errors = append(result.Errors, SecretActionError{
+      VaultKey: failure.Key,
+      Error: failure.Err,
+    })
+  }
+  return result
+}
+
+/*
+This will move a single journal entry starting from the
+state it was left in
+*/
+func (j *MoveJournal) moveEntry(client *VaultClient, index int) error {
+  entry := j.entry(index)
+
+  source, err := NewSecret(entry.From, "", "", nil, *client)
+  if err != nil {
+    return err
+  }
+  dest, err := NewSecret(entry.To, "", "", nil, *client)
+  if err != nil {
+    return err
+  }
+
+  for entry.State != MoveStateDone {
+    switch entry.State {
+    case MoveStatePending:
+      if !entry.DestChecked {
+        entry, err = j.checkDestination(client, index, entry, dest)
+        if err != nil {
+          return err
+        }
+      }
+
+      logger.LogDebug("Copying secret", "from", entry.From, "to", entry.To)
+      sourceHash, err := copySecret(client, source, dest, j.Options)
+      if err != nil {
+        return err
+      }
+      entry.SourceHash = sourceHash
+      entry.State = MoveStateCopied
+
+    case MoveStateCopied:
+      logger.LogDebug("Verifying secret copy", "from", entry.From, "to", entry.To)
+      err := verifyCopy(client, source, dest, entry.SourceHash)
+      if err != nil {
+        return err
+      }
+      entry.State = MoveStateVerified
+
+    case MoveStateVerified:
+      logger.LogDebug("Deleting source secret", "key", entry.From)
+      err := source.DeleteSecret(client)
+      if err != nil && !IsNotFoundError(err) {
+        return err
+      }
+      entry.State = MoveStateDone
+
+    default:
+      return fmt.Errorf("cannot move secret in state %s", entry.State)
+    }
+
+    entry.Error = ""
+    err = j.update(index, entry)
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+/*
+This will reverse a single journal entry based on the
+state it was left in
+*/
+func (j *MoveJournal) reverseEntry(client *VaultClient, index int) error {
+  entry := j.entry(index)
+
+  source, err := NewSecret(entry.From, "", "", nil, *client)
+  if err != nil {
+    return err
+  }
+  dest, err := NewSecret(entry.To, "", "", nil, *client)
+  if err != nil {
+    return err
+  }
+
+  switch entry.State {
+  case MoveStatePending:
+    logger.LogDebug("Secret was never copied, nothing to reverse", "key", entry.From)
+
+  case MoveStateCopied, MoveStateVerified:
+    logger.LogDebug("Source still exists, removing copy", "key", entry.To)
+    err := restoreDestination(client, entry, dest)
+    if err != nil {
+      return err
+    }
+
+  case MoveStateDone:
+    logger.LogDebug("Copying secret back to source", "from", entry.To, "to", entry.From)
+    destHash, err := copySecret(client, dest, source, j.Options)
+    if err != nil {
+      return err
+    }
+
+    err = verifyCopy(client, dest, source, destHash)
+    if err != nil {
+      return err
+    }
+
+    logger.LogDebug("Removing moved secret", "key", entry.To)
+    err = restoreDestination(client, entry, dest)
+    if err != nil {
+      return err
+    }
+
+  default:
+    return fmt.Errorf("cannot reverse secret in state %s", entry.State)
+  }
+
+  entry.State = MoveStateReversed
+  entry.Error = ""
+  return j.update(index, entry)
+}
+
+/*
+This will check if the destination of a move exists before
+anything is copied to it, an existing secret is only moved
+onto with force. What was found is saved in the journal
+*/
+func (j *MoveJournal) checkDestination(client *VaultClient, index int,
+  entry MoveJournalEntry, dest VaultSecret) (MoveJournalEntry, error) {
+
+  _, exists, err := dest.ReadCurrentData(client)
+  if err != nil {
+    return entry, err
+  }
+
+  if exists {
+    if !j.Options.Force {
+      return entry, fmt.Errorf("%s already exists, use --force to move onto it", entry.To)
+    }
+
+    version, err := dest.CurrentVersion(client)
+    if err != nil {
+      return entry, err
+    }
+    logger.LogDebug("Destination exists, it will be overwritten", "key", entry.To,
+      "version", version)
+    entry.DestExisted = true
+    entry.DestVersion = version
+  }
+
+  entry.DestChecked = true
+  return entry, j.update(index, entry)
+}
+
+/*
+This will undo the copy to a destination, a destination that
+did not exist before the move is deleted and one that did
+is put back to the kv v2 version it had. kv v1 secrets have
+no earlier version so they are left for the user to fix
+*/
+func restoreDestination(client *VaultClient, entry MoveJournalEntry,
+  dest VaultSecret) error {
+
+  if !entry.DestExisted {
+    err := dest.DeleteSecret(client)
+    if err != nil && !IsNotFoundError(err) {
+      return err
+    }
+    return nil
+  }
+
+  if entry.DestVersion < 1 {
+    return fmt.Errorf("%s existed before the move and has no earlier version to restore, it was left as it is",
+      entry.To)
+  }
+
+  logger.LogDebug("Restoring destination to the version it had before the move",
+    "key", entry.To, "version", entry.DestVersion)
+  data, err := client.ReadKvSecretVersion(dest, entry.DestVersion)
+  if err != nil {
+    return fmt.Errorf("%s: reading version %d to restore: %w", entry.To, entry.DestVersion, err)
+  }
+
+  dest.SecretData = data
+  return dest.WriteSecret(client)
+}
+
+/*
+This will copy a secret to a new key, optionally with all
+kv v2 versions and custom metadata. The hash of the source
+data that was copied is returned for verification
+*/
+func copySecret(client *VaultClient, source VaultSecret, dest VaultSecret,
+  opts MoveOptions) (string, error) {
+
+  bothV2 := source.KvVersion == "2" && dest.KvVersion == "2"
+
+  if opts.AllVersions && bothV2 {
+    logger.LogDebug("Copying all versions of secret", "key", source.VaultKey)
+    err := copySecretVersions(client, source, dest)
+    if err != nil {
+      return "", err
+    }
+  } else {
+    data, exists, err := source.ReadCurrentData(client)
+    if err != nil {
+      return "", err
+    }
+    if !exists {
+      return "", errors.New("source secret does not exist")
+    }
+
+    dest.SecretData = data
+    err = dest.WriteSecret(client)
+    if err != nil {
+      return "", err
+    }
+  }
+
+  if opts.Metadata && bothV2 {
+    logger.LogDebug("Copying secret metadata", "key", source.VaultKey)
+    metadata, err := client.ReadKvMetadata(source)
+    if err != nil {
+      return "", err
+    }
+
+    err = client.WriteKvMetadata(dest, schema.KvV2WriteMetadataRequest{
+      CasRequired: metadata.CasRequired,
+      CustomMetadata: metadata.CustomMetadata,
+      DeleteVersionAfter: metadata.DeleteVersionAfter,
+      MaxVersions: int32(metadata.MaxVersions),
+    })
+    if err != nil {
+      return "", err
+    }
+  }
+
+  data, exists, err := source.ReadCurrentData(client)
+  if err != nil {
+    return "", err
+  }
+  if !exists {
+    return "", errors.New("source secret does not exist")
+  }
+  return HashSecretData(data), nil
+}
+
+/*
+This will copy every readable version of a kv v2 secret
+in order, deleted and destroyed versions are skipped
+*/
+func copySecretVersions(client *VaultClient, source VaultSecret,
+  dest VaultSecret) error {
+
+  metadata, err := client.ReadKvMetadata(source)
+  if err != nil {
+    return err
+  }
+
+  oldest := metadata.OldestVersion
+  if oldest < 1 {
+    oldest = 1
+  }
+
+  for version := oldest; version <= metadata.CurrentVersion; version++ {
+    versionInfo, _ := metadata.Versions[strconv.FormatInt(version, 10)].(map[string]interface{})
+    if versionSkipped(versionInfo) {
+      logger.LogDebug("Skipping deleted or destroyed version", "version", version)
+      continue
+    }
+
+    data, err := client.ReadKvSecretVersion(source, version)
+    if err != nil {
+      if IsNotFoundError(err) {
+        continue
+      }
+      return err
+    }
+
+    dest.SecretData = data
+    _, err = client.WriteKvSecretVersion(dest)
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+/*
+This will check the version metadata to see if a version
+was deleted or destroyed and cannot be read
+*/
+func versionSkipped(versionInfo map[string]interface{}) bool {
+  return versionState(versionInfo) != SecretStateActive
+}
+
+/*
+This will check if a version is deleted at a time, mounts with
+delete_version_after give live versions a deletion time in the
+future so only a deletion time at or before now counts. A
+deletion time that cannot be parsed is not counted so the
+version is still read
+*/
+func versionDeleted(versionInfo map[string]interface{}, now time.Time) bool {
+  deletionTime, ok := versionInfo["deletion_time"].(string)
+  if !ok || deletionTime == "" {
+    return false
+  }
+
+  deletedAt, err := time.Parse(time.RFC3339Nano, deletionTime)
+  if err != nil {
+    logger.LogDebug("Cannot parse version deletion time", "deletionTime", deletionTime)
+    return false
+  }
+  return !deletedAt.After(now)
+}
+
+/*
+This will verify that the destination has the same
+data as the source had when it was copied
+*/
+func verifyCopy(client *VaultClient, source VaultSecret, dest VaultSecret,
+  sourceHash string) error {
+
+  destData, exists, err := dest.ReadCurrentData(client)
+  if err != nil {
+    return err
+  }
+  if !exists {
+    return errors.New("copied secret does not exist")
+  }
+
+  if HashSecretData(destData) != sourceHash {
+    return errors.New("copied secret data does not match the source")
+  }
+
+  sourceData, exists, err := source.ReadCurrentData(client)
+  if err != nil {
+    return err
+  }
+  if exists && HashSecretData(sourceData) != sourceHash {
+    return errors.New("source secret changed while it was being moved")
+  }
+  return nil
+}
+
+/*
+Returns a copy of a journal entry
+*/
+func (j *MoveJournal) entry(index int) MoveJournalEntry {
+  j.lock.Lock()
+  defer j.lock.Unlock()
+  return j.Entries[index]
+}
+
+/*
+This will update a journal entry and save the journal
+*/
+func (j *MoveJournal) update(index int, entry MoveJournalEntry) error {
+  j.lock.Lock()
+  j.Entries[index] = entry
+  j.lock.Unlock()
+  return j.save()
+}
+
+/*
+This will record an error on a journal entry
+*/
+func (j *MoveJournal) setError(index int, err error) {
+  j.lock.Lock()
+  j.Entries[index].Error = err.Error()
+  j.lock.Unlock()
+
+  saveErr := j.save()
+  if saveErr != nil {
+    logger.LogError("Error saving the move journal")
+  }
+}
+
+/*
+This will write the journal to its file
+*/
+func (j *MoveJournal) save() error {
+  j.lock.Lock()
+  defer j.lock.Unlock()
+
+  jsonData, err := json.MarshalIndent(j, "", "  ")
+  if err != nil {
+    logger.LogError("Error marshaling move journal to json")
+    return err
+  }
+
+  err = util.WriteFileAtomic(j.journalPath, jsonData, 0600)
+  if err != nil {
+    logger.LogError("Error writing the move journal")
+    return err
+  }
+  return nil
+}
+
+/*
+Reads a move journal from a file so a move can be
+resumed or reversed
+*/
+func ReadMoveJournal(journalPath string) (*MoveJournal, error) {
+  journal := &MoveJournal{}
+
+  file, err := os.Open(journalPath)
+  if err != nil {
+    logger.LogError("Error opening move journal")
+    return nil, err
+  }
+  defer file.Close()
+
+  bytes, err := io.ReadAll(file)
+  if err != nil {
+    logger.LogError("Error reading move journal")
+    return nil, err
+  }
+
+  err = json.Unmarshal(bytes, journal)
+  if err != nil {
+    logger.LogError("Error unmarshaling json to move journal struct")
+    return nil, err
+  }
+
+  if journal.FormatVersion != MoveJournalFormatVersion {
+    logger.LogError("Error unsupported move journal format version")
+    return nil, fmt.Errorf("unsupported move journal format version %d", journal.FormatVersion)
+  }
+
+  journal.journalPath = journalPath
+  return journal, nil
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgutierrez1287/vault-util/util"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for versionSkipped
+*/
+func TestVersionSkipped(t *testing.T) {
+  assert.False(t, versionSkipped(nil))
+  assert.False(t, versionSkipped(map[string]interface{}{"deletion_time": "", "destroyed": false}))
+  assert.True(t, versionSkipped(map[string]interface{}{"deletion_time": "2024-01-01T00:00:00Z"}))
+  assert.True(t, versionSkipped(map[string]interface{}{"destroyed": true}))
+
+  // delete_version_after gives live versions a future deletion time
+  future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339Nano)
+  assert.False(t, versionSkipped(map[string]interface{}{"deletion_time": future}))
+}
+
+func TestVersionDeleted(t *testing.T) {
+  now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+  assert.False(t, versionDeleted(map[string]interface{}{"deletion_time": "2024-06-02T00:00:00Z"}, now))
+  assert.True(t, versionDeleted(map[string]interface{}{"deletion_time": "2024-06-01T00:00:00Z"}, now))
+  assert.True(t, versionDeleted(map[string]interface{}{"deletion_time": "2024-05-01T00:00:00Z"}, now))
+  assert.False(t, versionDeleted(map[string]interface{}{"deletion_time": "not a time"}, now))
+}
+
+func TestCopySecretVersionsFutureDeletionTime(t *testing.T) {
+  fake, client := newFakeVault(t, map[string]string{"secret/": "2"})
+
+  fake.put("secret/app/old", map[string]interface{}{"value": "1"})
+  fake.put("secret/app/old", map[string]interface{}{"value": "2"})
+  fake.put("secret/app/old", map[string]interface{}{"value": "3"})
+  fake.setDeletionTime("secret/app/old", 1, time.Now().Add(-time.Hour))
+  fake.setDeletionTime("secret/app/old", 2, time.Now().Add(time.Hour))
+  fake.setDeletionTime("secret/app/old", 3, time.Now().Add(time.Hour))
+
+  source, err := NewSecret("secret/app/old", "", "", nil, *client)
+  assert.NoError(t, err)
+  dest, err := NewSecret("secret/app/new", "", "", nil, *client)
+  assert.NoError(t, err)
+
+  err = copySecretVersions(client, source, dest)
+  assert.NoError(t, err)
+  assert.Equal(t, 2, fake.versionCount("secret/app/new"))
+  assert.Equal(t, map[string]interface{}{"value": "3"}, fake.current("secret/app/new"))
+}
+
+/*
+   Tests for move journals
+*/
+func TestMoveJournalRoundTrip(t *testing.T) {
+  journalFile := filepath.Join(util.MockHomeDir, "move.json")
+
+  err := util.MockHomeSetup()
+  assert.NoError(t, err)
+
+  journal, err := NewMoveJournal(nil, "secret/old", "secret/new", false,
+    MoveOptions{AllVersions: true, Force: true}, journalFile)
+  assert.NoError(t, err)
+  assert.Len(t, journal.Entries, 1)
+
+  err = journal.update(0, MoveJournalEntry{
+    From: "secret/old",
+    To: "secret/new",
+    State: MoveStateCopied,
+    SourceHash: "abc",
+    DestChecked: true,
+    DestExisted: true,
+    DestVersion: 3,
+  })
+  assert.NoError(t, err)
+
+  readJournal, err := ReadMoveJournal(journalFile)
+  assert.NoError(t, err)
+  assert.True(t, readJournal.Options.AllVersions)
+  assert.Equal(t, MoveStateCopied, readJournal.Entries[0].State)
+  assert.Equal(t, "abc", readJournal.Entries[0].SourceHash)
+
+  // what was at the destination is kept so a reverse can restore it
+  assert.True(t, readJournal.Options.Force)
+  assert.True(t, readJournal.Entries[0].DestExisted)
+  assert.Equal(t, int64(3), readJournal.Entries[0].DestVersion)
+
+  err = util.MockHomeCleanup()
+  assert.NoError(t, err)
+}
@@ -0,0 +1,486 @@
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/dgutierrez1287/vault-util/util"
+)
+
+// version of the plan document format
+const SecretPlanFormatVersion = 1
+
+/*
+Actions that can be planned for a secret
+*/
+const (
+  PlanActionCreate = "create"
+  PlanActionUpdate = "update"
+  PlanActionDelete = "delete"
+  PlanActionUnchanged = "unchanged"
+  PlanActionExists = "exists"
+)
+
+/*
+Actions that can be planned for a single field
+of a secret
+*/
+const (
+  FieldActionAdd = "add"
+  FieldActionChange = "change"
+  FieldActionRemove = "remove"
+)
+
+/*
+FieldChange - a change to a single field of
+a secret, the old and new values are only kept
+in memory for console output
+*/
+type FieldChange struct {
+  Field string                  `json:"field"`
+  Action string                 `json:"action"`
+  OldValue interface{}          `json:"-"`
+  NewValue interface{}          `json:"-"`
+}
+
+/*
+SecretPlanEntry - the planned action for
+a single secret, the secret has the data that
+will be written so it is in the plan document.
+Deletes are soft deletes unless destroy is set
+*/
+type SecretPlanEntry struct {
+  Name string                   `json:"name"`
+  Action string                 `json:"action"`
+  Changes []FieldChange         `json:"changes,omitempty"`
+  CurrentHash string            `json:"currentHash,omitempty"`
+  Destroy bool                  `json:"destroy,omitempty"`
+  Secret VaultSecret            `json:"secret"`
+}
+
+/*
+SecretPlan - a plan of the changes that would
+be made to vault, this is the document that is
+written with --plan-out and read with --apply-plan.
+The document has the plaintext values that will be
+written so it must be kept as safe as the secrets
+*/
+type SecretPlan struct {
+  FormatVersion int             `json:"formatVersion"`
+  CreatedAt time.Time           `json:"createdAt"`
+  SecretsFile string            `json:"secretsFile,omitempty"`
+  Entries []SecretPlanEntry     `json:"entries"`
+}
+
+/*
+This will build a plan for the secrets by reading the
+current value of every target key and comparing it to
+the data that would be written
+*/
+func BuildSecretPlan(secrets VaultSecrets, secretsFile string,
+  client *VaultClient) (SecretPlan, error) {
+
+  plan := SecretPlan{
+    FormatVersion: SecretPlanFormatVersion,
+    CreatedAt: time.Now().UTC(),
+    SecretsFile: secretsFile,
+  }
+
+  var lock sync.Mutex
+  entries := make(map[string]SecretPlanEntry)
+
+  results := RunBulk(secrets.SecretNames(), client.Concurrency(), func(name string) error {
+    secret := secrets.Secrets[name]
+
+    logger.LogDebug("Planning secret", "name", name, "key", secret.VaultKey)
+    current, exists, err := secret.ReadCurrentData(client)
+    if err != nil {
+      logger.LogError("Error reading current secret data for plan")
+      return fmt.Errorf("%s: %w", secret.VaultKey, err)
+    }
+
+    lock.Lock()
+    entries[name] = planEntry(name, secret, current, exists)
+    lock.Unlock()
+    return nil
+  })
+
+  for _, result := range results {
+    if result.Err != nil {
+      return plan, result.Err
+    }
+    plan.Entries = append(plan.Entries, entries[result.Key])
+  }
+  return plan, nil
+}
+
+/*
+This will build the plan entry for a single secret
+given the current data in vault
+*/
+func planEntry(name string, secret VaultSecret, current map[string]interface{},
+  exists bool) SecretPlanEntry {
+
+  entry := SecretPlanEntry{
+    Name: name,
+    Secret: secret,
+  }
+
+  if !exists {
+    logger.LogDebug("Secret does not exist, planning create", "key", secret.VaultKey)
+    entry.Action = PlanActionCreate
+    entry.Changes = DiffSecretData(nil, secret.SecretData)
+    return entry
+  }
+
+  entry.CurrentHash = HashSecretData(current)
+  entry.Changes = DiffSecretData(current, secret.SecretData)
+
+  if len(entry.Changes) == 0 {
+    logger.LogDebug("Secret is unchanged", "key", secret.VaultKey)
+    entry.Action = PlanActionUnchanged
+  } else {
+    logger.LogDebug("Secret has changes, planning update", "key", secret.VaultKey)
+    entry.Action = PlanActionUpdate
+  }
+  return entry
+}
+
+/*
+This will compare the current and desired data for a
+secret and return the field level changes sorted by
+field name
+*/
+func DiffSecretData(current map[string]interface{},
+  desired map[string]interface{}) []FieldChange {
+
+  var changes []FieldChange
+
+  for field, newValue := range desired {
+    oldValue, ok := current[field]
+    if !ok {
+      changes = append(changes, FieldChange{
+        Field: field,
+        Action: FieldActionAdd,
+        NewValue: newValue,
+      })
+    } else if !valuesEqual(oldValue, newValue) {
+      changes = append(changes, FieldChange{
+        Field: field,
+        Action: FieldActionChange,
+        OldValue: oldValue,
+        NewValue: newValue,
+      })
+    }
+  }
+
+  for field, oldValue := range current {
+    if _, ok := desired[field]; !ok {
+      changes = append(changes, FieldChange{
+        Field: field,
+        Action: FieldActionRemove,
+        OldValue: oldValue,
+      })
+    }
+  }
+
+  sort.Slice(changes, func(i, j int) bool {
+    return changes[i].Field < changes[j].Field
+  })
+  return changes
+}
+
+/*
+This compares two values by their json encoding so
+numbers read from vault and numbers read from a file
+are treated the same
+*/
+func valuesEqual(a interface{}, b interface{}) bool {
+  aBytes, aErr := json.Marshal(a)
+  bBytes, bErr := json.Marshal(b)
+  if aErr != nil || bErr != nil {
+    return false
+  }
+  return bytes.Equal(aBytes, bBytes)
+}
+
+/*
+This will return a sha256 hash of secret data so the
+plan can record what the data looked like without
+storing the values
+*/
+func HashSecretData(data map[string]interface{}) string {
+  jsonBytes, err := json.Marshal(data)
+  if err != nil {
+    return ""
+  }
+  sum := sha256.Sum256(jsonBytes)
+  return hex.EncodeToString(sum[:])
+}
+
+/*
+PlanApplyResult - the results of applying
+a plan
+*/
+type PlanApplyResult struct {
+  Applied []string
+  Deleted []string
+  Unchanged []string
+  Existing []string
+  Skipped []string
+  Errors []SecretActionError
+}
+
+/*
+This will return the number of creates, updates, deletes
+and unchanged secrets in the plan
+*/
+func (p SecretPlan) Counts() (int, int, int, int) {
+  var creates, updates, deletes, unchanged int
+
+  for _, entry := range p.Entries {
+    switch entry.Action {
+    case PlanActionCreate:
+      creates++
+    case PlanActionUpdate:
+      updates++
+    case PlanActionDelete:
+      deletes++
+    case PlanActionUnchanged:
+      unchanged++
+    }
+  }
+  return creates, updates, deletes, unchanged
+}
+
+/*
+This will make a plan that only creates secrets, secrets
+that would be updated are left as they are and marked as
+existing
+*/
+func (p SecretPlan) CreateOnly() SecretPlan {
+  createOnly := p
+  createOnly.Entries = make([]SecretPlanEntry, len(p.Entries))
+  for i, entry := range p.Entries {
+    if entry.Action == PlanActionUpdate {
+      logger.LogDebug("Secret exists, not updating it", "key", entry.Secret.VaultKey)
+      entry.Action = PlanActionExists
+      entry.Changes = nil
+    }
+    createOnly.Entries[i] = entry
+  }
+  return createOnly
+}
+
+/*
+This will build the plan entry to delete a secret
+given the current data in vault, with destroy every
+version and the metadata are removed
+*/
+func deletePlanEntry(name string, secret VaultSecret,
+  current map[string]interface{}, destroy bool) SecretPlanEntry {
+
+  secret.SecretData = nil
+  return SecretPlanEntry{
+    Name: name,
+    Action: PlanActionDelete,
+    Changes: DiffSecretData(current, nil),
+    CurrentHash: HashSecretData(current),
+    Destroy: destroy,
+    Secret: secret,
+  }
+}
+
+/*
+This will apply a plan, unchanged secrets are skipped so
+no new kv v2 versions are created. Before writing, the current
+data is checked against the plan so a secret that changed
+since the plan was made is not overwritten
+*/
+func (p SecretPlan) Apply(client *VaultClient) PlanApplyResult {
+  return p.ApplyWithCheckpoint(client, nil)
+}
+
+/*
+This will apply a plan like Apply, entries the checkpoint has
+as done are skipped and every entry applied is recorded in
+the checkpoint
+*/
+func (p SecretPlan) ApplyWithCheckpoint(client *VaultClient,
+  checkpoint *Checkpoint) PlanApplyResult {
+
+  var result PlanApplyResult
+
+  entries := make(map[string]SecretPlanEntry)
+  var names []string
+  for _, entry := range p.Entries {
+    if entry.Action == PlanActionUnchanged {
+      logger.LogDebug("Secret unchanged, skipping", "key", entry.Secret.VaultKey)
+      result.Unchanged = append(result.Unchanged, entry.Name)
+      continue
+    }
+    if entry.Action == PlanActionExists {
+      logger.LogDebug("Secret exists, skipping", "key", entry.Secret.VaultKey)
+      result.Existing = append(result.Existing, entry.Name)
+      continue
+    }
+    if checkpoint != nil && checkpoint.IsDone(entry.Name) {
+      logger.LogDebug("Secret already applied, skipping", "key", entry.Secret.VaultKey)
+      result.Skipped = append(result.Skipped, entry.Name)
+      continue
+    }
+    entries[entry.Name] = entry
+    names = append(names, entry.Name)
+  }
+
+  results := RunBulk(names, client.Concurrency(), func(name string) error {
+    entry := entries[name]
+
+    logger.LogDebug("Checking secret has not changed since plan", "key", entry.Secret.VaultKey)
+    err := entry.checkDrift(client)
+    if err != nil {
+      logger.LogError("Error secret does not match plan")
+      return err
+    }
+
+    logger.LogDebug("Applying plan for secret", "key", entry.Secret.VaultKey,
+      "action", entry.Action)
+    if entry.Action == PlanActionDelete && entry.Destroy {
+      err = entry.Secret.DeleteSecret(client)
+    } else if entry.Action == PlanActionDelete {
+      err = entry.Secret.SoftDeleteSecret(client)
+    } else {
+      err = entry.Secret.WriteSecret(client)
+    }
+
+    if err == nil && checkpoint != nil {
+      saveErr := checkpoint.MarkDone(name)
+      if saveErr != nil {
+        logger.LogError("Error saving the checkpoint")
+      }
+    }
+    return err
+  })
+
+  succeeded, failed := SplitBulkResults(results)
+  for _, name := range succeeded {
+    if entries[name].Action == PlanActionDelete {
+      result.Deleted = append(result.Deleted, name)
+    } else {
+      result.Applied = append(result.Applied, name)
+    }
+  }
+
+  for _, failure := range failed {
+    result.Errors = append(result.Errors, SecretActionError{
+      VaultKey: entries[failure.Key].Secret.VaultKey,
+      Error: failure.Err,
+    })
+  }
+  return result
+}
+
+/*
+This will check that the current data for a secret is
+still what it was when the plan was made
+*/
+func (e SecretPlanEntry) checkDrift(client *VaultClient) error {
+  current, exists, err := e.Secret.ReadCurrentData(client)
+  if err != nil {
+    return err
+  }
+
+  if e.Action == PlanActionCreate {
+    if exists {
+      return errors.New("secret was created after the plan was made")
+    }
+    return nil
+  }
+
+  if !exists {
+    return errors.New("secret was deleted after the plan was made")
+  }
+
+  if HashSecretData(current) != e.CurrentHash {
+    return errors.New("secret was changed after the plan was made")
+  }
+  return nil
+}
+
+/*
+Writes the plan document to a file, the file has the
+plaintext secret values so only the owner can read it
+*/
+func WritePlanFile(planFilePath string, plan SecretPlan) error {
+
+  jsonData, err := json.MarshalIndent(plan, "", "  ")
+  if err != nil {
+    logger.LogError("Error marshaling plan to json")
+    return err
+  }
+
+  // written atomically so an existing file also ends up 0600
+  err = util.WriteFileAtomic(planFilePath, jsonData, 0600)
+  if err != nil {
+    logger.LogError("Error writing the plan file")
+    return err
+  }
+  return nil
+}
+
+/*
+Reads a plan document from a file
+*/
+func ReadPlanFile(planFilePath string) (SecretPlan, error) {
+  var plan SecretPlan
+
+  file, err := os.Open(planFilePath)
+  if err != nil {
+    logger.LogError("Error opening plan file")
+    return plan, err
+  }
+  defer file.Close()
+
+  bytes, err := io.ReadAll(file)
+  if err != nil {
+    logger.LogError("Error reading plan file")
+    return plan, err
+  }
+
+  err = json.Unmarshal(bytes, &plan)
+  if err != nil {
+    logger.LogError("Error unmarshaling json to plan struct")
+    return plan, err
+  }
+
+  if plan.FormatVersion != SecretPlanFormatVersion {
+    logger.LogError("Error unsupported plan format version")
+    return plan, fmt.Errorf("unsupported plan format version %d", plan.FormatVersion)
+  }
+  return plan, nil
+}
+
+/*
+This will return a copy of the plan without any secret
+data so it can be output without exposing values
+*/
+func (p SecretPlan) Redacted() SecretPlan {
+  redacted := p
+  redacted.Entries = make([]SecretPlanEntry, len(p.Entries))
+
+  for i, entry := range p.Entries {
+    entry.Secret.SecretData = nil
+    redacted.Entries[i] = entry
+  }
+  return redacted
+}
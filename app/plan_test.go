@@ -0,0 +1,178 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgutierrez1287/vault-util/util"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for DiffSecretData
+*/
+func TestDiffSecretDataCreate(t *testing.T) {
+  desired := map[string]interface{}{
+    "user": "admin",
+    "password": "secret",
+  }
+
+  changes := DiffSecretData(nil, desired)
+  assert.Len(t, changes, 2)
+  assert.Equal(t, "password", changes[0].Field)
+  assert.Equal(t, FieldActionAdd, changes[0].Action)
+  assert.Equal(t, "user", changes[1].Field)
+  assert.Equal(t, FieldActionAdd, changes[1].Action)
+}
+
+func TestDiffSecretDataUpdate(t *testing.T) {
+  current := map[string]interface{}{
+    "user": "admin",
+    "password": "old",
+    "removed": "value",
+  }
+  desired := map[string]interface{}{
+    "user": "admin",
+    "password": "new",
+    "added": "value",
+  }
+
+  changes := DiffSecretData(current, desired)
+  assert.Len(t, changes, 3)
+  assert.Equal(t, "added", changes[0].Field)
+  assert.Equal(t, FieldActionAdd, changes[0].Action)
+  assert.Equal(t, "password", changes[1].Field)
+  assert.Equal(t, FieldActionChange, changes[1].Action)
+  assert.Equal(t, "old", changes[1].OldValue)
+  assert.Equal(t, "new", changes[1].NewValue)
+  assert.Equal(t, "removed", changes[2].Field)
+  assert.Equal(t, FieldActionRemove, changes[2].Action)
+}
+
+func TestDiffSecretDataNumbers(t *testing.T) {
+  current := map[string]interface{}{
+    "port": json.Number("5432"),
+  }
+  desired := map[string]interface{}{
+    "port": float64(5432),
+  }
+
+  changes := DiffSecretData(current, desired)
+  assert.Empty(t, changes)
+}
+
+/*
+   Tests for planEntry
+*/
+func TestPlanEntryActions(t *testing.T) {
+  secret := VaultSecret{
+    VaultKey: "secret/app",
+    SecretData: map[string]interface{}{"password": "new"},
+  }
+
+  entry := planEntry("app", secret, nil, false)
+  assert.Equal(t, PlanActionCreate, entry.Action)
+  assert.Equal(t, "", entry.CurrentHash)
+
+  current := map[string]interface{}{"password": "new"}
+  entry = planEntry("app", secret, current, true)
+  assert.Equal(t, PlanActionUnchanged, entry.Action)
+  assert.Equal(t, HashSecretData(current), entry.CurrentHash)
+
+  current = map[string]interface{}{"password": "old"}
+  entry = planEntry("app", secret, current, true)
+  assert.Equal(t, PlanActionUpdate, entry.Action)
+  assert.Len(t, entry.Changes, 1)
+}
+
+/*
+   Tests for plan files
+*/
+func TestPlanFileRoundTrip(t *testing.T) {
+  planFile := filepath.Join(util.MockHomeDir, "plan.json")
+
+  err := util.MockHomeSetup()
+  assert.NoError(t, err)
+
+  plan := SecretPlan{
+    FormatVersion: SecretPlanFormatVersion,
+    Entries: []SecretPlanEntry{
+      {
+        Name: "app",
+        Action: PlanActionUpdate,
+        Changes: []FieldChange{
+          {Field: "password", Action: FieldActionChange, OldValue: "old", NewValue: "new"},
+        },
+        Secret: VaultSecret{
+          VaultKey: "secret/app",
+          SecretData: map[string]interface{}{"password": "new"},
+        },
+      },
+    },
+  }
+
+  // the plan has secret values so an existing file is made owner only
+  err = os.WriteFile(planFile, []byte("{}"), 0644)
+  assert.NoError(t, err)
+
+  err = WritePlanFile(planFile, plan)
+  assert.NoError(t, err)
+
+  info, err := os.Stat(planFile)
+  assert.NoError(t, err)
+  assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+  readPlan, err := ReadPlanFile(planFile)
+  assert.NoError(t, err)
+  assert.Len(t, readPlan.Entries, 1)
+  assert.Equal(t, "new", readPlan.Entries[0].Secret.SecretData["password"])
+  assert.Nil(t, readPlan.Entries[0].Changes[0].OldValue)
+  assert.Nil(t, readPlan.Entries[0].Changes[0].NewValue)
+
+  err = util.MockHomeCleanup()
+  assert.NoError(t, err)
+}
+
+func TestPlanRedacted(t *testing.T) {
+  plan := SecretPlan{
+    Entries: []SecretPlanEntry{
+      {
+        Name: "app",
+        Secret: VaultSecret{
+          VaultKey: "secret/app",
+          SecretData: map[string]interface{}{"password": "new"},
+        },
+      },
+    },
+  }
+
+  redacted := plan.Redacted()
+  assert.Nil(t, redacted.Entries[0].Secret.SecretData)
+  assert.NotNil(t, plan.Entries[0].Secret.SecretData)
+}
+
+func TestPlanCreateOnly(t *testing.T) {
+  desired := map[string]interface{}{"user": "admin"}
+  plan := SecretPlan{
+    Entries: []SecretPlanEntry{
+      planEntry("new", VaultSecret{VaultKey: "kv/new", SecretData: desired}, nil, false),
+      planEntry("changed", VaultSecret{VaultKey: "kv/changed", SecretData: desired},
+        map[string]interface{}{"user": "root"}, true),
+      planEntry("same", VaultSecret{VaultKey: "kv/same", SecretData: desired}, desired, true),
+    },
+  }
+
+  createOnly := plan.CreateOnly()
+  assert.Equal(t, PlanActionCreate, createOnly.Entries[0].Action)
+  assert.Equal(t, PlanActionExists, createOnly.Entries[1].Action)
+  assert.Empty(t, createOnly.Entries[1].Changes)
+  assert.Equal(t, PlanActionUnchanged, createOnly.Entries[2].Action)
+
+  // the plan it was made from is not changed
+  assert.Equal(t, PlanActionUpdate, plan.Entries[1].Action)
+
+  creates, updates, deletes, unchanged := createOnly.Counts()
+  assert.Equal(t, []int{1, 0, 0, 1}, []int{creates, updates, deletes, unchanged})
+}
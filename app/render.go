@@ -0,0 +1,482 @@
+package app
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/dgutierrez1287/vault-util/util"
+)
+
+/*
+RenderTemplate - a template file and where it is rendered to
+*/
+type RenderTemplate struct {
+  Source string                 `json:"source"`
+  Destination string            `json:"destination"`
+  Mode os.FileMode              `json:"mode"`
+}
+
+/*
+RenderOptions - the templates to render, in watch mode the
+secrets and prefixes the templates read are polled and the
+templates are rendered again when one changes, the reload
+command is run after files change
+*/
+type RenderOptions struct {
+  Templates []RenderTemplate
+  Watch bool
+  PollInterval time.Duration
+  ReloadCommand string
+}
+
+/*
+RenderResult - a rendered file and if it changed
+*/
+type RenderResult struct {
+  Source string                 `json:"source"`
+  Destination string            `json:"destination"`
+  Changed bool                  `json:"changed"`
+}
+
+/*
+RenderedSecrets - the secrets templates read with the version
+that was read, and the full keys listed under each prefix
+*/
+type RenderedSecrets struct {
+  Secrets []VaultSecret
+  Versions map[string]int64
+  Listings map[string][]string
+}
+
+/*
+templateSecrets - reads secrets for templates, every secret
+is read once per render and the secrets read and prefixes
+listed are kept so they can be watched
+*/
+type templateSecrets struct {
+  read func(key string) (map[string]interface{}, int64, error)
+  list func(prefix string) (map[string]string, error)
+  data map[string]map[string]interface{}
+  versions map[string]int64
+  listings map[string][]string
+}
+
+/*
+This will parse a template in the form source:destination
+or source:destination:mode, the mode is octal
+*/
+func ParseRenderTemplate(spec string, defaultMode os.FileMode) (RenderTemplate, error) {
+  parts := strings.Split(spec, ":")
+  if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+    return RenderTemplate{}, fmt.Errorf("template %s must be source:destination or source:destination:mode", spec)
+  }
+
+  renderTemplate := RenderTemplate{
+    Source: parts[0],
+    Destination: parts[1],
+    Mode: defaultMode,
+  }
+  if len(parts) == 3 {
+    mode, err := ParseFileMode(parts[2])
+    if err != nil {
+      return RenderTemplate{}, fmt.Errorf("template %s: %w", spec, err)
+    }
+    renderTemplate.Mode = mode
+  }
+  return renderTemplate, nil
+}
+
+/*
+This will parse an octal file mode like 0640
+*/
+func ParseFileMode(mode string) (os.FileMode, error) {
+  parsed, err := strconv.ParseUint(mode, 8, 32)
+  if err != nil || parsed > 0777 {
+    return 0, fmt.Errorf("mode %s must be octal permissions like 0640", mode)
+  }
+  return os.FileMode(parsed), nil
+}
+
+/*
+This will render every template once, in watch mode it keeps
+polling the versions of the secrets the templates read and the
+keys under the prefixes they listed, and renders again when they
+change. The versions are the ones that were read so a write
+during a render is still seen. The reload command is run
+each time a rendered file changes, in watch mode a render or
+reload command that fails is tried again on the next poll
+*/
+func Render(client *VaultClient, opts RenderOptions,
+  rendered func([]RenderResult)) error {
+
+  if len(opts.Templates) == 0 {
+    return errors.New("at least one template is required")
+  }
+  if opts.Watch && opts.PollInterval <= 0 {
+    return errors.New("the poll interval must be more than 0")
+  }
+
+  results, secrets, err := RenderTemplates(client, opts.Templates)
+  if err != nil {
+    return err
+  }
+  watcher := NewVersionWatcherAt(client, secrets.Secrets, secrets.Versions)
+  rendered(results)
+
+  // a failed render or reload is tried again on every poll
+  renderFailed := false
+  reloadPending := false
+  if anyChanged(results) {
+    err = runHook(opts.ReloadCommand)
+    if err != nil && !opts.Watch {
+      return err
+    }
+    reloadPending = err != nil
+  }
+
+  if !opts.Watch {
+    return nil
+  }
+
+  ticker := time.NewTicker(opts.PollInterval)
+  defer ticker.Stop()
+  for range ticker.C {
+    changed, err := watcher.Changed()
+    if err != nil {
+      logger.LogError(fmt.Sprintf("Error checking secret versions, will check again: %s", err))
+      continue
+    }
+
+    listed, err := listingsChanged(client, secrets.Listings)
+    if err != nil {
+      logger.LogError(fmt.Sprintf("Error listing watched prefixes, will check again: %s", err))
+      continue
+    }
+    changed = append(changed, listed...)
+
+    if len(changed) > 0 || renderFailed {
+      if len(changed) > 0 {
+        logger.LogInfo("Secrets changed, rendering templates", "keys", changed)
+      } else {
+        logger.LogInfo("Rendering templates again after the last render failed")
+      }
+
+      newResults, newSecrets, err := RenderTemplates(client, opts.Templates)
+      renderFailed = err != nil
+      if renderFailed {
+        logger.LogError(fmt.Sprintf("Error rendering templates, will try again on the next poll: %s", err))
+      } else {
+        results, secrets = newResults, newSecrets
+        rendered(results)
+
+        // templates can read different secrets after a change
+        watcher = NewVersionWatcherAt(client, secrets.Secrets, secrets.Versions)
+
+        reloadPending = reloadPending || anyChanged(results)
+      }
+    }
+
+    if reloadPending {
+      err = runHook(opts.ReloadCommand)
+      if err != nil {
+        logger.LogError("Error running the reload command, will try again on the next poll")
+      }
+      reloadPending = err != nil
+    }
+  }
+  return nil
+}
+
+/*
+This will render templates and write the files that changed,
+every template is rendered before anything is written so a
+failing template does not leave some files updated. The secrets
+the templates read and the prefixes they listed are returned
+*/
+func RenderTemplates(client *VaultClient, templates []RenderTemplate) ([]RenderResult,
+  RenderedSecrets, error) {
+
+  var secrets RenderedSecrets
+
+  reader := newTemplateSecrets(client)
+
+  contents := make([][]byte, len(templates))
+  for i, renderTemplate := range templates {
+    logger.LogDebug("Rendering template", "source", renderTemplate.Source)
+    content, err := reader.render(renderTemplate.Source)
+    if err != nil {
+      return nil, secrets, err
+    }
+    contents[i] = content
+  }
+
+  var results []RenderResult
+  for i, renderTemplate := range templates {
+    changed, err := writeIfChanged(renderTemplate.Destination, contents[i],
+      renderTemplate.Mode, -1, -1)
+    if err != nil {
+      logger.LogError("Error writing rendered template")
+      return results, secrets, fmt.Errorf("%s: %w", renderTemplate.Destination, err)
+    }
+    results = append(results, RenderResult{
+      Source: renderTemplate.Source,
+      Destination: renderTemplate.Destination,
+      Changed: changed,
+    })
+  }
+
+  for _, key := range reader.keys() {
+    secret, err := NewSecret(key, "", "", nil, *client)
+    if err != nil {
+      return results, secrets, err
+    }
+    secrets.Secrets = append(secrets.Secrets, secret)
+  }
+  secrets.Versions = reader.versions
+  secrets.Listings = reader.listings
+  return results, secrets, nil
+}
+
+/*
+This will create a reader that reads secrets from vault
+*/
+func newTemplateSecrets(client *VaultClient) *templateSecrets {
+  return &templateSecrets{
+    read: func(key string) (map[string]interface{}, int64, error) {
+      secret, err := NewSecret(key, "", "", nil, *client)
+      if err != nil {
+        return nil, 0, err
+      }
+      if secret.SecretType != "kv" {
+        return nil, 0, errors.New("only kv secrets can be used in templates")
+      }
+      return client.ReadKvSecretWithVersion(secret)
+    },
+    list: func(prefix string) (map[string]string, error) {
+      return listSubtree(client, prefix)
+    },
+    data: make(map[string]map[string]interface{}),
+    versions: make(map[string]int64),
+    listings: make(map[string][]string),
+  }
+}
+
+/*
+This will render a template file
+*/
+func (t *templateSecrets) render(source string) ([]byte, error) {
+  text, err := os.ReadFile(source)
+  if err != nil {
+    logger.LogError("Error reading template file")
+    return nil, err
+  }
+
+  tmpl, err := template.New(filepath.Base(source)).Option("missingkey=error").
+    Funcs(t.funcs()).Parse(string(text))
+  if err != nil {
+    logger.LogError("Error parsing template")
+    return nil, err
+  }
+
+  var buffer bytes.Buffer
+  err = tmpl.Execute(&buffer, nil)
+  if err != nil {
+    logger.LogError("Error rendering template")
+    return nil, err
+  }
+  return buffer.Bytes(), nil
+}
+
+/*
+This will get the functions templates can use, secret gets a
+field of a secret or all of its data without a field, secrets
+gets the data of every secret under a prefix keyed by the key
+relative to the prefix
+*/
+func (t *templateSecrets) funcs() template.FuncMap {
+  return template.FuncMap{
+    "secret": func(key string, field ...string) (interface{}, error) {
+      if len(field) > 1 {
+        return nil, errors.New("secret takes a key and an optional field")
+      }
+      data, err := t.secret(key)
+      if err != nil {
+        return nil, err
+      }
+      if len(field) == 0 {
+        return data, nil
+      }
+      value, err := ExtractField(data, field[0])
+      if err != nil {
+        return nil, fmt.Errorf("%s: %w", key, err)
+      }
+      return value, nil
+    },
+    "secrets": func(prefix string) (map[string]map[string]interface{}, error) {
+      keys, err := t.list(prefix)
+      if err != nil {
+        return nil, fmt.Errorf("%s: %w", prefix, err)
+      }
+      t.listings[strings.Trim(prefix, "/")] = listingKeys(keys)
+      secrets := make(map[string]map[string]interface{})
+      for relativeKey, fullKey := range keys {
+        data, err := t.secret(fullKey)
+        if err != nil {
+          return nil, err
+        }
+        secrets[relativeKey] = data
+      }
+      return secrets, nil
+    },
+    "base64": func(value interface{}) string {
+      return base64.StdEncoding.EncodeToString([]byte(searchText(value)))
+    },
+    "toJSON": toJSON,
+  }
+}
+
+/*
+This will read a secret, a secret is only read once
+*/
+func (t *templateSecrets) secret(key string) (map[string]interface{}, error) {
+  key = strings.Trim(key, "/")
+  if data, ok := t.data[key]; ok {
+    return data, nil
+  }
+
+  data, version, err := t.read(key)
+  if err != nil {
+    return nil, fmt.Errorf("%s: %w", key, err)
+  }
+  t.data[key] = data
+  t.versions[key] = version
+  return data, nil
+}
+
+/*
+This will get the keys of the secrets that were read
+*/
+func (t *templateSecrets) keys() []string {
+  keys := make([]string, 0, len(t.data))
+  for key := range t.data {
+    keys = append(keys, key)
+  }
+  sort.Strings(keys)
+  return keys
+}
+
+/*
+This will get the sorted full keys of a listing
+*/
+func listingKeys(keys map[string]string) []string {
+  fullKeys := make([]string, 0, len(keys))
+  for _, fullKey := range keys {
+    fullKeys = append(fullKeys, fullKey)
+  }
+  sort.Strings(fullKeys)
+  return fullKeys
+}
+
+/*
+This will list the prefixes again and get the ones whose keys
+changed, so secrets added or removed under a prefix are seen
+*/
+func listingsChanged(client *VaultClient, listings map[string][]string) ([]string, error) {
+  var changed []string
+  for prefix, lastKeys := range listings {
+    keys, err := listSubtree(client, prefix)
+    if err != nil {
+      return nil, fmt.Errorf("%s: %w", prefix, err)
+    }
+    if !slices.Equal(lastKeys, listingKeys(keys)) {
+      logger.LogDebug("Secrets under prefix changed", "prefix", prefix)
+      changed = append(changed, prefix)
+    }
+  }
+  sort.Strings(changed)
+  return changed, nil
+}
+
+/*
+This will marshal a value to json for templates
+*/
+func toJSON(value interface{}) (string, error) {
+  jsonBytes, err := json.Marshal(value)
+  return string(jsonBytes), err
+}
+
+/*
+This will write a file only when its content or permissions
+are different, the file is written atomically. A uid or gid
+of -1 leaves the owner as it is, an unchanged file is still
+given the owner
+*/
+func writeIfChanged(filePath string, content []byte, mode os.FileMode,
+  uid int, gid int) (bool, error) {
+
+  existing, err := os.ReadFile(filePath)
+  if err == nil && bytes.Equal(existing, content) {
+    info, err := os.Stat(filePath)
+    if err == nil && info.Mode().Perm() == mode.Perm() {
+      logger.LogDebug("File has not changed", "file", filePath)
+      if uid != -1 || gid != -1 {
+        return false, os.Chown(filePath, uid, gid)
+      }
+      return false, nil
+    }
+  }
+
+  logger.LogDebug("Writing file", "file", filePath)
+  err = util.WriteFileAtomicOwner(filePath, content, mode, uid, gid)
+  if err != nil {
+    return false, err
+  }
+  return true, nil
+}
+
+/*
+This will check if any rendered file changed
+*/
+func anyChanged(results []RenderResult) bool {
+  for _, result := range results {
+    if result.Changed {
+      return true
+    }
+  }
+  return false
+}
+
+/*
+This will run a hook command with the shell, the output of
+the command goes to stderr so it is not mixed with the
+output of vault-util
+*/
+func runHook(command string) error {
+  if command == "" {
+    return nil
+  }
+
+  logger.LogInfo("Running command", "command", command)
+  hook := exec.Command("sh", "-c", command)
+  hook.Stdout = os.Stderr
+  hook.Stderr = os.Stderr
+  err := hook.Run()
+  if err != nil {
+    logger.LogError(fmt.Sprintf("Error running command %s: %s", command, err))
+    return fmt.Errorf("command %s: %w", command, err)
+  }
+  return nil
+}
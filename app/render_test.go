@@ -0,0 +1,157 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for rendering templates
+*/
+func TestParseRenderTemplate(t *testing.T) {
+  renderTemplate, err := ParseRenderTemplate("app.tmpl:/etc/app.conf", 0600)
+  assert.NoError(t, err)
+  assert.Equal(t, RenderTemplate{Source: "app.tmpl", Destination: "/etc/app.conf", Mode: 0600}, renderTemplate)
+
+  renderTemplate, err = ParseRenderTemplate("app.tmpl:/etc/app.conf:0644", 0600)
+  assert.NoError(t, err)
+  assert.Equal(t, os.FileMode(0644), renderTemplate.Mode)
+
+  for _, bad := range []string{"app.tmpl", ":out", "app.tmpl:", "a:b:rw", "a:b:1777", "a:b:0600:x"} {
+    _, err = ParseRenderTemplate(bad, 0600)
+    assert.Error(t, err, bad)
+  }
+}
+
+func TestTemplateFuncs(t *testing.T) {
+  reads := 0
+  secrets := &templateSecrets{
+    read: func(key string) (map[string]interface{}, int64, error) {
+      reads++
+      switch key {
+      case "kv/app/db":
+        return map[string]interface{}{
+          "user": "admin",
+          "password": "hunter2",
+          "hosts": []interface{}{"a", "b"},
+        }, 3, nil
+      case "kv/app/cache":
+        return map[string]interface{}{"url": "redis://cache"}, 1, nil
+      }
+      return nil, 0, errors.New("secret not found")
+    },
+    list: func(prefix string) (map[string]string, error) {
+      return map[string]string{"db": "kv/app/db", "cache": "kv/app/cache"}, nil
+    },
+    data: make(map[string]map[string]interface{}),
+    versions: make(map[string]int64),
+    listings: make(map[string][]string),
+  }
+
+  dir := t.TempDir()
+  source := filepath.Join(dir, "app.tmpl")
+  err := os.WriteFile(source, []byte(
+    `user={{ secret "kv/app/db" "user" }}
+password={{ secret "/kv/app/db/" "password" | base64 }}
+host={{ secret "kv/app/db" "hosts[1]" }}
+hosts={{ secret "kv/app/db" "hosts" | toJSON }}
+{{ range $key, $data := secrets "kv/app" }}{{ $key }}={{ len $data }}
+{{ end }}`), 0600)
+  assert.NoError(t, err)
+
+  content, err := secrets.render(source)
+  assert.NoError(t, err)
+  assert.Equal(t, `user=admin
+password=aHVudGVyMg==
+host=b
+hosts=["a","b"]
+cache=1
+db=3
+`, string(content))
+
+  // every secret is only read once
+  assert.Equal(t, 2, reads)
+  assert.Equal(t, []string{"kv/app/cache", "kv/app/db"}, secrets.keys())
+  assert.Equal(t, map[string]int64{"kv/app/db": 3, "kv/app/cache": 1}, secrets.versions)
+  assert.Equal(t, map[string][]string{"kv/app": {"kv/app/cache", "kv/app/db"}}, secrets.listings)
+
+  err = os.WriteFile(source, []byte(`{{ secret "kv/app/db" "missing" }}`), 0600)
+  assert.NoError(t, err)
+  _, err = secrets.render(source)
+  assert.Error(t, err)
+
+  err = os.WriteFile(source, []byte(`{{ secret "kv/app/other" "user" }}`), 0600)
+  assert.NoError(t, err)
+  _, err = secrets.render(source)
+  assert.Error(t, err)
+}
+
+func TestRenderTemplatesWatchVersions(t *testing.T) {
+  fake, client := newFakeVault(t, map[string]string{"secret/": "2"})
+
+  fake.put("secret/app/db", map[string]interface{}{"password": "a"})
+  fake.put("secret/app/db", map[string]interface{}{"password": "b"})
+
+  dir := t.TempDir()
+  source := filepath.Join(dir, "app.tmpl")
+  err := os.WriteFile(source, []byte(
+    `password={{ secret "secret/app/db" "password" }}
+{{ range $key, $data := secrets "secret/app" }}{{ $key }}
+{{ end }}`), 0600)
+  assert.NoError(t, err)
+
+  results, secrets, err := RenderTemplates(client, []RenderTemplate{
+    {Source: source, Destination: filepath.Join(dir, "app.conf"), Mode: 0600},
+  })
+  assert.NoError(t, err)
+  assert.True(t, anyChanged(results))
+  assert.Equal(t, map[string]int64{"secret/app/db": 2}, secrets.Versions)
+  assert.Equal(t, map[string][]string{"secret/app": {"secret/app/db"}}, secrets.Listings)
+
+  // a write after the render but before the watcher is made is still seen
+  fake.put("secret/app/db", map[string]interface{}{"password": "c"})
+  watcher := NewVersionWatcherAt(client, secrets.Secrets, secrets.Versions)
+  changed, err := watcher.Changed()
+  assert.NoError(t, err)
+  assert.Equal(t, []string{"secret/app/db"}, changed)
+
+  listed, err := listingsChanged(client, secrets.Listings)
+  assert.NoError(t, err)
+  assert.Empty(t, listed)
+
+  // a new secret under a listed prefix is seen
+  fake.put("secret/app/cache", map[string]interface{}{"url": "redis://cache"})
+  listed, err = listingsChanged(client, secrets.Listings)
+  assert.NoError(t, err)
+  assert.Equal(t, []string{"secret/app"}, listed)
+}
+
+func TestWriteIfChanged(t *testing.T) {
+  filePath := filepath.Join(t.TempDir(), "app.conf")
+
+  changed, err := writeIfChanged(filePath, []byte("a=1\n"), 0600, -1, -1)
+  assert.NoError(t, err)
+  assert.True(t, changed)
+
+  changed, err = writeIfChanged(filePath, []byte("a=1\n"), 0600, -1, -1)
+  assert.NoError(t, err)
+  assert.False(t, changed)
+
+  // a different mode is a change
+  changed, err = writeIfChanged(filePath, []byte("a=1\n"), 0640, -1, -1)
+  assert.NoError(t, err)
+  assert.True(t, changed)
+  info, err := os.Stat(filePath)
+  assert.NoError(t, err)
+  assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+
+  changed, err = writeIfChanged(filePath, []byte("a=2\n"), 0640, -1, -1)
+  assert.NoError(t, err)
+  assert.True(t, changed)
+  content, _ := os.ReadFile(filePath)
+  assert.Equal(t, "a=2\n", string(content))
+}
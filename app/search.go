@@ -0,0 +1,307 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dgutierrez1287/vault-util/logger"
+)
+
+/*
+SearchOptions - what to search for and where, values
+are only searched when asked for since every secret
+has to be read
+*/
+type SearchOptions struct {
+  Pattern string
+  Glob bool
+  Paths bool
+  Fields bool
+  Values bool
+  Mounts []string
+  ShowValues bool
+}
+
+/*
+SearchMatch - a secret that matched a search, values
+are only included when they are asked to be shown
+*/
+type SearchMatch struct {
+  Key string                          `json:"key"`
+  PathMatched bool                    `json:"pathMatched,omitempty"`
+  Fields []string                     `json:"fields,omitempty"`
+  ValueFields []string                `json:"valueFields,omitempty"`
+  Values map[string]interface{}       `json:"values,omitempty"`
+}
+
+/*
+searchMatcher - the compiled pattern for a search
+*/
+type searchMatcher struct {
+  path *regexp.Regexp
+  text *regexp.Regexp
+}
+
+/*
+This will search secrets in the mounts, or every kv mount
+when no mounts are passed, for keys, field names and values
+that match the pattern. Secrets that cannot be read are
+returned as errors
+*/
+func Search(client *VaultClient, opts SearchOptions) ([]SearchMatch,
+  []SecretActionError, error) {
+
+  if !opts.Paths && !opts.Fields && !opts.Values {
+    return nil, nil, errors.New("nothing to search, paths, fields or values must be searched")
+  }
+
+  matcher, err := newSearchMatcher(opts.Pattern, opts.Glob)
+  if err != nil {
+    logger.LogError("Error compiling search pattern")
+    return nil, nil, err
+  }
+
+  var lock sync.Mutex
+  var matches []SearchMatch
+  secretErrors, err := forEachKvSecret(client, opts.Mounts, func(key string) error {
+    match, matched, err := searchSecret(client, key, matcher, opts)
+    if err != nil {
+      return err
+    }
+    if matched {
+      lock.Lock()
+      matches = append(matches, match)
+      lock.Unlock()
+    }
+    return nil
+  })
+  if err != nil {
+    return nil, nil, err
+  }
+
+  sort.Slice(matches, func(i, j int) bool { return matches[i].Key < matches[j].Key })
+  return matches, secretErrors, nil
+}
+
+/*
+This will search a single secret, the secret is only
+read when field names or values are searched
+*/
+func searchSecret(client *VaultClient, key string, matcher searchMatcher,
+  opts SearchOptions) (SearchMatch, bool, error) {
+
+  match := SearchMatch{Key: key}
+
+  if opts.Paths && matcher.matchPath(key) {
+    match.PathMatched = true
+  }
+
+  if opts.Fields || opts.Values {
+    secret, err := NewSecret(key, "", "", nil, *client)
+    if err != nil {
+      return match, false, err
+    }
+
+    data, _, err := secret.ReadCurrentData(client)
+    if err != nil {
+      return match, false, err
+    }
+
+    walkLeafPaths(data, nil, func(path []string, value interface{}) {
+      field := strings.Join(path, ".")
+      fieldMatched := opts.Fields && matcher.matchText(path[len(path)-1])
+      valueMatched := opts.Values && matcher.matchText(searchText(value))
+
+      if fieldMatched {
+        match.Fields = append(match.Fields, field)
+      }
+      if valueMatched {
+        match.ValueFields = append(match.ValueFields, field)
+      }
+      if opts.ShowValues && (fieldMatched || valueMatched) {
+        if match.Values == nil {
+          match.Values = make(map[string]interface{})
+        }
+        match.Values[field] = value
+      }
+    })
+    sort.Strings(match.Fields)
+    sort.Strings(match.ValueFields)
+  }
+
+  matched := match.PathMatched || len(match.Fields) > 0 || len(match.ValueFields) > 0
+  return match, matched, nil
+}
+
+/*
+This will call a function for every secret in the mounts, or
+every kv mount when no mounts are passed. Secrets are handled
+in parallel and the ones the function fails for are returned
+as errors
+*/
+func forEachKvSecret(client *VaultClient, mountNames []string,
+  fn func(key string) error) ([]SecretActionError, error) {
+
+  var secretErrors []SecretActionError
+
+  mounts, err := kvMountNames(client, mountNames)
+  if err != nil {
+    return nil, err
+  }
+
+  for _, mount := range mounts {
+    logger.LogDebug("Walking mount", "mount", mount)
+    keys, err := listSubtreeKeys(client, mount)
+    if err != nil {
+      logger.LogError("Error listing secrets in mount")
+      return nil, fmt.Errorf("%s: %w", mount, err)
+    }
+
+    results := RunBulk(keys, client.Concurrency(), fn)
+
+    _, failed := SplitBulkResults(results)
+    for _, failure := range failed {
+      logger.LogError("Error reading secret")
+      secretErrors = append(secretErrors, SecretActionError{
+        VaultKey: failure.Key,
+        Error: failure.Err,
+      })
+    }
+  }
+  return secretErrors, nil
+}
+
+/*
+This will get the names of the mounts to walk, every
+kv mount is used when no mounts are passed
+*/
+func kvMountNames(client *VaultClient, mountNames []string) ([]string, error) {
+  var mounts []string
+  for _, name := range mountNames {
+    if name = strings.Trim(name, "/"); name != "" {
+      mounts = append(mounts, name)
+    }
+  }
+  if len(mounts) > 0 {
+    return mounts, nil
+  }
+
+  logger.LogDebug("No mounts passed, using every kv mount")
+  secretMounts, err := GetSecretMounts(client)
+  if err != nil {
+    return nil, err
+  }
+
+  for _, mount := range secretMounts {
+    if mount.Type == "kv" {
+      mounts = append(mounts, strings.Trim(mount.Mount, "/"))
+    }
+  }
+  sort.Strings(mounts)
+  return mounts, nil
+}
+
+/*
+This will compile the search pattern, a regex matches
+anywhere in the text and a glob must match all of it
+*/
+func newSearchMatcher(pattern string, glob bool) (searchMatcher, error) {
+  var matcher searchMatcher
+  var err error
+
+  if pattern == "" {
+    return matcher, errors.New("a search pattern is required")
+  }
+
+  if !glob {
+    matcher.path, err = regexp.Compile(pattern)
+    matcher.text = matcher.path
+    return matcher, err
+  }
+
+  matcher.path, err = regexp.Compile(GlobToRegexp(pattern, true))
+  if err != nil {
+    return matcher, err
+  }
+  matcher.text, err = regexp.Compile(GlobToRegexp(pattern, false))
+  return matcher, err
+}
+
+func (m searchMatcher) matchPath(key string) bool {
+  return m.path.MatchString(key)
+}
+
+func (m searchMatcher) matchText(text string) bool {
+  return m.text.MatchString(text)
+}
+
+/*
+This will call a function for every leaf value in secret
+data with its dotted field path and the name of the field
+*/
+func walkLeaves(value interface{}, field string,
+  visit func(field string, name string, value interface{})) {
+
+  var path []string
+  if field != "" {
+    path = []string{field}
+  }
+  walkLeafPaths(value, path, func(path []string, value interface{}) {
+    visit(strings.Join(path, "."), path[len(path)-1], value)
+  })
+}
+
+/*
+This will call a function for every leaf value in secret
+data with the parts of its path, field names that have
+dots in them are kept as one part
+*/
+func walkLeafPaths(value interface{}, path []string,
+  visit func(path []string, value interface{})) {
+
+  switch typed := value.(type) {
+  case map[string]interface{}:
+    for key, item := range typed {
+      walkLeafPaths(item, appendPath(path, key), visit)
+    }
+  case []interface{}:
+    for i, item := range typed {
+      walkLeafPaths(item, appendPath(path, fmt.Sprint(i)), visit)
+    }
+  default:
+    if len(path) == 0 {
+      return
+    }
+    visit(path, value)
+  }
+}
+
+/*
+This will join a field path and a field name
+*/
+func joinField(field string, name string) string {
+  if field == "" {
+    return name
+  }
+  return field + "." + name
+}
+
+/*
+This will get the text of a value to search, strings are
+searched as they are and other values as json
+*/
+func searchText(value interface{}) string {
+  if text, ok := value.(string); ok {
+    return text
+  }
+  jsonBytes, err := json.Marshal(value)
+  if err != nil {
+    return fmt.Sprint(value)
+  }
+  return string(jsonBytes)
+}
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for search matching
+*/
+func TestSearchMatcherRegex(t *testing.T) {
+  matcher, err := newSearchMatcher("db_pass", false)
+  assert.NoError(t, err)
+  assert.True(t, matcher.matchPath("secret/app/db_password"))
+  assert.True(t, matcher.matchText("my_db_pass"))
+
+  _, err = newSearchMatcher("(", false)
+  assert.Error(t, err)
+  _, err = newSearchMatcher("", false)
+  assert.Error(t, err)
+}
+
+func TestSearchMatcherGlob(t *testing.T) {
+  matcher, err := newSearchMatcher("secret/*/db", true)
+  assert.NoError(t, err)
+  assert.True(t, matcher.matchPath("secret/app/db"))
+  assert.False(t, matcher.matchPath("secret/app/nested/db"))
+
+  matcher, err = newSearchMatcher("postgres://*", true)
+  assert.NoError(t, err)
+  assert.True(t, matcher.matchText("postgres://user@host/db"))
+  assert.False(t, matcher.matchText("mysql://host"))
+}
+
+func TestWalkLeaves(t *testing.T) {
+  data := map[string]interface{}{
+    "user": "admin",
+    "db": map[string]interface{}{
+      "password": "hunter2",
+      "hosts": []interface{}{"a", "b"},
+    },
+  }
+
+  fields := make(map[string]string)
+  walkLeaves(data, "", func(field string, name string, value interface{}) {
+    fields[field] = name
+  })
+
+  assert.Equal(t, map[string]string{
+    "user": "user",
+    "db.password": "password",
+    "db.hosts.0": "0",
+    "db.hosts.1": "1",
+  }, fields)
+}
+
+func TestSearchDottedFieldNames(t *testing.T) {
+  fake, client := newFakeVault(t, map[string]string{"secret/": "2"})
+
+  fake.put("secret/app/tls", map[string]interface{}{
+    "tls.crt": "certificate",
+    "tls.key": "private",
+  })
+
+  matches, secretErrors, err := Search(client, SearchOptions{
+    Pattern: "crt",
+    Fields: true,
+    ShowValues: true,
+  })
+  assert.NoError(t, err)
+  assert.Empty(t, secretErrors)
+  assert.Equal(t, []SearchMatch{{
+    Key: "secret/app/tls",
+    Fields: []string{"tls.crt"},
+    Values: map[string]interface{}{"tls.crt": "certificate"},
+  }}, matches)
+}
+
+func TestSearchText(t *testing.T) {
+  assert.Equal(t, "text", searchText("text"))
+  assert.Equal(t, "5432", searchText(float64(5432)))
+  assert.Equal(t, "true", searchText(true))
+}
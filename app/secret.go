@@ -198,6 +198,91 @@ func (s *VaultSecret) ReadSecret(client *VaultClient) error {
   return nil
 }
 
+/*
+This will get the current version of a kv v2 secret, kv v1
+secrets have no versions so 0 is returned for them
+*/
+func (s VaultSecret) CurrentVersion(client *VaultClient) (int64, error) {
+  if s.SecretType != "kv" || s.KvVersion != "2" {
+    return 0, nil
+  }
+
+  metadata, err := client.ReadKvMetadata(s)
+  if err != nil {
+    logger.LogError("Error reading the secret version")
+    return 0, err
+  }
+  return metadata.CurrentVersion, nil
+}
+
+/*
+Delete a secret
+*/
+func (s VaultSecret) DeleteSecret(client *VaultClient) error {
+  if s.SecretType == "kv" {
+    logger.LogDebug("Secret is kv type")
+
+    logger.LogDebug("Deleting secret", "path", s.NormalizedSecretPath)
+    err := client.DeleteKvSecret(s)
+
+    if err != nil {
+      logger.LogError("Error deleting the kv secret")
+      return err
+    }
+  }
+  return nil
+}
+
+/*
+Soft delete a secret, for kv v2 only the current version is
+deleted so the history is kept and it can be undeleted
+*/
+func (s VaultSecret) SoftDeleteSecret(client *VaultClient) error {
+  if s.SecretType == "kv" {
+    logger.LogDebug("Secret is kv type")
+
+    logger.LogDebug("Soft deleting secret", "path", s.NormalizedSecretPath)
+    err := client.SoftDeleteKvSecret(s)
+
+    if err != nil {
+      logger.LogError("Error deleting the kv secret")
+      return err
+    }
+  }
+  return nil
+}
+
+/*
+Reads the current data for a secret from vault without
+changing the secret object, if the secret does not exist
+then exists will be false
+*/
+func (s VaultSecret) ReadCurrentData(client *VaultClient) (map[string]interface{},
+  bool, error) {
+
+  if s.SecretType != "kv" {
+    logger.LogError("Error reading current data, secret is not kv", "key", s.VaultKey)
+    return nil, false, errors.New("reading current data is only supported for kv secrets")
+  }
+
+  logger.LogDebug("Reading current secret data", "path", s.NormalizedSecretPath)
+  data, err := client.ReadKvSecret(s)
+  if err != nil {
+    if IsNotFoundError(err) {
+      logger.LogDebug("Secret does not exist", "path", s.NormalizedSecretPath)
+      return nil, false, nil
+    }
+    logger.LogError("Error reading current secret data")
+    return nil, false, err
+  }
+
+  if data == nil {
+    logger.LogDebug("Secret has no current data", "path", s.NormalizedSecretPath)
+    return nil, false, nil
+  }
+  return data, true, nil
+}
+
 /*
 Check if a secret exists
 */
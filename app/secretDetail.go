@@ -0,0 +1,211 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgutierrez1287/vault-util/logger"
+)
+
+// the custom metadata key that holds the owner of a secret
+const OwnerMetadataKey = "owner"
+
+// the deletion states of the current version of a secret
+const (
+  SecretStateActive = "active"
+  SecretStateDeleted = "deleted"
+  SecretStateDestroyed = "destroyed"
+)
+
+// the columns secret details can be sorted by
+var SecretDetailSortColumns = []string{"key", "version", "versions", "created", "updated", "state", "owner"}
+
+/*
+SecretDetail - the kv v2 metadata of a secret
+*/
+type SecretDetail struct {
+  Key string                              `json:"key"`
+  CurrentVersion int64                    `json:"currentVersion"`
+  VersionCount int                        `json:"versionCount"`
+  CreatedTime time.Time                   `json:"createdTime"`
+  UpdatedTime time.Time                   `json:"updatedTime"`
+  DeletionState string                    `json:"deletionState"`
+  Owner string                            `json:"owner,omitempty"`
+  CustomMetadata map[string]interface{}   `json:"customMetadata,omitempty"`
+}
+
+/*
+This will read the kv v2 metadata for every secret, the
+secrets are full keys from a secret list. Secrets whose metadata
+cannot be read are returned as errors
+*/
+func GetSecretDetails(client *VaultClient, mount SecretMount,
+  secrets []string) ([]SecretDetail, []SecretActionError, error) {
+
+  var secretErrors []SecretActionError
+
+  if mount.Type != "kv" || mount.KvVersion != "2" {
+    logger.LogError("Error secret mount is not kv v2")
+    return nil, nil, fmt.Errorf("secret mount %s is not kv v2, only kv v2 secrets have metadata",
+      mount.Mount)
+  }
+
+  var lock sync.Mutex
+  detailsByKey := make(map[string]SecretDetail)
+
+  results := RunBulk(secrets, client.Concurrency(), func(key string) error {
+    detail, err := getSecretDetail(client, mount, key)
+    if err != nil {
+      return err
+    }
+    lock.Lock()
+    detailsByKey[key] = detail
+    lock.Unlock()
+    return nil
+  })
+
+  succeeded, failed := SplitBulkResults(results)
+  for _, failure := range failed {
+    logger.LogError("Error reading secret metadata")
+    secretErrors = append(secretErrors, SecretActionError{
+      VaultKey: failure.Key,
+      Error: failure.Err,
+    })
+  }
+
+  details := make([]SecretDetail, 0, len(succeeded))
+  for _, key := range succeeded {
+    details = append(details, detailsByKey[key])
+  }
+  return details, secretErrors, nil
+}
+
+/*
+This will read the metadata of a single secret
+*/
+func getSecretDetail(client *VaultClient, mount SecretMount, key string) (SecretDetail, error) {
+  detail := SecretDetail{Key: key}
+
+  secret, err := NewSecret(key, mount.Type, mount.KvVersion, nil, *client)
+  if err != nil {
+    return detail, err
+  }
+  secret.MountName = mount.Mount
+  err = secret.getNormalizedSecretPath()
+  if err != nil {
+    return detail, err
+  }
+
+  metadata, err := client.ReadKvMetadata(secret)
+  if err != nil {
+    return detail, err
+  }
+
+  detail.CurrentVersion = metadata.CurrentVersion
+  detail.VersionCount = len(metadata.Versions)
+  detail.CreatedTime = metadata.CreatedTime
+  detail.UpdatedTime = metadata.UpdatedTime
+  detail.CustomMetadata = metadata.CustomMetadata
+  if owner, ok := metadata.CustomMetadata[OwnerMetadataKey]; ok {
+    detail.Owner = fmt.Sprint(owner)
+  }
+
+  versionInfo, _ := metadata.Versions[strconv.FormatInt(metadata.CurrentVersion, 10)].(map[string]interface{})
+  detail.DeletionState = versionState(versionInfo)
+  return detail, nil
+}
+
+/*
+This will get the deletion state of a version from
+its metadata, a deletion time in the future is still active
+*/
+func versionState(versionInfo map[string]interface{}) string {
+  if destroyed, ok := versionInfo["destroyed"].(bool); ok && destroyed {
+    return SecretStateDestroyed
+  }
+  if versionDeleted(versionInfo, time.Now()) {
+    return SecretStateDeleted
+  }
+  return SecretStateActive
+}
+
+/*
+This will sort secret details by a column, ties are
+sorted by key so the order is always the same
+*/
+func SortSecretDetails(details []SecretDetail, column string, reverse bool) error {
+  compare, err := secretDetailCompare(column)
+  if err != nil {
+    return err
+  }
+
+  sort.SliceStable(details, func(i, j int) bool {
+    result := compare(details[i], details[j])
+    if result == 0 {
+      result = strings.Compare(details[i].Key, details[j].Key)
+    }
+    if reverse {
+      return result > 0
+    }
+    return result < 0
+  })
+  return nil
+}
+
+/*
+This will check a sort column before any metadata
+is read
+*/
+func ValidateSecretDetailSort(column string) error {
+  _, err := secretDetailCompare(column)
+  return err
+}
+
+/*
+This will get the compare function for a sort column
+*/
+func secretDetailCompare(column string) (func(a SecretDetail, b SecretDetail) int, error) {
+  var compare func(a SecretDetail, b SecretDetail) int
+
+  switch strings.ToLower(column) {
+  case "", "key":
+    compare = func(a SecretDetail, b SecretDetail) int { return 0 }
+  case "version":
+    compare = func(a SecretDetail, b SecretDetail) int { return compareInt(a.CurrentVersion, b.CurrentVersion) }
+  case "versions":
+    compare = func(a SecretDetail, b SecretDetail) int {
+      return compareInt(int64(a.VersionCount), int64(b.VersionCount))
+    }
+  case "created":
+    compare = func(a SecretDetail, b SecretDetail) int { return a.CreatedTime.Compare(b.CreatedTime) }
+  case "updated":
+    compare = func(a SecretDetail, b SecretDetail) int { return a.UpdatedTime.Compare(b.UpdatedTime) }
+  case "state":
+    compare = func(a SecretDetail, b SecretDetail) int { return strings.Compare(a.DeletionState, b.DeletionState) }
+  case "owner":
+    compare = func(a SecretDetail, b SecretDetail) int { return strings.Compare(a.Owner, b.Owner) }
+  default:
+    return nil, errors.New("unknown sort column " + column + ", must be one of " +
+      strings.Join(SecretDetailSortColumns, ", "))
+  }
+  return compare, nil
+}
+
+/*
+This will compare two numbers the same way
+strings.Compare does
+*/
+func compareInt(a int64, b int64) int {
+  switch {
+  case a < b:
+    return -1
+  case a > b:
+    return 1
+  }
+  return 0
+}
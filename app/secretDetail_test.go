@@ -0,0 +1,59 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for secret details
+*/
+func testSecretDetails() []SecretDetail {
+  base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+  return []SecretDetail{
+    {Key: "secret/b", CurrentVersion: 3, VersionCount: 3, UpdatedTime: base.Add(2 * time.Hour), Owner: "team-a"},
+    {Key: "secret/a", CurrentVersion: 1, VersionCount: 1, UpdatedTime: base.Add(3 * time.Hour), Owner: "team-b"},
+    {Key: "secret/c", CurrentVersion: 3, VersionCount: 2, UpdatedTime: base.Add(1 * time.Hour), Owner: "team-a"},
+  }
+}
+
+func detailKeys(details []SecretDetail) []string {
+  var keys []string
+  for _, detail := range details {
+    keys = append(keys, detail.Key)
+  }
+  return keys
+}
+
+func TestSortSecretDetails(t *testing.T) {
+  details := testSecretDetails()
+  assert.NoError(t, SortSecretDetails(details, "key", false))
+  assert.Equal(t, []string{"secret/a", "secret/b", "secret/c"}, detailKeys(details))
+
+  assert.NoError(t, SortSecretDetails(details, "version", true))
+  assert.Equal(t, []string{"secret/c", "secret/b", "secret/a"}, detailKeys(details))
+
+  assert.NoError(t, SortSecretDetails(details, "updated", false))
+  assert.Equal(t, []string{"secret/c", "secret/b", "secret/a"}, detailKeys(details))
+
+  assert.NoError(t, SortSecretDetails(details, "owner", false))
+  assert.Equal(t, []string{"secret/b", "secret/c", "secret/a"}, detailKeys(details))
+
+  assert.Error(t, SortSecretDetails(details, "size", false))
+
+  assert.NoError(t, ValidateSecretDetailSort("Created"))
+  assert.Error(t, ValidateSecretDetailSort("size"))
+}
+
+func TestVersionState(t *testing.T) {
+  assert.Equal(t, SecretStateActive, versionState(nil))
+  assert.Equal(t, SecretStateActive, versionState(map[string]interface{}{"deletion_time": "", "destroyed": false}))
+  assert.Equal(t, SecretStateDeleted, versionState(map[string]interface{}{"deletion_time": "2024-01-01T00:00:00Z"}))
+  assert.Equal(t, SecretStateDestroyed, versionState(map[string]interface{}{"destroyed": true}))
+
+  // delete_version_after gives live versions a deletion time in the future
+  future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339Nano)
+  assert.Equal(t, SecretStateActive, versionState(map[string]interface{}{"deletion_time": future}))
+}
@@ -2,6 +2,8 @@ package app
 
 import (
 	"errors"
+	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/dgutierrez1287/vault-util/logger"
@@ -53,44 +55,75 @@ given mount
 */
 func (sm SecretMount) ListSecrets(client *VaultClient) ([]string,
   error) {
+  return sm.ListSecretsWithPrefix(client, "")
+}
 
-  var secrets []string
+/*
+ListOptions - limits what is listed in a mount, the
+prefix is relative to the mount and a max depth of 0
+walks every folder
+*/
+type ListOptions struct {
+  Prefix string
+  MaxDepth int
+  Glob string
+}
 
-  if sm.Type == "kv" {
-    logger.LogDebug("Secret mount is a kv")
-
-    var walk func(string) error
-    walk = func(path string) error {
-      respKeys, err := client.ListKvSecrets(sm.Mount, path, sm.KvVersion)
-      if err != nil {
-        // check for 404 to skip non folder
-        if strings.Contains(err.Error(), "404") {
-          return nil
-        }
-        return err
-      }
+/*
+This will get a list of all the secrets under a folder
+in the mount, the prefix is relative to the mount. It is
+an error when a folder could not be listed since the list
+would not be complete
+*/
+func (sm SecretMount) ListSecretsWithPrefix(client *VaultClient, prefix string) ([]string,
+  error) {
 
-      for _, key := range respKeys {
-        if strings.HasSuffix(key, "/") {
-          err := walk(path + key)
-          if err != nil {
-            return err
-          }
-        } else {
-          //leaf secret, add to list
-          fullPath := path + key
-          secrets = append(secrets, fullPath)
-        }
-      }
-      return nil
-    }
+  secrets, result, err := sm.ListSecretsWithOptions(client, ListOptions{Prefix: prefix})
+  if err != nil {
+    return nil, err
+  }
 
-    err := walk(sm.Mount)
-    if err != nil {
-      return nil, err
+  if len(result.Skipped) > 0 {
+    var paths []string
+    for _, skipped := range result.Skipped {
+      paths = append(paths, skipped.Path)
     }
+    logger.LogError("Error some folders could not be listed")
+    return nil, fmt.Errorf("cannot list folders %s: permission denied", strings.Join(paths, ", "))
   }
-  return secrets, nil 
+  return secrets, nil
+}
+
+/*
+This will get a sorted list of the secrets in the mount
+that are under the prefix, no deeper than the max depth and
+match the glob, the walk result has the folders that were
+not walked and the folders that could not be listed
+*/
+func (sm SecretMount) ListSecretsWithOptions(client *VaultClient, opts ListOptions) ([]string,
+  WalkResult, error) {
+
+  var secrets []string
+
+  result, err := sm.WalkSecrets(client, opts, func(key string) error {
+    secrets = append(secrets, key)
+    return nil
+  })
+  if err != nil {
+    return nil, result, err
+  }
+
+  sort.Strings(secrets)
+  return secrets, result, nil
+}
+
+/*
+This will get the mount name from a vault key, the
+mount is the first part of the key
+*/
+func MountFromKey(key string) string {
+  parts := strings.SplitN(strings.TrimPrefix(key, "/"), "/", 2)
+  return parts[0] + "/"
 }
 
 /*
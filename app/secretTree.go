@@ -0,0 +1,95 @@
+package app
+
+import (
+	"sort"
+	"strings"
+)
+
+/*
+SecretFolder - a folder in a secret listing, the secret
+count is every secret under the folder. A folder is truncated
+when it was not walked because of the max depth
+*/
+type SecretFolder struct {
+  Name string                   `json:"name"`
+  Path string                   `json:"path"`
+  SecretCount int               `json:"secretCount"`
+  Truncated bool                `json:"truncated,omitempty"`
+  Folders []*SecretFolder       `json:"folders,omitempty"`
+  Secrets []string              `json:"secrets,omitempty"`
+}
+
+/*
+This will build a folder tree from a listing, the root is
+the folder that was listed and the secrets and folders are
+full paths under it
+*/
+func BuildSecretTree(root string, secrets []string, truncated []string) *SecretFolder {
+  if root != "" && !strings.HasSuffix(root, "/") {
+    root = root + "/"
+  }
+
+  tree := &SecretFolder{
+    Name: root,
+    Path: root,
+  }
+
+  for _, folder := range truncated {
+    relPath := strings.Trim(strings.TrimPrefix(folder, root), "/")
+    if relPath == "" {
+      continue
+    }
+    tree.folder(strings.Split(relPath, "/")).Truncated = true
+  }
+
+  for _, secret := range secrets {
+    parts := strings.Split(strings.TrimPrefix(secret, root), "/")
+    folder := tree.folder(parts[:len(parts)-1])
+    folder.Secrets = append(folder.Secrets, parts[len(parts)-1])
+  }
+
+  tree.sort()
+  return tree
+}
+
+/*
+This will get a folder under this one, the folders
+on the way are created when they do not exist
+*/
+func (f *SecretFolder) folder(parts []string) *SecretFolder {
+  current := f
+  for _, part := range parts {
+    var next *SecretFolder
+    for _, child := range current.Folders {
+      if child.Name == part+"/" {
+        next = child
+        break
+      }
+    }
+
+    if next == nil {
+      next = &SecretFolder{
+        Name: part + "/",
+        Path: current.Path + part + "/",
+      }
+      current.Folders = append(current.Folders, next)
+    }
+    current = next
+  }
+  return current
+}
+
+/*
+This will sort the folder and count the secrets
+under it
+*/
+func (f *SecretFolder) sort() int {
+  sort.Slice(f.Folders, func(i, j int) bool { return f.Folders[i].Name < f.Folders[j].Name })
+  sort.Strings(f.Secrets)
+
+  f.SecretCount = len(f.Secrets)
+  for _, child := range f.Folders {
+    f.SecretCount += child.sort()
+  }
+  return f.SecretCount
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for building secret trees
+*/
+func TestBuildSecretTree(t *testing.T) {
+  secrets := []string{
+    "secret/app/db",
+    "secret/app/api/token",
+    "secret/app/api/key",
+    "secret/root",
+  }
+
+  tree := BuildSecretTree("secret/", secrets, []string{"secret/infra/"})
+
+  assert.Equal(t, "secret/", tree.Path)
+  assert.Equal(t, 4, tree.SecretCount)
+  assert.Equal(t, []string{"root"}, tree.Secrets)
+  assert.Len(t, tree.Folders, 2)
+
+  app := tree.Folders[0]
+  assert.Equal(t, "app/", app.Name)
+  assert.Equal(t, "secret/app/", app.Path)
+  assert.Equal(t, 3, app.SecretCount)
+  assert.Equal(t, []string{"db"}, app.Secrets)
+
+  api := app.Folders[0]
+  assert.Equal(t, "secret/app/api/", api.Path)
+  assert.Equal(t, 2, api.SecretCount)
+  assert.Equal(t, []string{"key", "token"}, api.Secrets)
+
+  infra := tree.Folders[1]
+  assert.Equal(t, "infra/", infra.Name)
+  assert.True(t, infra.Truncated)
+  assert.Equal(t, 0, infra.SecretCount)
+}
+
+func TestBuildSecretTreePrefix(t *testing.T) {
+  tree := BuildSecretTree("secret/app", []string{"secret/app/db", "secret/app/api/token"}, nil)
+
+  assert.Equal(t, "secret/app/", tree.Path)
+  assert.Equal(t, 2, tree.SecretCount)
+  assert.Equal(t, []string{"db"}, tree.Secrets)
+  assert.Equal(t, "secret/app/api/", tree.Folders[0].Path)
+}
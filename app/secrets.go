@@ -5,8 +5,11 @@ import (
 	"encoding/json"
 	"io"
 	"os"
+	"path/filepath"
+	"sort"
 
 	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/dgutierrez1287/vault-util/util"
 )
 
 /*
@@ -15,6 +18,24 @@ set of secrets from a file
 */
 type VaultSecrets struct {
   Secrets map[string]VaultSecret           `json:"secrets"`
+  Encryption *EncryptionMetadata           `json:"encryption,omitempty"`
+}
+
+/*
+secretsFileEntry - a secret as it is written
+to a secrets file
+*/
+type secretsFileEntry struct {
+  VaultKey string                          `json:"key"`
+  SecretData map[string]interface{}        `json:"data"`
+}
+
+/*
+secretsFile - the format of a secrets file
+*/
+type secretsFile struct {
+  Secrets map[string]secretsFileEntry      `json:"secrets"`
+  Encryption *EncryptionMetadata           `json:"encryption,omitempty"`
 }
 
 /*
@@ -32,21 +53,72 @@ format
     }
   }
 }
+
+values can reference ${env:NAME}, ${file:path}, ${file:path:base64},
+${vault:mount/path#field} and ${random:length:charset}
+
+if the file is encrypted the values are decrypted
+
+the file is checked against the schema and every secret is
+checked against vault before anything else is done, problems
+are returned as a ValidationError
 */
 func ReadSecretsFromJson(secretsFilePath string, 
   client *VaultClient, ctx context.Context) (VaultSecrets, error) {
+
   var secrets VaultSecrets
 
-  file, err := os.Open(secretsFilePath)
+  data, err := readSecretsFileBytes(secretsFilePath)
   if err != nil {
-    logger.LogError("Error opening secrets file")
     return secrets, err
   }
-  defer file.Close()
 
-  bytes, err := io.ReadAll(file)
+  logger.LogDebug("Validating secrets file against the schema")
+  issues, locations := ValidateSecretsFileSchema(data)
+  if len(issues) > 0 {
+    logger.LogError("Error secrets file does not match the schema")
+    return secrets, &ValidationError{SecretsFile: secretsFilePath, Issues: issues}
+  }
+
+  err = json.Unmarshal(data, &secrets)
+  if err != nil {
+    logger.LogError("Error unmarshaling json to struct")
+    return secrets, err
+  }
+
+  logger.LogDebug("Decrypting secrets file if it is encrypted")
+  err = secrets.Decrypt(client)
+  if err != nil {
+    logger.LogError("Error decrypting secrets file")
+    return secrets, err
+  }
+
+  logger.LogDebug("Checking secrets in the file against vault")
+  issues = secrets.preflight(client, locations)
+  if len(issues) > 0 {
+    logger.LogError("Error secrets file failed the pre-flight checks")
+    return secrets, &ValidationError{SecretsFile: secretsFilePath, Issues: issues}
+  }
+
+  logger.LogDebug("Resolving references in secret values")
+  err = secrets.ResolveReferences(client, filepath.Dir(secretsFilePath))
+  if err != nil {
+    logger.LogError("Error resolving references in secrets file")
+    return secrets, err
+  }
+
+  return secrets, nil
+}
+
+/*
+Reads a secrets file without decrypting it or getting
+any details for the secrets from vault
+*/
+func LoadSecretsFile(secretsFilePath string) (VaultSecrets, error) {
+  var secrets VaultSecrets
+
+  bytes, err := readSecretsFileBytes(secretsFilePath)
   if err != nil {
-    logger.LogError("Error reading secrets file")
     return secrets, err
   }
 
@@ -55,15 +127,122 @@ func ReadSecretsFromJson(secretsFilePath string,
     logger.LogError("Error unmarshaling json to struct")
     return secrets, err
   }
+  return secrets, nil
+}
+
+/*
+Reads the contents of a secrets file
+*/
+func readSecretsFileBytes(secretsFilePath string) ([]byte, error) {
+  file, err := os.Open(secretsFilePath)
+  if err != nil {
+    logger.LogError("Error opening secrets file")
+    return nil, err
+  }
+  defer file.Close()
+
+  bytes, err := io.ReadAll(file)
+  if err != nil {
+    logger.LogError("Error reading secrets file")
+    return nil, err
+  }
+  return bytes, nil
+}
+
+/*
+Writes secrets to a secrets file, only the key and
+data are written so the file can be loaded again
+*/
+func WriteSecretsFile(secretsFilePath string, secrets VaultSecrets) error {
+  fileData := secretsFile{
+    Secrets: make(map[string]secretsFileEntry, len(secrets.Secrets)),
+    Encryption: secrets.Encryption,
+  }
 
-  logger.LogDebug("Getting secret details for secrets in the list")
   for name, secret := range secrets.Secrets {
-    logger.LogDebug("Getting details for secret", "name", name)
-    secret.getSecretDetails(client)
-    secrets.Secrets[name] = secret
+    fileData.Secrets[name] = secretsFileEntry{
+      VaultKey: secret.VaultKey,
+      SecretData: secret.SecretData,
+    }
   }
 
-  return secrets, nil
+  jsonData, err := json.MarshalIndent(fileData, "", "  ")
+  if err != nil {
+    logger.LogError("Error marshaling secrets to json")
+    return err
+  }
+
+  err = util.WriteFileAtomic(secretsFilePath, jsonData, 0600)
+  if err != nil {
+    logger.LogError("Error writing the secrets file")
+    return err
+  }
+  return nil
+}
+
+/*
+Returns the names of all the secrets
+*/
+func (vs VaultSecrets) SecretNames() []string {
+  names := make([]string, 0, len(vs.Secrets))
+  for name := range vs.Secrets {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+  return names
+}
+
+/*
+This will write all the secrets using the client's worker
+pool, the names written and the errors are sorted by
+secret name
+*/
+func (vs VaultSecrets) WriteSecrets(client *VaultClient) ([]string,
+  []SecretActionError) {
+
+  return vs.WriteSecretsWithCheckpoint(client, nil)
+}
+
+/*
+This will write the secrets like WriteSecrets, secrets the
+checkpoint has as done are skipped and every secret written
+is recorded in the checkpoint
+*/
+func (vs VaultSecrets) WriteSecretsWithCheckpoint(client *VaultClient,
+  checkpoint *Checkpoint) ([]string, []SecretActionError) {
+
+  var secretErrors []SecretActionError
+  var names []string
+
+  for _, name := range vs.SecretNames() {
+    if checkpoint != nil && checkpoint.IsDone(name) {
+      logger.LogDebug("Secret already written, skipping", "name", name)
+      continue
+    }
+    names = append(names, name)
+  }
+
+  results := RunBulk(names, client.Concurrency(), func(name string) error {
+    logger.LogDebug("writing secret", "name", name)
+    err := vs.Secrets[name].WriteSecret(client)
+    if err == nil && checkpoint != nil {
+      saveErr := checkpoint.MarkDone(name)
+      if saveErr != nil {
+        logger.LogError("Error saving the checkpoint")
+      }
+    }
+    return err
+  })
+
+  secretsWritten, failed := SplitBulkResults(results)
+  for _, result := range failed {
+    logger.LogError("Error writing secret")
+    secretErrors = append(secretErrors, SecretActionError{
+      VaultKey: vs.Secrets[result.Key].VaultKey,
+      Error: result.Err,
+    })
+  }
+  return secretsWritten, secretErrors
 }
 
 
@@ -0,0 +1,232 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgutierrez1287/vault-util/logger"
+)
+
+// the staleness of a secret
+const (
+  StaleStatusOk = "ok"
+  StaleStatusWarn = "warn"
+  StaleStatusCritical = "critical"
+)
+
+// the exit codes for a staleness report
+const (
+  StaleExitWarn = 1
+  StaleExitCritical = 2
+)
+
+/*
+StaleOptions - where to look for stale secrets and
+how old a secret can be, secrets that are not stale are
+only reported when all is set
+*/
+type StaleOptions struct {
+  Mounts []string
+  WarnDays int
+  CriticalDays int
+  All bool
+  Now time.Time
+}
+
+/*
+StaleSecret - a secret and how long ago it was updated
+*/
+type StaleSecret struct {
+  Key string                    `json:"key"`
+  UpdatedTime time.Time         `json:"updatedTime"`
+  AgeDays int                   `json:"ageDays"`
+  Status string                 `json:"status"`
+}
+
+/*
+StaleGroup - the stale secrets in a mount that
+have the same owner
+*/
+type StaleGroup struct {
+  Mount string                  `json:"mount"`
+  Owner string                  `json:"owner,omitempty"`
+  Warn int                      `json:"warn"`
+  Critical int                  `json:"critical"`
+  Secrets []StaleSecret         `json:"secrets"`
+}
+
+/*
+StaleReport - the stale secrets grouped by mount
+and owner
+*/
+type StaleReport struct {
+  Checked int                   `json:"checked"`
+  Warn int                      `json:"warn"`
+  Critical int                  `json:"critical"`
+  Groups []StaleGroup           `json:"groups"`
+  Skipped []SkippedFolder       `json:"skippedFolders,omitempty"`
+  Errors []SecretActionError    `json:"Errors,omitempty"`
+}
+
+/*
+This will find the secrets in the mounts, or every kv v2 mount
+when no mounts are passed, that have not been updated in the
+warn or critical number of days
+*/
+func FindStaleSecrets(client *VaultClient, opts StaleOptions) (StaleReport, error) {
+  var report StaleReport
+
+  if opts.WarnDays < 0 || opts.CriticalDays < 0 {
+    return report, errors.New("warn and critical days cannot be negative")
+  }
+  if opts.CriticalDays > 0 && opts.WarnDays > opts.CriticalDays {
+    return report, errors.New("warn days must not be more than critical days")
+  }
+  if opts.Now.IsZero() {
+    opts.Now = time.Now()
+  }
+
+  mounts, err := kvV2Mounts(client, opts.Mounts)
+  if err != nil {
+    return report, err
+  }
+
+  groups := make(map[string]*StaleGroup)
+  for _, mount := range mounts {
+    logger.LogDebug("Checking mount for stale secrets", "mount", mount.Mount)
+    secrets, result, err := mount.ListSecretsWithOptions(client, ListOptions{})
+    if err != nil {
+      logger.LogError("Error listing secrets to check")
+      return report, fmt.Errorf("%s: %w", mount.Mount, err)
+    }
+    report.Skipped = append(report.Skipped, result.Skipped...)
+
+    details, secretErrors, err := GetSecretDetails(client, mount, secrets)
+    if err != nil {
+      return report, err
+    }
+    report.Errors = append(report.Errors, secretErrors...)
+
+    for _, detail := range details {
+      report.Checked++
+
+      age := int(opts.Now.Sub(detail.UpdatedTime).Hours() / 24)
+      status := staleStatus(age, opts.WarnDays, opts.CriticalDays)
+      if status == StaleStatusOk && !opts.All {
+        continue
+      }
+
+      groupKey := mount.Mount + "\x00" + detail.Owner
+      group, ok := groups[groupKey]
+      if !ok {
+        group = &StaleGroup{Mount: mount.Mount, Owner: detail.Owner}
+        groups[groupKey] = group
+      }
+
+      switch status {
+      case StaleStatusWarn:
+        group.Warn++
+        report.Warn++
+      case StaleStatusCritical:
+        group.Critical++
+        report.Critical++
+      }
+      group.Secrets = append(group.Secrets, StaleSecret{
+        Key: detail.Key,
+        UpdatedTime: detail.UpdatedTime,
+        AgeDays: age,
+        Status: status,
+      })
+    }
+  }
+
+  for _, group := range groups {
+    sort.Slice(group.Secrets, func(i, j int) bool {
+      if group.Secrets[i].AgeDays != group.Secrets[j].AgeDays {
+        return group.Secrets[i].AgeDays > group.Secrets[j].AgeDays
+      }
+      return group.Secrets[i].Key < group.Secrets[j].Key
+    })
+    report.Groups = append(report.Groups, *group)
+  }
+  sort.Slice(report.Groups, func(i, j int) bool {
+    if report.Groups[i].Mount != report.Groups[j].Mount {
+      return report.Groups[i].Mount < report.Groups[j].Mount
+    }
+    return report.Groups[i].Owner < report.Groups[j].Owner
+  })
+  return report, nil
+}
+
+/*
+This will get the status of a secret from its age in days,
+a threshold of 0 is turned off
+*/
+func staleStatus(ageDays int, warnDays int, criticalDays int) string {
+  if criticalDays > 0 && ageDays >= criticalDays {
+    return StaleStatusCritical
+  }
+  if warnDays > 0 && ageDays >= warnDays {
+    return StaleStatusWarn
+  }
+  return StaleStatusOk
+}
+
+/*
+This will get the exit code for the report, fail on
+is the lowest status that fails: warn, critical or never
+*/
+func (r StaleReport) ExitCode(failOn string) (int, error) {
+  switch strings.ToLower(failOn) {
+  case "never":
+    return 0, nil
+  case StaleStatusWarn:
+    if r.Critical > 0 {
+      return StaleExitCritical, nil
+    }
+    if r.Warn > 0 {
+      return StaleExitWarn, nil
+    }
+    return 0, nil
+  case StaleStatusCritical:
+    if r.Critical > 0 {
+      return StaleExitCritical, nil
+    }
+    return 0, nil
+  }
+  return 0, fmt.Errorf("fail on %s must be warn, critical or never", failOn)
+}
+
+/*
+This will get the kv v2 mounts to check, every kv v2
+mount is used when no mounts are passed
+*/
+func kvV2Mounts(client *VaultClient, mountNames []string) ([]SecretMount, error) {
+  var mounts []SecretMount
+
+  names, err := kvMountNames(client, mountNames)
+  if err != nil {
+    return nil, err
+  }
+
+  for _, name := range names {
+    mount, err := NewSecretMount(name, "", "", "", client)
+    if err != nil {
+      logger.LogError("Error getting secret mount details")
+      return nil, fmt.Errorf("%s: %w", name, err)
+    }
+
+    if mount.Type != "kv" || mount.KvVersion != "2" {
+      if len(mountNames) > 0 {
+        return nil, fmt.Errorf("secret mount %s is not kv v2, only kv v2 secrets have metadata", name)
+      }
+      logger.LogDebug("Skipping mount that is not kv v2", "mount", name)
+      continue
+    }
+    mounts = append(mounts, mount)
+  }
+  return mounts, nil
+}
@@ -0,0 +1,44 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for stale secret reports
+*/
+func TestStaleStatus(t *testing.T) {
+  assert.Equal(t, StaleStatusOk, staleStatus(10, 90, 180))
+  assert.Equal(t, StaleStatusWarn, staleStatus(90, 90, 180))
+  assert.Equal(t, StaleStatusCritical, staleStatus(200, 90, 180))
+
+  // a threshold of 0 is turned off
+  assert.Equal(t, StaleStatusWarn, staleStatus(500, 90, 0))
+  assert.Equal(t, StaleStatusOk, staleStatus(500, 0, 0))
+}
+
+func TestStaleReportExitCode(t *testing.T) {
+  warnOnly := StaleReport{Warn: 2}
+  critical := StaleReport{Warn: 1, Critical: 1}
+
+  code, err := warnOnly.ExitCode("critical")
+  assert.NoError(t, err)
+  assert.Equal(t, 0, code)
+
+  code, _ = warnOnly.ExitCode("warn")
+  assert.Equal(t, StaleExitWarn, code)
+
+  code, _ = critical.ExitCode("warn")
+  assert.Equal(t, StaleExitCritical, code)
+
+  code, _ = critical.ExitCode("critical")
+  assert.Equal(t, StaleExitCritical, code)
+
+  code, _ = critical.ExitCode("never")
+  assert.Equal(t, 0, code)
+
+  _, err = critical.ExitCode("sometimes")
+  assert.Error(t, err)
+}
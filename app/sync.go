@@ -0,0 +1,106 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dgutierrez1287/vault-util/logger"
+)
+
+/*
+SyncOptions - options for making a mount
+match a secrets file
+*/
+type SyncOptions struct {
+  Mount string
+  Scope string
+  Prune bool
+  Protected []string
+  MaxDeletions int
+  Destroy bool
+}
+
+/*
+This will build a plan to make the scope of a mount match the
+secrets file exactly, secrets in the file are created or updated
+and with prune, secrets in the scope that are not in the file
+are deleted. Protected keys are never deleted and the plan fails
+if it would delete more than the max deletions. Deletes are soft
+deletes so the history is kept unless destroy is set
+*/
+func BuildSyncPlan(secrets VaultSecrets, secretsFile string, client *VaultClient,
+  opts SyncOptions) (SecretPlan, error) {
+
+  scopeKey := syncScopeKey(opts.Mount, opts.Scope)
+  logger.LogDebug("Sync scope", "scope", scopeKey)
+
+  keep := make(map[string]string)
+  for _, name := range secrets.SecretNames() {
+    secret := secrets.Secrets[name]
+    relKey, ok := keyInScope(secret, scopeKey)
+    if !ok {
+      logger.LogError("Error secret is outside the sync scope")
+      return SecretPlan{}, fmt.Errorf("secret %s is outside the sync scope %s",
+        secret.VaultKey, scopeKey)
+    }
+    keep[relKey] = secret.VaultKey
+  }
+
+  plan, err := BuildSecretPlan(secrets, secretsFile, client)
+  if err != nil {
+    return plan, err
+  }
+
+  if !opts.Prune {
+    return plan, nil
+  }
+
+  logger.LogDebug("Finding secrets to prune")
+  pruneEntries, err := prunePlanEntries(client, scopeKey, keep, nil, nil, opts.Protected, opts.Destroy)
+  if err != nil {
+    return plan, err
+  }
+
+  if opts.MaxDeletions >= 0 && len(pruneEntries) > opts.MaxDeletions {
+    logger.LogError("Error sync would delete more than the max deletions")
+    return plan, fmt.Errorf("sync would delete %d secrets which is more than the max of %d",
+      len(pruneEntries), opts.MaxDeletions)
+  }
+
+  plan.Entries = append(plan.Entries, pruneEntries...)
+  return plan, nil
+}
+
+/*
+This will get the key for the folder that the sync owns
+*/
+func syncScopeKey(mount string, scope string) string {
+  mount = strings.Trim(mount, "/")
+  scope = strings.Trim(scope, "/")
+
+  if scope == "" {
+    return mount
+  }
+  return mount + "/" + scope
+}
+
+/*
+This will get the key of a secret relative to the scope
+folder, kv v2 keys that include the data part are handled
+*/
+func keyInScope(secret VaultSecret, scopeKey string) (string, bool) {
+  key := strings.Trim(secret.VaultKey, "/")
+  mount := strings.TrimSuffix(MountFromKey(key), "/")
+
+  if secret.KvVersion == "2" {
+    dataPrefix := mount + "/data/"
+    if strings.HasPrefix(key, dataPrefix) {
+      key = mount + "/" + strings.TrimPrefix(key, dataPrefix)
+    }
+  }
+
+  if !strings.HasPrefix(key, scopeKey+"/") {
+    return "", false
+  }
+  return strings.TrimPrefix(key, scopeKey+"/"), true
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for sync scope helpers
+*/
+func TestSyncScopeKey(t *testing.T) {
+  assert.Equal(t, "secret", syncScopeKey("secret/", ""))
+  assert.Equal(t, "secret/app", syncScopeKey("secret/", "/app/"))
+}
+
+func TestKeyInScope(t *testing.T) {
+  secret := VaultSecret{VaultKey: "secret/app/db", KvVersion: "2"}
+  relKey, ok := keyInScope(secret, "secret")
+  assert.True(t, ok)
+  assert.Equal(t, "app/db", relKey)
+
+  secret = VaultSecret{VaultKey: "secret/data/app/db", KvVersion: "2"}
+  relKey, ok = keyInScope(secret, "secret/app")
+  assert.True(t, ok)
+  assert.Equal(t, "db", relKey)
+
+  secret = VaultSecret{VaultKey: "other/app/db", KvVersion: "1"}
+  _, ok = keyInScope(secret, "secret")
+  assert.False(t, ok)
+}
+
+/*
+   Tests for sync prune
+*/
+func TestSyncPruneSoftDeletes(t *testing.T) {
+  fake, client := newFakeVault(t, map[string]string{"secret/": "2"})
+
+  fake.put("secret/app/db", map[string]interface{}{"password": "old"})
+  fake.put("secret/app/stale", map[string]interface{}{"key": "1"})
+  fake.put("secret/app/stale", map[string]interface{}{"key": "2"})
+
+  secret, err := NewSecret("secret/app/db", "", "",
+    map[string]interface{}{"password": "new"}, *client)
+  assert.NoError(t, err)
+  secrets := VaultSecrets{Secrets: map[string]VaultSecret{"db": secret}}
+
+  opts := SyncOptions{Mount: "secret/", Scope: "app", Prune: true, MaxDeletions: -1}
+  plan, err := BuildSyncPlan(secrets, "secrets.json", client, opts)
+  assert.NoError(t, err)
+
+  result := plan.Apply(client)
+  assert.Empty(t, result.Errors)
+  assert.Equal(t, []string{"secret/app/stale"}, result.Deleted)
+  assert.Nil(t, fake.current("secret/app/stale"))
+  assert.Equal(t, 2, fake.versionCount("secret/app/stale"))
+
+  fake.put("secret/app/stale", map[string]interface{}{"key": "3"})
+  opts.Destroy = true
+  plan, err = BuildSyncPlan(secrets, "secrets.json", client, opts)
+  assert.NoError(t, err)
+
+  result = plan.Apply(client)
+  assert.Empty(t, result.Errors)
+  assert.Equal(t, 0, fake.versionCount("secret/app/stale"))
+}
@@ -0,0 +1,196 @@
+package app
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/dgutierrez1287/vault-util/logger"
+)
+
+/*
+Returned when a secret was changed by someone else
+after it was written so it was not rolled back
+*/
+var ErrRollbackConflict = errors.New("secret was changed after it was written, not rolled back")
+
+/*
+SecretSnapshot - the state of a secret before
+it was written so it can be rolled back
+*/
+type SecretSnapshot struct {
+  Existed bool
+  Data map[string]interface{}
+  Version int64
+}
+
+/*
+RollbackResult - the results of rolling back
+a failed atomic write
+*/
+type RollbackResult struct {
+  Restored []string
+  Deleted []string
+  Conflicts []string
+  Errors []SecretActionError
+}
+
+/*
+This will snapshot the current data of every secret, for
+kv v2 secrets the current version number is also kept. It
+comes from the metadata so it is set when the current
+version is deleted and the secret has a history
+*/
+func (vs VaultSecrets) Snapshot(client *VaultClient) (map[string]SecretSnapshot, error) {
+  var lock sync.Mutex
+  snapshots := make(map[string]SecretSnapshot)
+
+  results := RunBulk(vs.SecretNames(), client.Concurrency(), func(name string) error {
+    secret := vs.Secrets[name]
+
+    logger.LogDebug("Taking snapshot of secret", "key", secret.VaultKey)
+    data, exists, err := secret.ReadCurrentData(client)
+    if err != nil {
+      return err
+    }
+
+    snapshot := SecretSnapshot{
+      Existed: exists,
+      Data: data,
+    }
+
+    if secret.KvVersion == "2" {
+      metadata, err := client.ReadKvMetadata(secret)
+      if err != nil && !IsNotFoundError(err) {
+        return err
+      }
+      snapshot.Version = metadata.CurrentVersion
+    }
+
+    lock.Lock()
+    snapshots[name] = snapshot
+    lock.Unlock()
+    return nil
+  })
+
+  for _, result := range results {
+    if result.Err != nil {
+      logger.LogError("Error taking snapshot of secret")
+      return snapshots, result.Err
+    }
+  }
+  return snapshots, nil
+}
+
+/*
+This will write all the secrets as one unit, a snapshot is
+taken first and if any write fails every secret that was
+written is put back to how it was and new secrets are deleted
+*/
+func (vs VaultSecrets) WriteSecretsAtomic(client *VaultClient) ([]string,
+  []SecretActionError, *RollbackResult, error) {
+
+  logger.LogDebug("Taking snapshot before writing")
+  snapshots, err := vs.Snapshot(client)
+  if err != nil {
+    return nil, nil, nil, err
+  }
+
+  secretsWritten, secretErrors := vs.WriteSecrets(client)
+  if len(secretErrors) == 0 {
+    return secretsWritten, secretErrors, nil, nil
+  }
+
+  logger.LogError("Error writing secrets, rolling back")
+  rollback := vs.Rollback(client, secretsWritten, snapshots)
+  return nil, secretErrors, &rollback, nil
+}
+
+/*
+This will roll back the named secrets to their snapshots,
+secrets that did not exist before are deleted. Kv v2 secrets
+are restored with check-and-set against the version the write
+made so a change made by someone else since then is reported
+as a conflict instead of being overwritten
+*/
+func (vs VaultSecrets) Rollback(client *VaultClient, names []string,
+  snapshots map[string]SecretSnapshot) RollbackResult {
+
+  var result RollbackResult
+
+  results := RunBulk(names, client.Concurrency(), func(name string) error {
+    secret := vs.Secrets[name]
+    snapshot := snapshots[name]
+
+    if !snapshot.Existed {
+      logger.LogDebug("Secret was created, deleting", "key", secret.VaultKey)
+      return rollbackCreated(client, secret, snapshot)
+    }
+
+    logger.LogDebug("Restoring secret", "key", secret.VaultKey,
+      "version", snapshot.Version)
+    secret.SecretData = snapshot.Data
+    if snapshot.Version == 0 {
+      return secret.WriteSecret(client)
+    }
+
+    err := client.WriteKvSecretCas(secret, snapshot.Version+1)
+    if IsCasMismatchError(err) {
+      return ErrRollbackConflict
+    }
+    return err
+  })
+
+  succeeded, failed := SplitBulkResults(results)
+  for _, name := range succeeded {
+    if snapshots[name].Existed {
+      result.Restored = append(result.Restored, name)
+    } else {
+      result.Deleted = append(result.Deleted, name)
+    }
+  }
+
+  for _, failure := range failed {
+    if errors.Is(failure.Err, ErrRollbackConflict) {
+      logger.LogError("Error secret was changed after it was written, not rolling back")
+      result.Conflicts = append(result.Conflicts, failure.Key)
+      continue
+    }
+
+    logger.LogError("Error rolling back secret")
+    result.Errors = append(result.Errors, SecretActionError{
+      VaultKey: vs.Secrets[failure.Key].VaultKey,
+      Error: failure.Err,
+    })
+  }
+  return result
+}
+
+/*
+This will undo the write to a secret that did not exist
+before, for kv v2 the written version must still be the
+current version. If the secret has a history from before
+only the written version is deleted, the metadata is only
+removed when the write made it
+*/
+func rollbackCreated(client *VaultClient, secret VaultSecret,
+  snapshot SecretSnapshot) error {
+
+  if secret.KvVersion != "2" {
+    return secret.DeleteSecret(client)
+  }
+
+  current, err := secret.CurrentVersion(client)
+  if err != nil {
+    return err
+  }
+  if current != snapshot.Version+1 {
+    return ErrRollbackConflict
+  }
+
+  if snapshot.Version > 0 {
+    logger.LogDebug("Secret has a history, deleting the written version",
+      "key", secret.VaultKey)
+    return secret.SoftDeleteSecret(client)
+  }
+  return secret.DeleteSecret(client)
+}
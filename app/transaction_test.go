@@ -0,0 +1,110 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for atomic writes and rollback
+*/
+func newAtomicSecrets(t *testing.T, client *VaultClient,
+  data map[string]map[string]interface{}) VaultSecrets {
+
+  secrets := VaultSecrets{Secrets: make(map[string]VaultSecret)}
+  for key, secretData := range data {
+    secret, err := NewSecret(key, "", "", secretData, *client)
+    assert.NoError(t, err)
+    secrets.Secrets[key] = secret
+  }
+  return secrets
+}
+
+func TestSnapshotVersions(t *testing.T) {
+  fake, client := newFakeVault(t, map[string]string{"secret/": "2"})
+
+  fake.put("secret/app/live", map[string]interface{}{"key": "1"})
+  fake.put("secret/app/live", map[string]interface{}{"key": "2"})
+  fake.put("secret/app/deleted", map[string]interface{}{"key": "1"})
+  fake.softDelete("secret/app/deleted")
+
+  secrets := newAtomicSecrets(t, client, map[string]map[string]interface{}{
+    "secret/app/live": {"key": "new"},
+    "secret/app/deleted": {"key": "new"},
+    "secret/app/new": {"key": "new"},
+  })
+
+  snapshots, err := secrets.Snapshot(client)
+  assert.NoError(t, err)
+  assert.Equal(t, SecretSnapshot{Existed: true, Data: map[string]interface{}{"key": "2"},
+    Version: 2}, snapshots["secret/app/live"])
+  assert.Equal(t, SecretSnapshot{Version: 1}, snapshots["secret/app/deleted"])
+  assert.Equal(t, SecretSnapshot{}, snapshots["secret/app/new"])
+}
+
+func TestWriteSecretsAtomicRollback(t *testing.T) {
+  fake, client := newFakeVault(t, map[string]string{"secret/": "2"})
+
+  fake.put("secret/app/live", map[string]interface{}{"key": "old"})
+  fake.put("secret/app/deleted", map[string]interface{}{"key": "old"})
+  fake.put("secret/app/deleted", map[string]interface{}{"key": "older"})
+  fake.softDelete("secret/app/deleted")
+  fake.failWrite("secret/app/fails")
+
+  secrets := newAtomicSecrets(t, client, map[string]map[string]interface{}{
+    "secret/app/live": {"key": "new"},
+    "secret/app/deleted": {"key": "new"},
+    "secret/app/new": {"key": "new"},
+    "secret/app/fails": {"key": "new"},
+  })
+
+  written, secretErrors, rollback, err := secrets.WriteSecretsAtomic(client)
+  assert.NoError(t, err)
+  assert.Nil(t, written)
+  assert.Len(t, secretErrors, 1)
+  assert.NotNil(t, rollback)
+  assert.Empty(t, rollback.Errors)
+  assert.Empty(t, rollback.Conflicts)
+  assert.Equal(t, []string{"secret/app/live"}, rollback.Restored)
+  assert.ElementsMatch(t, []string{"secret/app/deleted", "secret/app/new"}, rollback.Deleted)
+
+  assert.Equal(t, map[string]interface{}{"key": "old"}, fake.current("secret/app/live"))
+
+  // the history from before the run is kept, only the written version is deleted
+  assert.Nil(t, fake.current("secret/app/deleted"))
+  assert.Equal(t, 3, fake.versionCount("secret/app/deleted"))
+
+  assert.Equal(t, 0, fake.versionCount("secret/app/new"))
+}
+
+func TestRollbackConflicts(t *testing.T) {
+  fake, client := newFakeVault(t, map[string]string{"secret/": "2"})
+
+  fake.put("secret/app/live", map[string]interface{}{"key": "old"})
+
+  secrets := newAtomicSecrets(t, client, map[string]map[string]interface{}{
+    "secret/app/live": {"key": "new"},
+    "secret/app/new": {"key": "new"},
+  })
+
+  snapshots, err := secrets.Snapshot(client)
+  assert.NoError(t, err)
+
+  written, secretErrors := secrets.WriteSecrets(client)
+  assert.Empty(t, secretErrors)
+
+  // someone else writes both secrets after the run
+  fake.put("secret/app/live", map[string]interface{}{"key": "theirs"})
+  fake.put("secret/app/new", map[string]interface{}{"key": "theirs"})
+
+  rollback := secrets.Rollback(client, written, snapshots)
+  assert.Empty(t, rollback.Errors)
+  assert.Empty(t, rollback.Restored)
+  assert.Empty(t, rollback.Deleted)
+  assert.ElementsMatch(t, []string{"secret/app/live", "secret/app/new"}, rollback.Conflicts)
+
+  assert.Equal(t, map[string]interface{}{"key": "theirs"}, fake.current("secret/app/live"))
+  assert.Equal(t, map[string]interface{}{"key": "theirs"}, fake.current("secret/app/new"))
+  assert.Equal(t, 2, fake.versionCount("secret/app/new"))
+}
@@ -0,0 +1,476 @@
+package app
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// the url the secrets file schema is published under
+const SecretsFileSchemaUrl = "https://github.com/dgutierrez1287/vault-util/schema/secrets-file.schema.json"
+
+/*
+The JSON Schema for secrets files, it can be printed
+with validate-secrets-file --print-schema
+*/
+//go:embed schema/secrets-file.schema.json
+var SecretsFileSchema []byte
+
+var compileSchemaOnce sync.Once
+var compiledSchema *jsonschema.Schema
+var compileSchemaErr error
+
+/*
+ValidationIssue - a single problem found in a secrets
+file, the line and column are 0 when the problem is not
+tied to a place in the file
+*/
+type ValidationIssue struct {
+  Secret string                   `json:"secret,omitempty"`
+  Location string                 `json:"location,omitempty"`
+  Line int                        `json:"line,omitempty"`
+  Column int                      `json:"column,omitempty"`
+  Message string                  `json:"message"`
+}
+
+/*
+ValidationError - the error returned when a secrets
+file has problems
+*/
+type ValidationError struct {
+  SecretsFile string
+  Issues []ValidationIssue
+}
+
+/*
+fileLocations - where every value in a json file
+starts, keyed by json pointer
+*/
+type fileLocations struct {
+  data []byte
+  offsets map[string]int
+}
+
+/*
+Formats an issue as line:column: message
+*/
+func (i ValidationIssue) String() string {
+  message := i.Message
+  if i.Secret != "" {
+    message = fmt.Sprintf("secret %s: %s", i.Secret, i.Message)
+  }
+
+  if i.Line == 0 {
+    return message
+  }
+  return fmt.Sprintf("%d:%d: %s", i.Line, i.Column, message)
+}
+
+func (e *ValidationError) Error() string {
+  var issues []string
+  for _, issue := range e.Issues {
+    issues = append(issues, issue.String())
+  }
+  return fmt.Sprintf("%s is not valid: %s", e.SecretsFile, strings.Join(issues, "; "))
+}
+
+/*
+This will validate a secrets file, the file is checked against
+the schema and then, if there is a client, every secret is checked
+against vault. Encrypted files do not need to be decrypted
+*/
+func ValidateSecretsFile(secretsFilePath string, client *VaultClient) ([]ValidationIssue, error) {
+  data, err := readSecretsFileBytes(secretsFilePath)
+  if err != nil {
+    return nil, err
+  }
+
+  issues, locations := ValidateSecretsFileSchema(data)
+  if len(issues) > 0 || client == nil {
+    return issues, nil
+  }
+
+  var secrets VaultSecrets
+  err = json.Unmarshal(data, &secrets)
+  if err != nil {
+    logger.LogError("Error unmarshaling json to struct")
+    return nil, err
+  }
+  return secrets.preflight(client, locations), nil
+}
+
+/*
+This will check a secrets file against the schema, syntax
+errors and duplicate json keys are reported as well
+*/
+func ValidateSecretsFileSchema(data []byte) ([]ValidationIssue, fileLocations) {
+  locations := fileLocations{data: data, offsets: make(map[string]int)}
+
+  var document interface{}
+  decoder := json.NewDecoder(bytes.NewReader(data))
+  decoder.UseNumber()
+  err := decoder.Decode(&document)
+  if err != nil {
+    return []ValidationIssue{locations.syntaxIssue(err)}, locations
+  }
+
+  issues := locations.scan()
+
+  schema, err := secretsFileSchema()
+  if err != nil {
+    logger.LogError("Error compiling the secrets file schema")
+    return append(issues, ValidationIssue{Message: err.Error()}), locations
+  }
+
+  err = schema.Validate(document)
+  var validationErr *jsonschema.ValidationError
+  if errors.As(err, &validationErr) {
+    for _, leaf := range schemaLeafErrors(validationErr) {
+      issues = append(issues, locations.issue(leaf.InstanceLocation, leaf.Message))
+    }
+  } else if err != nil {
+    issues = append(issues, ValidationIssue{Message: err.Error()})
+  }
+
+  sortIssues(issues)
+  return issues, locations
+}
+
+/*
+This will check every secret against vault before anything is
+written, the secret details are filled in as they are found. The
+mount is always looked up even if the file has the secret type
+and kv version, they must match the mount. The mount must exist
+and be kv, no two secrets can have the same target key and every
+secret must have data
+*/
+func (vs *VaultSecrets) preflight(client *VaultClient,
+  locations fileLocations) []ValidationIssue {
+
+  var issues []ValidationIssue
+  var lock sync.Mutex
+
+  addIssue := func(name string, field string, message string) {
+    issue := locations.issue("/secrets/"+escapePointer(name)+field, message)
+    issue.Secret = name
+    lock.Lock()
+    issues = append(issues, issue)
+    lock.Unlock()
+  }
+
+  RunBulk(vs.SecretNames(), client.Concurrency(), func(name string) error {
+    lock.Lock()
+    secret := vs.Secrets[name]
+    lock.Unlock()
+
+    if len(secret.SecretData) == 0 {
+      addIssue(name, "/data", "secret has no data")
+    }
+
+    declaredType, declaredVersion := secret.SecretType, secret.KvVersion
+    secret.SecretType = ""
+    secret.KvVersion = ""
+
+    logger.LogDebug("Getting details for secret", "name", name)
+    err := secret.getSecretDetails(client)
+    if err != nil {
+      addIssue(name, "/key", fmt.Sprintf("cannot use key %s: %s", secret.VaultKey, err))
+      return err
+    }
+
+    for _, mismatch := range detailMismatches(declaredType, declaredVersion, secret) {
+      addIssue(name, mismatch[0], mismatch[1])
+    }
+
+    if secret.SecretType != "kv" {
+      addIssue(name, "/key", fmt.Sprintf("mount %s is a %s mount, only kv mounts are supported",
+        secret.MountName, secret.SecretType))
+    }
+
+    lock.Lock()
+    vs.Secrets[name] = secret
+    lock.Unlock()
+    return nil
+  })
+
+  targets := make(map[string][]string)
+  for _, name := range vs.SecretNames() {
+    target := targetKey(vs.Secrets[name])
+    targets[target] = append(targets[target], name)
+  }
+  for target, names := range targets {
+    if len(names) < 2 {
+      continue
+    }
+    for _, name := range names[1:] {
+      addIssue(name, "/key", fmt.Sprintf("target key %s is also used by secret %s",
+        target, names[0]))
+    }
+  }
+
+  sortIssues(issues)
+  return issues
+}
+
+/*
+This will compare the secret type and kv version set in the
+file with the ones found for the mount, each mismatch is
+returned as the field and a message
+*/
+func detailMismatches(declaredType string, declaredVersion string,
+  resolved VaultSecret) [][2]string {
+
+  var mismatches [][2]string
+
+  if declaredType != "" && declaredType != resolved.SecretType {
+    mismatches = append(mismatches, [2]string{"/secretType",
+      fmt.Sprintf("secret type is %s but mount %s is a %s mount",
+        declaredType, resolved.MountName, resolved.SecretType)})
+  }
+
+  if declaredVersion != "" && resolved.SecretType == "kv" &&
+    declaredVersion != resolved.KvVersion {
+    mismatches = append(mismatches, [2]string{"/kvVersion",
+      fmt.Sprintf("kv version is %s but mount %s is kv version %s",
+        declaredVersion, resolved.MountName, resolved.KvVersion)})
+  }
+  return mismatches
+}
+
+/*
+This will get the key a secret is written to, kv v2
+keys with and without the data part are the same key
+*/
+func targetKey(secret VaultSecret) string {
+  if secret.NormalizedSecretPath != "" {
+    return strings.Trim(secret.NormalizedSecretPath, "/")
+  }
+  return strings.Trim(secret.VaultKey, "/")
+}
+
+/*
+This will compile the embedded schema once
+*/
+func secretsFileSchema() (*jsonschema.Schema, error) {
+  compileSchemaOnce.Do(func() {
+    compiler := jsonschema.NewCompiler()
+    compiler.AssertFormat = true
+    compileSchemaErr = compiler.AddResource(SecretsFileSchemaUrl, bytes.NewReader(SecretsFileSchema))
+    if compileSchemaErr == nil {
+      compiledSchema, compileSchemaErr = compiler.Compile(SecretsFileSchemaUrl)
+    }
+  })
+  return compiledSchema, compileSchemaErr
+}
+
+/*
+This will get the errors at the bottom of a schema
+validation error, they are the ones that say what is wrong
+*/
+func schemaLeafErrors(err *jsonschema.ValidationError) []*jsonschema.ValidationError {
+  if len(err.Causes) == 0 {
+    return []*jsonschema.ValidationError{err}
+  }
+
+  var leaves []*jsonschema.ValidationError
+  for _, cause := range err.Causes {
+    leaves = append(leaves, schemaLeafErrors(cause)...)
+  }
+  return leaves
+}
+
+/*
+This will create an issue at a json pointer, the secret
+name is taken from the pointer when it is inside a secret
+*/
+func (l fileLocations) issue(pointer string, message string) ValidationIssue {
+  issue := ValidationIssue{
+    Location: pointer,
+    Message: message,
+  }
+
+  parts := strings.Split(pointer, "/")
+  if len(parts) > 2 && parts[1] == "secrets" {
+    issue.Secret = unescapePointer(parts[2])
+  }
+
+  if offset, ok := l.offsets[pointer]; ok {
+    issue.Line, issue.Column = l.position(offset)
+  }
+  return issue
+}
+
+/*
+This will create an issue for a json syntax error
+*/
+func (l fileLocations) syntaxIssue(err error) ValidationIssue {
+  issue := ValidationIssue{Message: err.Error()}
+
+  var syntaxErr *json.SyntaxError
+  if errors.As(err, &syntaxErr) {
+    // the offset is just after the character that failed
+    offset := int(syntaxErr.Offset) - 1
+    if offset < 0 {
+      offset = 0
+    }
+    issue.Line, issue.Column = l.position(offset)
+  } else if errors.Is(err, io.EOF) {
+    issue.Message = "file is empty"
+  }
+  return issue
+}
+
+/*
+This will get the line and column of a byte offset
+*/
+func (l fileLocations) position(offset int) (int, int) {
+  if offset > len(l.data) {
+    offset = len(l.data)
+  }
+
+  line := bytes.Count(l.data[:offset], []byte("\n")) + 1
+  lineStart := bytes.LastIndexByte(l.data[:offset], '\n') + 1
+  return line, utf8.RuneCount(l.data[lineStart:offset]) + 1
+}
+
+/*
+This will find where every value in the file starts,
+json keys that appear twice in the same object are
+returned as issues since only the last one is used
+*/
+func (l *fileLocations) scan() []ValidationIssue {
+  var issues []ValidationIssue
+  decoder := json.NewDecoder(bytes.NewReader(l.data))
+
+  var scanValue func(pointer string) error
+  scanValue = func(pointer string) error {
+    l.offsets[pointer] = l.valueStart(decoder.InputOffset())
+
+    token, err := decoder.Token()
+    if err != nil {
+      return err
+    }
+
+    switch token {
+    case json.Delim('{'):
+      seen := make(map[string]bool)
+      for decoder.More() {
+        keyStart := l.valueStart(decoder.InputOffset())
+        keyToken, err := decoder.Token()
+        if err != nil {
+          return err
+        }
+
+        key := keyToken.(string)
+        childPointer := pointer + "/" + escapePointer(key)
+        if seen[key] {
+          line, column := l.position(keyStart)
+          issues = append(issues, ValidationIssue{
+            Location: childPointer,
+            Line: line,
+            Column: column,
+            Message: fmt.Sprintf("duplicate key %s, only the last one is used", key),
+          })
+        }
+        seen[key] = true
+
+        err = scanValue(childPointer)
+        if err != nil {
+          return err
+        }
+      }
+      _, err = decoder.Token()
+      return err
+
+    case json.Delim('['):
+      for i := 0; decoder.More(); i++ {
+        err = scanValue(pointer + "/" + strconv.Itoa(i))
+        if err != nil {
+          return err
+        }
+      }
+      _, err = decoder.Token()
+      return err
+    }
+    return nil
+  }
+
+  err := scanValue("")
+  if err != nil {
+    logger.LogDebug("Error scanning secrets file for locations", "error", err)
+  }
+
+  for i := range issues {
+    parts := strings.Split(issues[i].Location, "/")
+    if len(parts) > 2 && parts[1] == "secrets" {
+      issues[i].Secret = unescapePointer(parts[2])
+    }
+  }
+  return issues
+}
+
+/*
+This will skip the whitespace and separators before
+a value to find where it starts
+*/
+func (l fileLocations) valueStart(offset int64) int {
+  start := int(offset)
+  for start < len(l.data) {
+    switch l.data[start] {
+    case ' ', '\t', '\r', '\n', ':', ',':
+      start++
+    default:
+      return start
+    }
+  }
+  return start
+}
+
+/*
+This will escape a json pointer part the same way
+the schema validator does
+*/
+func escapePointer(part string) string {
+  part = strings.ReplaceAll(part, "~", "~0")
+  part = strings.ReplaceAll(part, "/", "~1")
+  return url.PathEscape(part)
+}
+
+/*
+This will unescape a json pointer part
+*/
+func unescapePointer(part string) string {
+  unescaped, err := url.PathUnescape(part)
+  if err == nil {
+    part = unescaped
+  }
+  part = strings.ReplaceAll(part, "~1", "/")
+  return strings.ReplaceAll(part, "~0", "~")
+}
+
+/*
+This will sort issues by where they are in the file
+*/
+func sortIssues(issues []ValidationIssue) {
+  sort.SliceStable(issues, func(i, j int) bool {
+    if issues[i].Line != issues[j].Line {
+      return issues[i].Line < issues[j].Line
+    }
+    if issues[i].Column != issues[j].Column {
+      return issues[i].Column < issues[j].Column
+    }
+    return issues[i].Message < issues[j].Message
+  })
+}
@@ -0,0 +1,109 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for secrets file schema validation
+*/
+func TestValidateSecretsFileSchemaValid(t *testing.T) {
+  data := []byte(`{
+  "secrets": {
+    "app": {
+      "key": "secret/app/config",
+      "data": {"user": "admin", "port": 5432}
+    }
+  }
+}`)
+
+  issues, _ := ValidateSecretsFileSchema(data)
+  assert.Empty(t, issues)
+}
+
+func TestValidateSecretsFileSchemaLocations(t *testing.T) {
+  data := []byte(`{
+  "secrets": {
+    "app": {
+      "key": "secret/app/config",
+      "dat": {"user": "admin"}
+    },
+    "db": {
+      "key": 5,
+      "data": {}
+    }
+  }
+}`)
+
+  issues, _ := ValidateSecretsFileSchema(data)
+  assert.Len(t, issues, 3)
+
+  assert.Equal(t, "app", issues[0].Secret)
+  assert.Equal(t, 3, issues[0].Line)
+  assert.Equal(t, 12, issues[0].Column)
+
+  assert.Equal(t, "db", issues[2].Secret)
+  assert.Equal(t, "/secrets/db/key", issues[2].Location)
+  assert.Equal(t, 8, issues[2].Line)
+  assert.Equal(t, 14, issues[2].Column)
+}
+
+func TestValidateSecretsFileSchemaSyntaxError(t *testing.T) {
+  data := []byte("{\n  \"secrets\": {\n    \"app\": ,\n  }\n}")
+
+  issues, _ := ValidateSecretsFileSchema(data)
+  assert.Len(t, issues, 1)
+  assert.Equal(t, 3, issues[0].Line)
+  assert.Equal(t, 12, issues[0].Column)
+}
+
+func TestValidateSecretsFileSchemaDuplicateKeys(t *testing.T) {
+  data := []byte(`{
+  "secrets": {
+    "app": {"key": "secret/a", "data": {"a": "1"}},
+    "app": {"key": "secret/b", "data": {"b": "2"}}
+  }
+}`)
+
+  issues, _ := ValidateSecretsFileSchema(data)
+  assert.Len(t, issues, 1)
+  assert.Equal(t, "app", issues[0].Secret)
+  assert.Equal(t, 4, issues[0].Line)
+  assert.Equal(t, 5, issues[0].Column)
+  assert.Contains(t, issues[0].Message, "duplicate key app")
+}
+
+func TestTargetKey(t *testing.T) {
+  assert.Equal(t, "secret/data/app", targetKey(VaultSecret{
+    VaultKey: "secret/app",
+    NormalizedSecretPath: "secret/data/app",
+  }))
+  assert.Equal(t, "secret/app", targetKey(VaultSecret{VaultKey: "/secret/app/"}))
+}
+
+func TestDetailMismatches(t *testing.T) {
+  resolved := VaultSecret{
+    VaultKey: "secret/app",
+    SecretType: "kv",
+    KvVersion: "2",
+    MountName: "secret/",
+  }
+
+  assert.Empty(t, detailMismatches("", "", resolved))
+  assert.Empty(t, detailMismatches("kv", "2", resolved))
+
+  mismatches := detailMismatches("kv", "1", resolved)
+  assert.Len(t, mismatches, 1)
+  assert.Equal(t, "/kvVersion", mismatches[0][0])
+
+  mismatches = detailMismatches("transit", "", resolved)
+  assert.Len(t, mismatches, 1)
+  assert.Equal(t, "/secretType", mismatches[0][0])
+}
+
+func TestPointerEscaping(t *testing.T) {
+  assert.Equal(t, "a~1b~0c", escapePointer("a/b~c"))
+  assert.Equal(t, "a/b~c", unescapePointer(escapePointer("a/b~c")))
+}
@@ -0,0 +1,217 @@
+package app
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dgutierrez1287/vault-util/logger"
+)
+
+/*
+SkippedFolder - a folder the walker could not list,
+nothing under it was walked
+*/
+type SkippedFolder struct {
+  Path string                   `json:"path"`
+  Error string                  `json:"error"`
+}
+
+/*
+WalkResult - what a walk did not visit, the folders not
+walked because of the max depth and the folders that
+could not be listed
+*/
+type WalkResult struct {
+  SecretCount int
+  Folders []string
+  Skipped []SkippedFolder
+}
+
+/*
+walkJob - a folder waiting to be listed
+*/
+type walkJob struct {
+  path string
+  depth int
+}
+
+/*
+walkQueue - the folders waiting to be listed, it is shared
+by the workers and is done when it is empty and no worker
+is listing a folder
+*/
+type walkQueue struct {
+  lock sync.Mutex
+  cond *sync.Cond
+  jobs []walkJob
+  active int
+  stopped bool
+}
+
+/*
+This will walk the secrets in the mount that are under the
+prefix, no deeper than the max depth and match the glob. Folders
+are listed by as many workers as the client concurrency and
+every secret is passed to visit as it is found, visit is never
+called by two workers at once. Folders the token cannot list
+are skipped and returned, any other error stops the walk
+*/
+func (sm SecretMount) WalkSecrets(client *VaultClient, opts ListOptions,
+  visit func(key string) error) (WalkResult, error) {
+
+  var result WalkResult
+
+  if sm.Type != "kv" {
+    return result, nil
+  }
+  logger.LogDebug("Secret mount is a kv")
+
+  prefix := strings.TrimPrefix(opts.Prefix, "/")
+  if prefix != "" && !strings.HasSuffix(prefix, "/") {
+    prefix = prefix + "/"
+  }
+
+  queue := &walkQueue{}
+  queue.cond = sync.NewCond(&queue.lock)
+  queue.push(walkJob{path: sm.Mount + prefix, depth: 1})
+
+  var lock sync.Mutex
+  var walkErr error
+
+  fail := func(err error) {
+    lock.Lock()
+    if walkErr == nil {
+      walkErr = err
+    }
+    lock.Unlock()
+    queue.stop()
+  }
+
+  var wg sync.WaitGroup
+  for i := 0; i < client.Concurrency(); i++ {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      for {
+        job, ok := queue.pop()
+        if !ok {
+          return
+        }
+
+        respKeys, err := client.ListKvSecrets(sm.Mount, job.path, sm.KvVersion)
+        if err != nil {
+          switch {
+          case IsNotFoundError(err):
+            // the folder is empty or was removed while walking
+            logger.LogDebug("Folder not found, skipping", "path", job.path)
+          case IsPermissionDeniedError(err):
+            logger.LogDebug("Permission denied listing folder, skipping", "path", job.path)
+            lock.Lock()
+            result.Skipped = append(result.Skipped, SkippedFolder{
+              Path: job.path,
+              Error: "permission denied",
+            })
+            lock.Unlock()
+          default:
+            logger.LogError("Error listing folder")
+            fail(err)
+          }
+          queue.done()
+          continue
+        }
+
+        for _, key := range respKeys {
+          fullPath := job.path + key
+          if strings.HasSuffix(key, "/") {
+            if opts.MaxDepth > 0 && job.depth >= opts.MaxDepth {
+              lock.Lock()
+              result.Folders = append(result.Folders, fullPath)
+              lock.Unlock()
+              continue
+            }
+            queue.push(walkJob{path: fullPath, depth: job.depth + 1})
+            continue
+          }
+
+          //leaf secret, pass to visit
+          if opts.Glob != "" && !GlobMatch(opts.Glob, strings.TrimPrefix(fullPath, sm.Mount)) {
+            continue
+          }
+          lock.Lock()
+          if walkErr == nil {
+            result.SecretCount++
+            err = visit(fullPath)
+          }
+          lock.Unlock()
+          if err != nil {
+            fail(err)
+            break
+          }
+        }
+        queue.done()
+      }
+    }()
+  }
+  wg.Wait()
+
+  sort.Strings(result.Folders)
+  sort.Slice(result.Skipped, func(i, j int) bool {
+    return result.Skipped[i].Path < result.Skipped[j].Path
+  })
+  return result, walkErr
+}
+
+/*
+This will add a folder to the queue
+*/
+func (q *walkQueue) push(job walkJob) {
+  q.lock.Lock()
+  q.jobs = append(q.jobs, job)
+  q.lock.Unlock()
+  q.cond.Signal()
+}
+
+/*
+This will get the next folder to list, it waits while other
+workers may still find folders and returns false when the walk
+is done. The last folder found is listed first so the queue
+stays small on deep mounts
+*/
+func (q *walkQueue) pop() (walkJob, bool) {
+  q.lock.Lock()
+  defer q.lock.Unlock()
+
+  for len(q.jobs) == 0 && q.active > 0 && !q.stopped {
+    q.cond.Wait()
+  }
+  if len(q.jobs) == 0 || q.stopped {
+    return walkJob{}, false
+  }
+
+  job := q.jobs[len(q.jobs)-1]
+  q.jobs = q.jobs[:len(q.jobs)-1]
+  q.active++
+  return job, true
+}
+
+/*
+This will mark a folder as listed
+*/
+func (q *walkQueue) done() {
+  q.lock.Lock()
+  q.active--
+  q.lock.Unlock()
+  q.cond.Broadcast()
+}
+
+/*
+This will stop the walk, the workers return after
+the folder they are listing
+*/
+func (q *walkQueue) stop() {
+  q.lock.Lock()
+  q.stopped = true
+  q.lock.Unlock()
+  q.cond.Broadcast()
+}
@@ -0,0 +1,104 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	vaultGo "github.com/hashicorp/vault-client-go"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+   Tests for the walker queue
+*/
+func newTestQueue() *walkQueue {
+  queue := &walkQueue{}
+  queue.cond = sync.NewCond(&queue.lock)
+  return queue
+}
+
+func TestWalkQueueOrder(t *testing.T) {
+  queue := newTestQueue()
+  queue.push(walkJob{path: "a/", depth: 1})
+  queue.push(walkJob{path: "b/", depth: 1})
+
+  job, ok := queue.pop()
+  assert.True(t, ok)
+  assert.Equal(t, "b/", job.path)
+  queue.done()
+
+  job, ok = queue.pop()
+  assert.True(t, ok)
+  assert.Equal(t, "a/", job.path)
+  queue.done()
+
+  _, ok = queue.pop()
+  assert.False(t, ok)
+}
+
+func TestWalkQueueWorkers(t *testing.T) {
+  queue := newTestQueue()
+  queue.push(walkJob{path: "", depth: 1})
+
+  var lock sync.Mutex
+  var visited []string
+  var wg sync.WaitGroup
+  for i := 0; i < 4; i++ {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      for {
+        job, ok := queue.pop()
+        if !ok {
+          return
+        }
+        lock.Lock()
+        visited = append(visited, job.path)
+        lock.Unlock()
+
+        // every folder has two folders under it down to depth 4
+        if job.depth < 4 {
+          for i := 0; i < 2; i++ {
+            queue.push(walkJob{path: fmt.Sprintf("%s%d/", job.path, i), depth: job.depth + 1})
+          }
+        }
+        queue.done()
+      }
+    }()
+  }
+  wg.Wait()
+
+  assert.Len(t, visited, 15)
+}
+
+func TestWalkQueueStop(t *testing.T) {
+  queue := newTestQueue()
+  queue.push(walkJob{path: "a/", depth: 1})
+  queue.stop()
+
+  _, ok := queue.pop()
+  assert.False(t, ok)
+}
+
+/*
+   Tests for vault error checks
+*/
+func TestVaultErrorStatus(t *testing.T) {
+  notFound := &vaultGo.ResponseError{StatusCode: 404}
+  denied := &vaultGo.ResponseError{StatusCode: 403}
+
+  assert.True(t, IsNotFoundError(fmt.Errorf("listing: %w", notFound)))
+  assert.False(t, IsNotFoundError(denied))
+  assert.True(t, IsPermissionDeniedError(denied))
+  assert.False(t, IsPermissionDeniedError(errors.New("403 in a message")))
+
+  casMismatch := &vaultGo.ResponseError{StatusCode: 400,
+    Errors: []string{"check-and-set parameter did not match the current version"}}
+  badRequest := &vaultGo.ResponseError{StatusCode: 400, Errors: []string{"no data provided"}}
+
+  assert.True(t, IsCasMismatchError(fmt.Errorf("restoring: %w", casMismatch)))
+  assert.False(t, IsCasMismatchError(badRequest))
+  assert.False(t, IsCasMismatchError(denied))
+}
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/dgutierrez1287/vault-util/logger"
+)
+
+/*
+VersionWatcher - keeps the last seen version of kv v2
+secrets so changes can be found by polling the metadata.
+kv v1 secrets have no versions so they never change
+*/
+type VersionWatcher struct {
+  client *VaultClient
+  secrets []VaultSecret
+  versions map[string]int64
+  lock sync.Mutex
+}
+
+/*
+This will create a watcher for secrets, the current
+versions are read so only later changes are reported
+*/
+func NewVersionWatcher(client *VaultClient, secrets []VaultSecret) (*VersionWatcher, error) {
+  watcher := &VersionWatcher{
+    client: client,
+    secrets: secrets,
+    versions: make(map[string]int64),
+  }
+
+  _, err := watcher.Changed()
+  if err != nil {
+    return nil, err
+  }
+  return watcher, nil
+}
+
+/*
+This will create a watcher for secrets from the versions
+that were read with them, only changes after those versions
+are reported even if they happened before the watcher was made
+*/
+func NewVersionWatcherAt(client *VaultClient, secrets []VaultSecret,
+  versions map[string]int64) *VersionWatcher {
+
+  watcher := &VersionWatcher{
+    client: client,
+    secrets: secrets,
+    versions: make(map[string]int64),
+  }
+  for key, version := range versions {
+    watcher.versions[key] = version
+  }
+  return watcher
+}
+
+/*
+This will get the keys of the secrets whose version
+changed since the last check, the keys are sorted
+*/
+func (w *VersionWatcher) Changed() ([]string, error) {
+  w.lock.Lock()
+  defer w.lock.Unlock()
+
+  keys := make([]string, 0, len(w.secrets))
+  secrets := make(map[string]VaultSecret)
+  for _, secret := range w.secrets {
+    keys = append(keys, secret.VaultKey)
+    secrets[secret.VaultKey] = secret
+  }
+
+  var versionLock sync.Mutex
+  versions := make(map[string]int64)
+  results := RunBulk(keys, w.client.Concurrency(), func(key string) error {
+    version, err := secrets[key].CurrentVersion(w.client)
+    if err != nil {
+      return err
+    }
+    versionLock.Lock()
+    versions[key] = version
+    versionLock.Unlock()
+    return nil
+  })
+
+  _, failed := SplitBulkResults(results)
+  if len(failed) > 0 {
+    logger.LogError("Error checking secret versions")
+    return nil, fmt.Errorf("%s: %w", failed[0].Key, failed[0].Err)
+  }
+
+  var changed []string
+  for key, version := range versions {
+    lastVersion, seen := w.versions[key]
+    if seen && lastVersion != version {
+      logger.LogDebug("Secret version changed", "key", key, "from", lastVersion, "to", version)
+      changed = append(changed, key)
+    }
+    w.versions[key] = version
+  }
+  sort.Strings(changed)
+  return changed, nil
+}
+
+/*
+This will get the last seen version of a secret
+*/
+func (w *VersionWatcher) Version(key string) int64 {
+  w.lock.Lock()
+  defer w.lock.Unlock()
+  return w.versions[key]
+}
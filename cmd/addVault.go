@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/dgutierrez1287/vault-util/logger"
 	"github.com/dgutierrez1287/vault-util/app"
@@ -64,16 +63,11 @@ var addVaultCmd = &cobra.Command{
       logger.LogErrorExit("Error updating the config", 100, err)
     }
 
-    if !machineOutput {
+    machineReadableOutput.ExitCode = 0
+    machineReadableOutput.Message = fmt.Sprintf("%s vault successfully created", vaultName)
+    writeOutput(machineReadableOutput, func() {
       logger.LogInfo("Vault instance successfully added", "name", vaultName)
-      os.Exit(0)
-    } else {
-      machineReadableOutput.ExitCode = 0
-      machineReadableOutput.Message = fmt.Sprintf("%s vault successfully created", vaultName)
-      output, eCode := machineReadableOutput.GetOutputJson()
-      fmt.Println(output)
-      os.Exit(eCode)
-    }
+    }, 0)
   },
 }
 
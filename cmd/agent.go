@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/dgutierrez1287/vault-util/app"
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/dgutierrez1287/vault-util/util"
+	"github.com/spf13/cobra"
+)
+
+// agent flags
+var agentConfigFile string
+var agentOnce bool
+
+var agentCmd = &cobra.Command{
+  Use: "agent",
+  Short: "Keeps secrets written to files on disk",
+  Long: "Writes secrets to files listed in a json config file and keeps them up to date. Every file has a secretKey, an optional field and format, a destination, a mode, an owner in the form user or user:group and a hook that is run after the file changes. Files are written on start, then the kv v2 versions are polled every pollInterval and files are written again atomically when their secret changes. The health of the agent is written to the statusFile after every check. With --once the files are written and the agent exits",
+  Run: func(cmd *cobra.Command, args []string) {
+    if !machineOutput {
+      fmt.Println(util.TitleString)
+    }
+
+    if agentConfigFile == "" {
+      logger.LogErrorExit("Error no agent config file", 100, errors.New("--config is required"))
+    }
+
+    logger.LogInfo("Loading agent config")
+    config, err := app.LoadAgentConfig(agentConfigFile)
+    if err != nil {
+      logger.LogErrorExit("Error loading agent config", 100, err)
+    }
+
+    ctx := context.Background()
+    vaultClient := getVaultClient(&ctx)
+
+    // stop cleanly so the status file says the agent stopped
+    ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    err = app.RunAgent(ctx, vaultClient, config, agentOnce)
+    if err != nil {
+      logger.LogErrorExit("Error running agent", 250, err)
+    }
+  },
+}
+
+func init() {
+  // Command specific cli options
+  agentCmd.PersistentFlags().StringVarP(&agentConfigFile, "config", "", "", "The agent config file")
+  agentCmd.PersistentFlags().BoolVarP(&agentOnce, "once", "", false, "(Optional) Write the files once and exit")
+
+  // Add command
+  RootCmd.AddCommand(agentCmd)
+}
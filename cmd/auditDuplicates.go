@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dgutierrez1287/vault-util/app"
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/dgutierrez1287/vault-util/util"
+	"github.com/spf13/cobra"
+)
+
+// audit duplicates flags
+var auditMountNames []string
+var auditMinLength int
+
+var auditDuplicatesCmd = &cobra.Command{
+  Use: "audit-duplicates",
+  Short: "Finds secret values stored in more than one place",
+  Long: "Walks one or more kv mounts, or every kv mount, and reports the groups of secret fields that hold the same value. Values are compared by a keyed hash made for this run and are never printed. Use --output csv or --output json for reports",
+  Run: func(cmd *cobra.Command, args []string) {
+    var machineReadableOutput app.DuplicatesOutput
+
+    if !machineOutput {
+      fmt.Println(util.TitleString)
+    }
+
+    ctx := context.Background()
+    vaultClient := getVaultClient(&ctx)
+
+    mounts := auditMountNames
+    if mountName != "" {
+      mounts = append(mounts, mountName)
+    }
+
+    logger.LogInfo("Auditing secrets for duplicate values")
+    groups, secretErrors, err := app.FindDuplicates(vaultClient, mounts, auditMinLength)
+    if err != nil {
+      logger.LogErrorExit("Error auditing secrets", 250, err)
+    }
+
+    logger.LogDebug("Outputing results")
+    machineReadableOutput.ExitCode = 0
+    machineReadableOutput.Groups = groups
+    machineReadableOutput.Errors = secretErrors
+    writeOutput(machineReadableOutput, func() {
+      app.DuplicatesConsoleOutput(groups, secretErrors)
+    }, 0)
+  },
+}
+
+func init() {
+  // Command specific cli options
+  auditDuplicatesCmd.PersistentFlags().StringSliceVarP(&auditMountNames, "mount", "", nil, "(Optional) The kv mounts to audit, defaults to every kv mount")
+  auditDuplicatesCmd.PersistentFlags().IntVarP(&auditMinLength, "min-length", "", 8, "(Optional) Values shorter than this are not compared")
+
+  // Add command
+  RootCmd.AddCommand(auditDuplicatesCmd)
+}
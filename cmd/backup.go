@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dgutierrez1287/vault-util/app"
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/dgutierrez1287/vault-util/util"
+	"github.com/spf13/cobra"
+)
+
+// backup flags
+var backupFile string
+var backupMounts []string
+
+var backupCmd = &cobra.Command{
+  Use: "backup",
+  Short: "Backs up every kv mount to an encrypted archive",
+  Long: "Backs up the data, versions and metadata of every secret in every kv mount to a single compressed and encrypted archive, the archive can be restored with restore",
+  Run: func(cmd *cobra.Command, args []string) {
+    var machineReadableOutput app.BackupOutput
+
+    if !machineOutput {
+      fmt.Println(util.TitleString)
+    }
+
+    encryptOpts := app.EncryptionOptions{
+      AgeRecipients: ageRecipients,
+      TransitKey: transitKey,
+    }
+    if !encryptOpts.Enabled() {
+      logger.LogErrorExit("Error backups must be encrypted", 100,
+        errors.New("one of --age-recipient or --transit-key must be set"))
+    }
+
+    ctx := context.Background()
+    vaultClient := getVaultClient(&ctx)
+
+    logger.LogInfo("Backing up kv mounts")
+    backup, secretErrors, err := app.CreateBackup(vaultClient, backupMounts)
+    if err != nil {
+      logger.LogErrorExit("Error backing up kv mounts", 250, err)
+    }
+
+    logger.LogInfo("Writing backup file", "file", backupFile)
+    err = app.WriteBackupFile(backupFile, backup, encryptOpts, vaultClient)
+    if err != nil {
+      logger.LogErrorExit("Error writing the backup file", 100, err)
+    }
+
+    logger.LogDebug("Outputing results")
+    machineReadableOutput.ExitCode = 0
+    if len(secretErrors) > 0 {
+      machineReadableOutput.ExitCode = 250
+    }
+    machineReadableOutput.BackupFile = backupFile
+    machineReadableOutput.Mounts = backup.Manifest.Mounts
+    machineReadableOutput.SecretCount = len(backup.Secrets)
+    machineReadableOutput.Errors = secretErrors
+    writeOutput(machineReadableOutput, func() {
+      app.BackupConsoleOutput(backup, secretErrors, backupFile)
+    }, machineReadableOutput.ExitCode)
+  },
+}
+
+func init() {
+  // Command specific cli options
+  backupCmd.PersistentFlags().StringVarP(&backupFile, "backup-file", "", "", "The backup archive to write")
+  backupCmd.PersistentFlags().StringSliceVarP(&backupMounts, "mount", "", nil, "(Optional) Only back up these kv mounts, defaults to every kv mount")
+  backupCmd.PersistentFlags().StringSliceVarP(&ageRecipients, "age-recipient", "", nil, "(Optional) Encrypt the backup to these age recipients")
+  backupCmd.PersistentFlags().StringVarP(&transitKey, "transit-key", "", "", "(Optional) Encrypt the backup with this vault transit key in the form mount/key")
+
+  // Required command cli options
+  backupCmd.MarkPersistentFlagRequired("backup-file")
+
+  // Add command
+  RootCmd.AddCommand(backupCmd)
+}
@@ -2,8 +2,8 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
 
 	"github.com/dgutierrez1287/vault-util/logger"
   "github.com/dgutierrez1287/vault-util/app"
@@ -14,90 +14,89 @@ import (
 
 var secretsFile string
 var kvVersion string
+
+// plan flags
+var planOnly bool
+var planOutFile string
+var applyPlanFile string
+var showValues bool
+
+// transaction flags
+var atomic bool
+
 var bulkLoadCmd = &cobra.Command {
   Use: "bulk-load",
   Short: "bulk creates/updates secrets from a json file to vault",
   Long: "bulk creates/updates secrets from a json file to vault",
   Run: func(cmd *cobra.Command, args []string) {
     var machineReadableOutput app.BulkActionOutput
-    var secretsAdded []string
-    var secretErrors []app.SecretActionError
-    var vaultInstance *app.VaultInstance
     var err error
 
     if !machineOutput {
       fmt.Println(util.TitleString)
     }
-    
-    // Get vault configuration from settings file
-    if vaultName != "" {
-      logger.LogInfo("Vault name passed, getting connection details from settings file")
-
-      logger.LogInfo("Getting the settings file path")
-      settingsFilePath, err := app.ConfigFilePath()
-      if err != nil {
-        logger.LogErrorExit("Error getting settings file path", 200, err)
-      }
 
-      vaultInstance, err = app.GetVaultConfigFromSettings(vaultName, settingsFilePath)
-      if err != nil {
-        logger.LogErrorExit("Error getting the vault config from settings", 200, err)
-      }
-    } else {
-      logger.LogInfo("No Vault name is passed getting connection details from command line")
+    if secretsFile == "" && applyPlanFile == "" {
+      logger.LogErrorExit("Error a secrets file or plan file is required", 100,
+        errors.New("one of --secrets-file or --apply-plan must be set"))
+    }
 
-      vaultInstance, err = app.NewVault(vaultUrl, token, skipTlsVerify, 
-        caCertFile, caKeyFile)
-      if err != nil {
-        logger.LogErrorExit("Error creating the vault instance", 150, err)
-      }
+    if atomic && resumeCheckpoint != "" {
+      logger.LogErrorExit("Error an atomic run cannot be resumed", 100,
+        errors.New("--atomic and --resume cannot be used together"))
     }
 
     ctx := context.Background()
+    vaultClient := getVaultClient(&ctx)
 
-    logger.LogInfo("Getting vault client")
-    vaultClient, err := app.NewClient(*vaultInstance, &ctx)
-    if err != nil {
-      logger.LogErrorExit("Error getting vault client", 250, err)
+    if applyPlanFile != "" {
+      applySecretPlan(vaultClient)
     }
 
     logger.LogInfo("Reading secrets from json file", "file", secretsFile)
     secrets, err := app.ReadSecretsFromJson(secretsFile, vaultClient, ctx)
 
     if err != nil {
-      logger.LogErrorExit("Error reading secrets from json file", 250, err)
+      exitOnSecretsFileError(err)
     }
 
-    logger.LogInfo("Creating or updating secrets")
-    for name, secret := range secrets.Secrets {
-      logger.LogDebug("writing secret secret", "name", name)
-      err = secret.WriteSecret(vaultClient)
-
-      if err != nil {
-        logger.LogError("Error writing secret", "error", err)
-        secretErrors = append(secretErrors, app.SecretActionError{
-          VaultKey: secret.VaultKey,
-          Error: err,
-        })
-      } else {
-        logger.LogInfo("Secret created/updated")
-        secretsAdded = append(secretsAdded, name)
-      }
+    if planOnly || planOutFile != "" {
+      planSecrets(secrets, vaultClient)
     }
 
+    if atomic {
+      writeSecretsAtomic(secrets, vaultClient)
+    }
+
+    inputHash, err := app.HashFile(secretsFile)
+    if err != nil {
+      logger.LogErrorExit("Error hashing the secrets file", 100, err)
+    }
+    checkpoint := openCheckpoint(app.CheckpointOpBulkLoad, inputHash)
+    var secretsSkipped []string
+    if checkpoint != nil {
+      secretsSkipped = checkpoint.CompletedKeys()
+    }
+
+    logger.LogInfo("Creating or updating secrets")
+    secretsAdded, secretErrors := secrets.WriteSecretsWithCheckpoint(vaultClient, checkpoint)
+    keptCheckpoint := finishCheckpoint(checkpoint, len(secretErrors) > 0)
+
     logger.LogDebug("Outputing results")
-    if machineOutput {
-      machineReadableOutput.ExitCode = 0
-      machineReadableOutput.SecretsAdded = secretsAdded
-      machineReadableOutput.Errors = secretErrors
-
-      output, eCode := machineReadableOutput.GetOutputJson()
-      fmt.Println(output)
-      os.Exit(eCode)
-    } else {
+    machineReadableOutput.ExitCode = 0
+    machineReadableOutput.SecretsAdded = secretsAdded
+    machineReadableOutput.SecretsSkipped = secretsSkipped
+    machineReadableOutput.Errors = secretErrors
+    machineReadableOutput.CheckpointFile = keptCheckpoint
+    writeOutput(machineReadableOutput, func() {
       app.BulkActionConsoleOutput(secretsAdded, secretErrors, "added")
-      os.Exit(0)
-    }
+      if len(secretsSkipped) > 0 {
+        fmt.Printf("\n%d secrets already written in an earlier run\n", len(secretsSkipped))
+      }
+      if keptCheckpoint != "" {
+        app.CheckpointConsoleOutput(keptCheckpoint)
+      }
+    }, 0)
   },
 }
 
@@ -107,7 +106,136 @@ func init() {
 
   // secrets file
   bulkLoadCmd.PersistentFlags().StringVarP(&secretsFile, "secrets-file", "", "", "The json file that contains the secrets to be loaded/updated")
-  bulkLoadCmd.MarkFlagRequired("secrets-file")
+
+  // plan options
+  bulkLoadCmd.PersistentFlags().BoolVarP(&planOnly, "plan", "", false, "Show what would change without writing any secrets")
+  bulkLoadCmd.PersistentFlags().StringVarP(&planOutFile, "plan-out", "", "", "(Optional) Write the plan document to a file so it can be applied later, the file has the plaintext secret values")
+  bulkLoadCmd.PersistentFlags().StringVarP(&applyPlanFile, "apply-plan", "", "", "(Optional) Apply a plan document written with --plan-out")
+  bulkLoadCmd.PersistentFlags().BoolVarP(&showValues, "show-values", "", false, "(Optional) Show secret values in the plan instead of masking them")
+
+  // transaction options
+  bulkLoadCmd.PersistentFlags().BoolVarP(&atomic, "atomic", "", false, "(Optional) Roll back every secret that was written if any write fails")
+
+  // checkpoint options
+  addCheckpointFlags(bulkLoadCmd)
+}
+
+/*
+This will write the secrets as one unit, if any write fails
+the secrets are rolled back to how they were before
+*/
+func writeSecretsAtomic(secrets app.VaultSecrets, vaultClient *app.VaultClient) {
+  var machineReadableOutput app.BulkActionOutput
+
+  logger.LogInfo("Creating or updating secrets atomically")
+  secretsAdded, secretErrors, rollback, err := secrets.WriteSecretsAtomic(vaultClient)
+  if err != nil {
+    logger.LogErrorExit("Error taking snapshot of secrets, nothing was written", 250, err)
+  }
+
+  logger.LogDebug("Outputing results")
+  machineReadableOutput.ExitCode = 0
+  machineReadableOutput.SecretsAdded = secretsAdded
+  machineReadableOutput.Errors = secretErrors
+  if rollback != nil {
+    machineReadableOutput.ExitCode = 250
+    machineReadableOutput.RolledBack = true
+    machineReadableOutput.SecretsRestored = rollback.Restored
+    machineReadableOutput.SecretsDeleted = rollback.Deleted
+    machineReadableOutput.RollbackConflicts = rollback.Conflicts
+    machineReadableOutput.RollbackErrors = rollback.Errors
+  }
+
+  writeOutput(machineReadableOutput, func() {
+    app.BulkActionConsoleOutput(secretsAdded, secretErrors, "added")
+    if rollback != nil {
+      app.RollbackConsoleOutput(*rollback)
+    }
+  }, machineReadableOutput.ExitCode)
+}
+
+/*
+This will build the plan for the secrets, output it and
+optionally write the plan document, no secrets are written
+*/
+func planSecrets(secrets app.VaultSecrets, vaultClient *app.VaultClient) {
+  logger.LogInfo("Building plan")
+  plan, err := app.BuildSecretPlan(secrets, secretsFile, vaultClient)
+  if err != nil {
+    logger.LogErrorExit("Error building the plan", 250, err)
+  }
+  outputPlan(plan)
+}
+
+/*
+This will output a plan and write the plan document
+when --plan-out is set
+*/
+func outputPlan(plan app.SecretPlan) {
+  var machineReadableOutput app.PlanOutput
+
+  if planOutFile != "" {
+    logger.LogInfo("Writing plan file", "file", planOutFile)
+    err := app.WritePlanFile(planOutFile, plan)
+    if err != nil {
+      logger.LogErrorExit("Error writing the plan file", 100, err)
+    }
+  }
+
+  logger.LogDebug("Outputing plan")
+  machineReadableOutput.ExitCode = 0
+  machineReadableOutput.PlanFile = planOutFile
+  machineReadableOutput.Plan = plan.Redacted()
+  writeOutput(machineReadableOutput, func() {
+    app.PlanConsoleOutput(plan, showValues)
+  }, 0)
+}
+
+/*
+This will apply a plan document that was written with
+--plan-out, unchanged secrets are skipped
+*/
+func applySecretPlan(vaultClient *app.VaultClient) {
+  logger.LogInfo("Reading plan file", "file", applyPlanFile)
+  plan, err := app.ReadPlanFile(applyPlanFile)
+  if err != nil {
+    logger.LogErrorExit("Error reading the plan file", 100, err)
+  }
+
+  inputHash, err := app.HashFile(applyPlanFile)
+  if err != nil {
+    logger.LogErrorExit("Error hashing the plan file", 100, err)
+  }
+  checkpoint := openCheckpoint(app.CheckpointOpBulkLoad, inputHash)
+
+  logger.LogInfo("Applying plan")
+  result := plan.ApplyWithCheckpoint(vaultClient, checkpoint)
+  keptCheckpoint := finishCheckpoint(checkpoint, len(result.Errors) > 0)
+
+  logger.LogDebug("Outputing results")
+  outputPlanApplyResult(result, keptCheckpoint)
+}
+
+/*
+This will output the results of applying a plan
+*/
+func outputPlanApplyResult(result app.PlanApplyResult, keptCheckpoint string) {
+  var machineReadableOutput app.BulkActionOutput
+
+  machineReadableOutput.ExitCode = 0
+  machineReadableOutput.SecretsAdded = result.Applied
+  machineReadableOutput.SecretsRemoved = result.Deleted
+  machineReadableOutput.SecretsUnchanged = result.Unchanged
+  machineReadableOutput.SecretsExisting = result.Existing
+  machineReadableOutput.SecretsSkipped = result.Skipped
+  machineReadableOutput.Errors = result.Errors
+  machineReadableOutput.CheckpointFile = keptCheckpoint
+  writeOutput(machineReadableOutput, func() {
+    app.PlanApplyConsoleOutput(result)
+    if keptCheckpoint != "" {
+      app.CheckpointConsoleOutput(keptCheckpoint)
+    }
+  }, 0)
 }
 
 
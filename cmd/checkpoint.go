@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"github.com/dgutierrez1287/vault-util/app"
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/spf13/cobra"
+)
+
+// checkpoint flags
+var checkpointFile string
+var resumeCheckpoint string
+
+/*
+This will add the checkpoint flags to a bulk command
+*/
+func addCheckpointFlags(cmd *cobra.Command) {
+  cmd.PersistentFlags().StringVarP(&checkpointFile, "checkpoint", "", "", "(Optional) The checkpoint file to record finished secrets in so a run that stops can be resumed, no checkpoint is kept without it")
+  cmd.PersistentFlags().StringVarP(&resumeCheckpoint, "resume", "", "", "(Optional) Resume a run that stopped from its checkpoint file")
+}
+
+/*
+This will read the checkpoint being resumed and check it
+matches the input, or create a new checkpoint when a
+checkpoint file is set. Without either flag there is no
+checkpoint and nil is returned
+*/
+func openCheckpoint(operation string, inputHash string) *app.Checkpoint {
+  if resumeCheckpoint != "" {
+    logger.LogInfo("Reading checkpoint", "file", resumeCheckpoint)
+    checkpoint, err := app.ReadCheckpoint(resumeCheckpoint)
+    if err != nil {
+      logger.LogErrorExit("Error reading the checkpoint file", 100, err)
+    }
+
+    err = checkpoint.Verify(operation, inputHash)
+    if err != nil {
+      logger.LogErrorExit("Error the checkpoint does not match this run", 100, err)
+    }
+    return checkpoint
+  }
+
+  if checkpointFile == "" {
+    logger.LogDebug("No checkpoint file set, not keeping a checkpoint")
+    return nil
+  }
+
+  logger.LogInfo("Creating checkpoint", "file", checkpointFile)
+  checkpoint, err := app.NewCheckpoint(checkpointFile, operation, inputHash)
+  if err != nil {
+    logger.LogErrorExit("Error creating the checkpoint file", 100, err)
+  }
+  return checkpoint
+}
+
+/*
+This will save the checkpoint when the run had errors so it
+can be resumed, otherwise the checkpoint file is removed. The
+path is returned when the checkpoint is kept
+*/
+func finishCheckpoint(checkpoint *app.Checkpoint, failed bool) string {
+  if checkpoint == nil {
+    return ""
+  }
+
+  if !failed {
+    logger.LogDebug("Run finished, removing checkpoint", "file", checkpoint.Path())
+    err := checkpoint.Remove()
+    if err != nil {
+      logger.LogErrorExit("Error removing the checkpoint file", 100, err)
+    }
+    return ""
+  }
+
+  logger.LogInfo("Run did not finish, keeping checkpoint", "file", checkpoint.Path())
+  err := checkpoint.Save()
+  if err != nil {
+    logger.LogErrorExit("Error saving the checkpoint file", 100, err)
+  }
+  return checkpoint.Path()
+}
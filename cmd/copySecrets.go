@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dgutierrez1287/vault-util/app"
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/dgutierrez1287/vault-util/util"
+	"github.com/spf13/cobra"
+)
+
+// copy flags
+var fromVault string
+var toVault string
+var fromPath string
+var toPath string
+var includePatterns []string
+var excludePatterns []string
+var prune bool
+var destroySecrets bool
+var applyChanges bool
+
+var copySecretsCmd = &cobra.Command{
+  Use: "copy-secrets",
+  Short: "Copies secrets between two configured vaults",
+  Long: "Copies a secret or a folder of secrets between two vaults in the settings file, runs as a dry run unless --apply is passed",
+  Run: func(cmd *cobra.Command, args []string) {
+    var machineReadableOutput app.PlanOutput
+
+    if !machineOutput {
+      fmt.Println(util.TitleString)
+    }
+
+    ctx := context.Background()
+    fromClient := getNamedVaultClient(fromVault, &ctx)
+    toClient := getNamedVaultClient(toVault, &ctx)
+
+    opts := app.CopyOptions{
+      FromPath: fromPath,
+      ToPath: toPath,
+      Include: includePatterns,
+      Exclude: excludePatterns,
+      Prune: prune,
+      MaxDeletions: maxDeletions,
+      Destroy: destroySecrets,
+    }
+
+    logger.LogInfo("Building copy plan", "from", fromPath, "to", toPath)
+    plan, err := app.BuildCopyPlan(fromClient, toClient, opts)
+    if err != nil {
+      logger.LogErrorExit("Error building the copy plan", 250, err)
+    }
+
+    if applyChanges {
+      inputHash := app.HashInputs(fromVault, toVault, fromPath, toPath,
+        strings.Join(includePatterns, ","), strings.Join(excludePatterns, ","),
+        strconv.FormatBool(prune), strconv.FormatBool(destroySecrets))
+      checkpoint := openCheckpoint(app.CheckpointOpCopy, inputHash)
+
+      logger.LogInfo("Applying copy plan")
+      result := plan.ApplyWithCheckpoint(toClient, checkpoint)
+      keptCheckpoint := finishCheckpoint(checkpoint, len(result.Errors) > 0)
+
+      logger.LogDebug("Outputing results")
+      outputPlanApplyResult(result, keptCheckpoint)
+    }
+
+    logger.LogDebug("Outputing plan")
+    machineReadableOutput.ExitCode = 0
+    machineReadableOutput.Plan = plan.Redacted()
+    writeOutput(machineReadableOutput, func() {
+      app.PlanConsoleOutput(plan, showValues)
+      fmt.Println("Dry run, use --apply to make these changes")
+    }, 0)
+  },
+}
+
+func init() {
+  // Command specific cli options
+  copySecretsCmd.PersistentFlags().StringVarP(&fromVault, "from-vault", "", "", "The name of the vault in the settings file to copy from")
+  copySecretsCmd.PersistentFlags().StringVarP(&toVault, "to-vault", "", "", "The name of the vault in the settings file to copy to")
+  copySecretsCmd.PersistentFlags().StringVarP(&fromPath, "from-path", "", "", "The secret or folder to copy from, including the mount")
+  copySecretsCmd.PersistentFlags().StringVarP(&toPath, "to-path", "", "", "The secret or folder to copy to, including the mount")
+  copySecretsCmd.PersistentFlags().StringSliceVarP(&includePatterns, "include", "", nil, "(Optional) Glob patterns of relative keys to include")
+  copySecretsCmd.PersistentFlags().StringSliceVarP(&excludePatterns, "exclude", "", nil, "(Optional) Glob patterns of relative keys to exclude")
+  copySecretsCmd.PersistentFlags().BoolVarP(&prune, "prune", "", false, "(Optional) Delete destination secrets that are missing at the source")
+  copySecretsCmd.PersistentFlags().BoolVarP(&destroySecrets, "destroy", "", false, "(Optional) With --prune, destroy every version and the metadata of pruned secrets instead of deleting the current version")
+  copySecretsCmd.PersistentFlags().IntVarP(&maxDeletions, "max-deletions", "", 10, "(Optional) The max number of secrets --prune can delete, -1 is unlimited")
+  copySecretsCmd.PersistentFlags().BoolVarP(&applyChanges, "apply", "", false, "(Optional) Make the changes, without this it is a dry run")
+  copySecretsCmd.PersistentFlags().BoolVarP(&showValues, "show-values", "", false, "(Optional) Show secret values in the plan instead of masking them")
+
+  // checkpoint options
+  addCheckpointFlags(copySecretsCmd)
+
+  // Required command cli options
+  copySecretsCmd.MarkPersistentFlagRequired("from-vault")
+  copySecretsCmd.MarkPersistentFlagRequired("to-vault")
+  copySecretsCmd.MarkPersistentFlagRequired("from-path")
+  copySecretsCmd.MarkPersistentFlagRequired("to-path")
+
+  // Add command
+  RootCmd.AddCommand(copySecretsCmd)
+}
@@ -56,16 +56,11 @@ var deleteVaultCmd = &cobra.Command{
       logger.LogErrorExit("Error updating config", 100, err)
     }
 
-    if ! machineOutput {
+    machineReadableOutput.ExitCode = 0
+    machineReadableOutput.Message = fmt.Sprintf("%s vault successfully deleted", vaultName)
+    writeOutput(machineReadableOutput, func() {
       logger.LogInfo("Vault instance successfully deleted", "name", vaultName)
-      os.Exit(0)
-    } else {
-      machineReadableOutput.ExitCode = 0
-      machineReadableOutput.Message = fmt.Sprintf("%s vault successfully deleted", vaultName)
-      output, eCode := machineReadableOutput.GetOutputJson()
-      fmt.Println(output)
-      os.Exit(eCode)
-    }
+    }, 0)
   },
 }
 
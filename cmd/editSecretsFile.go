@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/dgutierrez1287/vault-util/app"
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/dgutierrez1287/vault-util/util"
+	"github.com/spf13/cobra"
+)
+
+var editSecretsFileCmd = &cobra.Command{
+  Use: "edit-secrets-file",
+  Short: "Edits an encrypted secrets file",
+  Long: "Decrypts a secrets file to a temp file, opens it in $EDITOR and encrypts it again when the editor exits",
+  Run: func(cmd *cobra.Command, args []string) {
+    var machineReadableOutput app.AddRemoveOutput
+    var vaultClient *app.VaultClient
+
+    if !machineOutput {
+      fmt.Println(util.TitleString)
+    }
+
+    logger.LogInfo("Reading secrets file", "file", secretsFile)
+    secrets, err := app.LoadSecretsFile(secretsFile)
+    if err != nil {
+      logger.LogErrorExit("Error reading secrets file", 100, err)
+    }
+
+    if secrets.Encryption == nil {
+      logger.LogErrorExit("Error secrets file is not encrypted", 100,
+        errors.New("secrets file has no encryption metadata"))
+    }
+    encryptOpts := secrets.Encryption.Options()
+
+    if encryptOpts.TransitKey != "" {
+      ctx := context.Background()
+      vaultClient = getVaultClient(&ctx)
+    }
+
+    logger.LogInfo("Decrypting secrets file")
+    err = secrets.Decrypt(vaultClient)
+    if err != nil {
+      logger.LogErrorExit("Error decrypting secrets file", 250, err)
+    }
+
+    tmpFile, err := os.CreateTemp("", "vault-util-edit-*.json")
+    if err != nil {
+      logger.LogErrorExit("Error creating temp file", 100, err)
+    }
+    tmpFile.Close()
+
+    err = app.WriteSecretsFile(tmpFile.Name(), secrets)
+    if err != nil {
+      os.Remove(tmpFile.Name())
+      logger.LogErrorExit("Error writing temp file", 100, err)
+    }
+
+    logger.LogInfo("Opening editor")
+    err = runEditor(tmpFile.Name())
+    if err != nil {
+      os.Remove(tmpFile.Name())
+      logger.LogErrorExit("Error running editor", 100, err)
+    }
+
+    edited, err := app.LoadSecretsFile(tmpFile.Name())
+    if err != nil {
+      os.Remove(tmpFile.Name())
+      logger.LogErrorExit("Error reading edited secrets file", 100, err)
+    }
+
+    logger.LogInfo("Encrypting secrets file")
+    err = edited.Encrypt(encryptOpts, vaultClient)
+    if err != nil {
+      os.Remove(tmpFile.Name())
+      logger.LogErrorExit("Error encrypting secrets file", 250, err)
+    }
+
+    err = app.WriteSecretsFile(secretsFile, edited)
+    if err != nil {
+      os.Remove(tmpFile.Name())
+      logger.LogErrorExit("Error writing secrets file", 100, err)
+    }
+    os.Remove(tmpFile.Name())
+
+    machineReadableOutput.ExitCode = 0
+    machineReadableOutput.Message = fmt.Sprintf("%s successfully updated", secretsFile)
+    writeOutput(machineReadableOutput, func() {
+      logger.LogInfo("Secrets file successfully updated", "file", secretsFile)
+    }, 0)
+  },
+}
+
+/*
+This will open a file in the editor from $EDITOR
+and wait for it to exit
+*/
+func runEditor(filePath string) error {
+  editor := strings.Fields(os.Getenv("EDITOR"))
+  if len(editor) == 0 {
+    editor = []string{"vi"}
+  }
+
+  editorCmd := exec.Command(editor[0], append(editor[1:], filePath)...)
+  editorCmd.Stdin = os.Stdin
+  editorCmd.Stdout = os.Stdout
+  editorCmd.Stderr = os.Stderr
+  return editorCmd.Run()
+}
+
+func init() {
+  // Command specific cli options
+  editSecretsFileCmd.PersistentFlags().StringVarP(&secretsFile, "secrets-file", "", "", "The encrypted secrets file to edit")
+
+  // Required command cli options
+  editSecretsFileCmd.MarkPersistentFlagRequired("secrets-file")
+
+  // Add command
+  RootCmd.AddCommand(editSecretsFileCmd)
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/dgutierrez1287/vault-util/app"
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/spf13/cobra"
+)
+
+// exec flags
+var execSecretKeys []string
+var execRestart bool
+var execPollInterval time.Duration
+
+var execCmd = &cobra.Command{
+  Use: "exec -- command [args...]",
+  Short: "Runs a command with secrets as environment variables",
+  Long: "Runs a command with the fields of kv secrets as environment variables. Nested fields are named by their path with dots made underscores, --env-map names a field explicitly in the form field=NAME or secret/key:field=NAME. Signals are passed on to the command and vault-util exits with the exit code of the command. With --restart the command is restarted with the new values when a kv v2 secret changes",
+  Run: func(cmd *cobra.Command, args []string) {
+    if len(args) == 0 {
+      logger.LogErrorExit("Error no command to run", 100, errors.New("pass the command to run after --"))
+    }
+
+    rules, err := mappingRules()
+    if err != nil {
+      logger.LogErrorExit("Error parsing env mappings", 100, err)
+    }
+
+    ctx := context.Background()
+    vaultClient := getVaultClient(&ctx)
+
+    opts := app.ExecOptions{
+      SecretKeys: execSecretKeys,
+      Rules: rules,
+      Command: args,
+      Restart: execRestart,
+      PollInterval: execPollInterval,
+    }
+
+    logger.LogInfo("Running command with secrets", "command", args[0])
+    exitCode, err := app.RunExec(vaultClient, opts)
+    if err != nil {
+      logger.LogErrorExit("Error running command", 250, err)
+    }
+    os.Exit(exitCode)
+  },
+}
+
+func init() {
+  // Command specific cli options
+  execCmd.PersistentFlags().StringSliceVarP(&execSecretKeys, "secret-key", "", nil, "The secret keys to read, can be passed more than once")
+  execCmd.PersistentFlags().BoolVarP(&execRestart, "restart", "", false, "(Optional) Restart the command when a kv v2 secret changes")
+  execCmd.PersistentFlags().DurationVarP(&execPollInterval, "poll-interval", "", 30*time.Second, "(Optional) How often to check for secret changes with --restart")
+
+  // field mapping options
+  addMappingFlags(execCmd)
+
+  // Add command
+  RootCmd.AddCommand(execCmd)
+}
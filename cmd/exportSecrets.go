@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/dgutierrez1287/vault-util/app"
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/dgutierrez1287/vault-util/util"
+	"github.com/spf13/cobra"
+)
+
+// export flags
+var secretPrefix string
+var outputFile string
+var ageRecipients []string
+var transitKey string
+
+var exportSecretsCmd = &cobra.Command{
+  Use: "export-secrets",
+  Short: "Exports secrets from a mount to a secrets file",
+  Long: "Exports secrets from a mount to a secrets file that can be loaded with bulk-load, the values can be encrypted with age recipients or a vault transit key",
+  Run: func(cmd *cobra.Command, args []string) {
+    var machineReadableOutput app.ExportOutput
+
+    if !machineOutput {
+      fmt.Println(util.TitleString)
+    }
+
+    requireMountName()
+
+    ctx := context.Background()
+    vaultClient := getVaultClient(&ctx)
+
+    folderKey := mountName
+    if secretPrefix != "" {
+      folderKey = mountName + "/" + secretPrefix
+    }
+
+    checkpoint := openCheckpoint(app.CheckpointOpExport, exportInputHash(folderKey))
+    previous := readPreviousExport(checkpoint, vaultClient)
+
+    logger.LogInfo("Exporting secrets", "folder", folderKey)
+    secrets, secretErrors, err := app.ExportSecretsWithCheckpoint(vaultClient, folderKey, checkpoint)
+    if err != nil {
+      logger.LogErrorExit("Error exporting secrets", 250, err)
+    }
+
+    for name, secret := range previous.Secrets {
+      if checkpoint != nil && checkpoint.IsDone(name) {
+        secrets.Secrets[name] = secret
+      }
+    }
+
+    encryptOpts := app.EncryptionOptions{
+      AgeRecipients: ageRecipients,
+      TransitKey: transitKey,
+    }
+
+    if encryptOpts.Enabled() {
+      logger.LogInfo("Encrypting secret values")
+      err = secrets.Encrypt(encryptOpts, vaultClient)
+      if err != nil {
+        logger.LogErrorExit("Error encrypting secrets", 250, err)
+      }
+    }
+
+    logger.LogInfo("Writing secrets file", "file", outputFile)
+    err = app.WriteSecretsFile(outputFile, secrets)
+    if err != nil {
+      logger.LogErrorExit("Error writing secrets file", 100, err)
+    }
+
+    if checkpoint != nil {
+      err = checkpoint.MarkDone(secrets.SecretNames()...)
+      if err != nil {
+        logger.LogErrorExit("Error saving the checkpoint file", 100, err)
+      }
+      checkpoint.SetInputHash(exportInputHash(folderKey))
+    }
+    keptCheckpoint := finishCheckpoint(checkpoint, len(secretErrors) > 0)
+
+    logger.LogDebug("Outputing results")
+    machineReadableOutput.ExitCode = 0
+    machineReadableOutput.OutputFile = outputFile
+    machineReadableOutput.Encrypted = encryptOpts.Enabled()
+    machineReadableOutput.SecretsExported = secrets.SecretNames()
+    machineReadableOutput.Errors = secretErrors
+    machineReadableOutput.CheckpointFile = keptCheckpoint
+    writeOutput(machineReadableOutput, func() {
+      app.ExportConsoleOutput(secrets.SecretNames(), secretErrors, outputFile,
+        encryptOpts.Enabled())
+      if keptCheckpoint != "" {
+        app.CheckpointConsoleOutput(keptCheckpoint)
+      }
+    }, 0)
+  },
+}
+
+func init() {
+  // Command specific cli options
+  exportSecretsCmd.PersistentFlags().StringVarP(&secretPrefix, "prefix", "", "", "(Optional) Only export secrets under this folder in the mount")
+  exportSecretsCmd.PersistentFlags().StringVarP(&outputFile, "output-file", "", "", "The secrets file to write")
+  exportSecretsCmd.PersistentFlags().StringSliceVarP(&ageRecipients, "age-recipient", "", nil, "(Optional) Encrypt values to these age recipients")
+  exportSecretsCmd.PersistentFlags().StringVarP(&transitKey, "transit-key", "", "", "(Optional) Encrypt values with this vault transit key in the form mount/key")
+
+  // checkpoint options
+  addCheckpointFlags(exportSecretsCmd)
+
+  // Required command cli options
+  exportSecretsCmd.MarkPersistentFlagRequired("output-file")
+
+  // Add command
+  RootCmd.AddCommand(exportSecretsCmd)
+}
+
+/*
+This will hash what an export is resumed from, the folder
+and the output file as it was last written
+*/
+func exportInputHash(folderKey string) string {
+  fileHash := ""
+  if _, err := os.Stat(outputFile); err == nil {
+    fileHash, err = app.HashFile(outputFile)
+    if err != nil {
+      logger.LogErrorExit("Error hashing the output file", 100, err)
+    }
+  }
+  return app.HashInputs(folderKey, fileHash)
+}
+
+/*
+This will read the secrets an earlier run already exported
+when resuming so they are kept in the output file
+*/
+func readPreviousExport(checkpoint *app.Checkpoint, vaultClient *app.VaultClient) app.VaultSecrets {
+  if checkpoint == nil || len(checkpoint.CompletedKeys()) == 0 {
+    return app.VaultSecrets{}
+  }
+
+  logger.LogInfo("Reading secrets exported in an earlier run", "file", outputFile)
+  previous, err := app.LoadSecretsFile(outputFile)
+  if err != nil {
+    logger.LogErrorExit("Error reading the output file to resume", 100, err)
+  }
+
+  err = previous.Decrypt(vaultClient)
+  if err != nil {
+    logger.LogErrorExit("Error decrypting the output file to resume", 250, err)
+  }
+  return previous
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dgutierrez1287/vault-util/app"
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/dgutierrez1287/vault-util/util"
+	"github.com/spf13/cobra"
+)
+
+// generate flags
+var generateKind string
+var generateSecretKeys []string
+var generateName string
+var generateNamespace string
+var secretStore string
+var secretStoreKind string
+var refreshInterval string
+var credentialsDir string
+var generateOutputFile string
+
+var generateCmd = &cobra.Command{
+  Use: "generate",
+  Short: "Generates deployment files from secrets",
+  Long: "Generates deployment files from the fields of kv secrets, a kubernetes Secret manifest, an external secrets operator ExternalSecret that reads the fields from vault, a docker --env-file or a systemd credentials dir. Every kind names the fields the same way, nested fields are named by their path and --env-map names a field explicitly. The file is printed unless --output-file is passed, for systemd credentials the LoadCredential lines for the unit are printed",
+  Run: func(cmd *cobra.Command, args []string) {
+    // the generated file is printed so nothing else can be
+    if !machineOutput && generateOutputFile != "" {
+      fmt.Println(util.TitleString)
+    }
+
+    rules, err := mappingRules()
+    if err != nil {
+      logger.LogErrorExit("Error parsing env mappings", 100, err)
+    }
+
+    ctx := context.Background()
+    vaultClient := getVaultClient(&ctx)
+
+    secrets, err := app.KvSecrets(vaultClient, generateSecretKeys)
+    if err != nil {
+      logger.LogErrorExit("Error getting secrets", 250, err)
+    }
+
+    logger.LogInfo("Reading secrets")
+    fields, err := app.ReadMappedFields(vaultClient, secrets, rules)
+    if err != nil {
+      logger.LogErrorExit("Error reading secrets", 250, err)
+    }
+
+    opts := app.GenerateOptions{
+      Name: generateName,
+      Namespace: generateNamespace,
+      SecretStore: secretStore,
+      SecretStoreKind: secretStoreKind,
+      RefreshInterval: refreshInterval,
+      CredentialsDir: credentialsDir,
+    }
+
+    logger.LogInfo("Generating", "kind", generateKind)
+    content, err := app.Generate(generateKind, fields, opts)
+    if err != nil {
+      logger.LogErrorExit("Error generating "+generateKind, 100, err)
+    }
+
+    if generateOutputFile == "" {
+      os.Stdout.Write(content)
+      os.Exit(0)
+    }
+
+    logger.LogInfo("Writing file", "file", generateOutputFile)
+    err = util.WriteFileAtomic(generateOutputFile, content, 0600)
+    if err != nil {
+      logger.LogErrorExit("Error writing generated file", 100, err)
+    }
+
+    var names []string
+    for _, field := range fields {
+      names = append(names, field.Name)
+    }
+
+    logger.LogDebug("Outputing results")
+    machineReadableOutput := app.GenerateOutput{
+      ExitCode: 0,
+      Kind: generateKind,
+      OutputFile: generateOutputFile,
+      Names: names,
+    }
+    writeOutput(machineReadableOutput, func() {
+      app.GenerateConsoleOutput(generateKind, generateOutputFile, names)
+    }, 0)
+  },
+}
+
+func init() {
+  // Command specific cli options
+  generateCmd.PersistentFlags().StringVarP(&generateKind, "kind", "", "", "What to generate, "+strings.Join(app.GenerateKinds(), ", "))
+  generateCmd.PersistentFlags().StringSliceVarP(&generateSecretKeys, "secret-key", "", nil, "The secret keys to read, can be passed more than once")
+  generateCmd.PersistentFlags().StringVarP(&generateName, "name", "", "", "(Optional) The name of the kubernetes secret, required for kubernetes kinds")
+  generateCmd.PersistentFlags().StringVarP(&generateNamespace, "namespace", "", "", "(Optional) The kubernetes namespace")
+  generateCmd.PersistentFlags().StringVarP(&secretStore, "secret-store", "", "", "(Optional) The secret store an external secret reads from")
+  generateCmd.PersistentFlags().StringVarP(&secretStoreKind, "secret-store-kind", "", "SecretStore", "(Optional) The kind of the secret store, SecretStore or ClusterSecretStore")
+  generateCmd.PersistentFlags().StringVarP(&refreshInterval, "refresh-interval", "", "1h", "(Optional) How often the external secret is refreshed")
+  generateCmd.PersistentFlags().StringVarP(&credentialsDir, "credentials-dir", "", "", "(Optional) The dir systemd credentials are written to")
+  generateCmd.PersistentFlags().StringVarP(&generateOutputFile, "output-file", "", "", "(Optional) Write the generated file here instead of printing it")
+
+  // field mapping options
+  addMappingFlags(generateCmd)
+
+  // Required command cli options
+  generateCmd.MarkPersistentFlagRequired("kind")
+
+  // Add command
+  RootCmd.AddCommand(generateCmd)
+}
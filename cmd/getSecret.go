@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 
@@ -11,16 +12,24 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// get secret flags
+var secretField string
+var fieldFormat string
+var noNewline bool
+var revealValues bool
+var revealFields []string
+var jsonView bool
+
 var getSecretCmd = &cobra.Command{
   Use: "get-secret",
   Short: "Gets the secret data for secret",
-  Long: "Gets the secret data for secret",
+  Long: "Gets the secret data for secret. With --field only the value of that field is printed, the field can be a dot path or a JSONPath into nested values. Exits 1 when the secret or the field does not exist. Values are masked in console output unless --reveal or --reveal-field is passed",
   Run: func(cmd *cobra.Command, args []string) {
     var machineReadableOutput app.GetSecretOutput
     var vaultInstance *app.VaultInstance
     var err error
 
-    if !machineOutput {
+    if !machineOutput && secretField == "" {
       fmt.Println(util.TitleString)
     }
 
@@ -70,16 +79,16 @@ var getSecretCmd = &cobra.Command{
 
     if !secretExists {
       logger.LogInfo("Secret does not exist")
-      if machineOutput {
-        machineReadableOutput.ExitCode = 0
-        machineReadableOutput.SecretExists = secretExists
-        output, eCode := machineReadableOutput.GetOutputJson()
-        fmt.Println(output)
-        os.Exit(eCode)
-      }  else {
-        app.GetSecretConsoleOutput(secret, secretExists)
-        os.Exit(0)
+      if secretField != "" {
+        logger.LogErrorExit("Error secret does not exist", 1,
+          fmt.Errorf("secret %s does not exist", secretKey))
       }
+
+      machineReadableOutput.ExitCode = 0
+      machineReadableOutput.SecretExists = secretExists
+      writeOutput(machineReadableOutput, func() {
+        app.GetSecretConsoleOutput(secret, secretExists, revealOptions(), jsonView)
+      }, 0)
     }
 
     logger.LogInfo("Reading the secret")
@@ -88,19 +97,18 @@ var getSecretCmd = &cobra.Command{
       logger.LogErrorExit("Error reading vault secret", 250, err)
     }
 
-    logger.LogDebug("Outputing results")
-    if machineOutput {
-      machineReadableOutput.ExitCode = 0
-      machineReadableOutput.SecretExists = secretExists
-      machineReadableOutput.VaultKey = secret.NormalizedSecretPath
-      machineReadableOutput.Data = secret.SecretData
-      output, ecode := machineReadableOutput.GetOutputJson()
-      fmt.Println(output)
-      os.Exit(ecode)
+    if secretField != "" {
+      printSecretField(secret.SecretData)
     }
 
-    app.GetSecretConsoleOutput(secret, secretExists)
-    os.Exit(0)
+    logger.LogDebug("Outputing results")
+    machineReadableOutput.ExitCode = 0
+    machineReadableOutput.SecretExists = secretExists
+    machineReadableOutput.VaultKey = secret.NormalizedSecretPath
+    machineReadableOutput.Data = secret.SecretData
+    writeOutput(machineReadableOutput, func() {
+      app.GetSecretConsoleOutput(secret, secretExists, revealOptions(), jsonView)
+    }, 0)
   },
 }
 
@@ -109,7 +117,50 @@ func init() {
   getSecretCmd.MarkFlagRequired("secret-key")
 
   // command specific cli options
+  getSecretCmd.PersistentFlags().StringVarP(&secretField, "field", "", "", "(Optional) Only print the value of this field, a dot path like db.password or a JSONPath like $.hosts[0]")
+  getSecretCmd.PersistentFlags().StringVarP(&fieldFormat, "format", "", app.FieldFormatRaw, "(Optional) The format of the field value, raw or base64")
+  getSecretCmd.PersistentFlags().BoolVarP(&noNewline, "no-newline", "n", false, "(Optional) Do not print a newline after the field value")
+  getSecretCmd.PersistentFlags().BoolVarP(&revealValues, "reveal", "", false, "(Optional) Show every secret value in console output instead of masking them")
+  getSecretCmd.PersistentFlags().StringSliceVarP(&revealFields, "reveal-field", "", nil, "(Optional) Show the values of these fields, a field shows every value under it")
+  getSecretCmd.PersistentFlags().BoolVarP(&jsonView, "json-view", "", false, "(Optional) Show the secret data as pretty json instead of a table")
 
   //Add command
   RootCmd.AddCommand(getSecretCmd)
 }
+
+/*
+This will print one field of the secret data and exit,
+a missing field exits with 1
+*/
+func printSecretField(data map[string]interface{}) {
+  value, err := app.ExtractField(data, secretField)
+  if err != nil {
+    var notFound *app.FieldNotFoundError
+    if errors.As(err, &notFound) {
+      logger.LogErrorExit("Error field does not exist", 1, err)
+    }
+    logger.LogErrorExit("Error reading the field", 100, err)
+  }
+
+  text, err := app.FormatFieldValue(value, fieldFormat)
+  if err != nil {
+    logger.LogErrorExit("Error formatting the field", 100, err)
+  }
+
+  if noNewline {
+    fmt.Print(text)
+  } else {
+    fmt.Println(text)
+  }
+  os.Exit(0)
+}
+
+/*
+This will get which values are shown in console output
+*/
+func revealOptions() app.RevealOptions {
+  return app.RevealOptions{
+    All: revealValues,
+    Fields: revealFields,
+  }
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dgutierrez1287/vault-util/app"
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/dgutierrez1287/vault-util/util"
+	"github.com/spf13/cobra"
+)
+
+// import flags
+var importFiles []string
+var importFormat string
+var importTargetKey string
+var createOnly bool
+
+var importCmd = &cobra.Command{
+  Use: "import",
+  Short: "Imports secrets from dotenv files and kubernetes Secret manifests",
+  Long: "Imports secrets from dotenv files and kubernetes Secret manifests, the base64 data in manifests is decoded. Every secret is written to the target key, which can have {name} and {namespace} in it for manifests with more than one Secret, dotenv files are named by their file name. The secrets are checked and written the same way as bulk-load. With --plan nothing is written and with --create-only secrets that already exist are not updated",
+  Run: func(cmd *cobra.Command, args []string) {
+    var machineReadableOutput app.BulkActionOutput
+
+    if !machineOutput {
+      fmt.Println(util.TitleString)
+    }
+
+    if len(importFiles) == 0 {
+      logger.LogErrorExit("Error no files to import", 100, errors.New("--file is required"))
+    }
+
+    var imported []app.ImportedSecret
+    for _, file := range importFiles {
+      logger.LogInfo("Reading secrets to import", "file", file)
+      secrets, err := app.ReadImportSource(file, importFormat)
+      if err != nil {
+        logger.LogErrorExit("Error reading secrets to import", 100, err)
+      }
+      imported = append(imported, secrets...)
+    }
+
+    secrets, err := app.ImportToVaultSecrets(imported, importTargetKey)
+    if err != nil {
+      logger.LogErrorExit("Error mapping secrets to target keys", 100, err)
+    }
+
+    ctx := context.Background()
+    vaultClient := getVaultClient(&ctx)
+
+    logger.LogDebug("Checking imported secrets against vault")
+    err = app.CheckImportSecrets(&secrets, vaultClient, importFiles)
+    if err != nil {
+      exitOnSecretsFileError(err)
+    }
+
+    if planOnly || planOutFile != "" || createOnly {
+      logger.LogInfo("Building plan")
+      plan, err := app.BuildSecretPlan(secrets, strings.Join(importFiles, ","), vaultClient)
+      if err != nil {
+        logger.LogErrorExit("Error building the plan", 250, err)
+      }
+      if createOnly {
+        plan = plan.CreateOnly()
+      }
+
+      if planOnly || planOutFile != "" {
+        outputPlan(plan)
+      }
+
+      logger.LogInfo("Creating secrets that do not exist")
+      outputPlanApplyResult(plan.Apply(vaultClient), "")
+    }
+
+    logger.LogInfo("Creating or updating secrets")
+    secretsAdded, secretErrors := secrets.WriteSecrets(vaultClient)
+
+    logger.LogDebug("Outputing results")
+    machineReadableOutput.ExitCode = 0
+    machineReadableOutput.SecretsAdded = secretsAdded
+    machineReadableOutput.Errors = secretErrors
+    writeOutput(machineReadableOutput, func() {
+      app.BulkActionConsoleOutput(secretsAdded, secretErrors, "added")
+    }, 0)
+  },
+}
+
+func init() {
+  // Command specific cli options
+  importCmd.PersistentFlags().StringSliceVarP(&importFiles, "file", "", nil, "The dotenv files or kubernetes Secret manifests to import, can be passed more than once")
+  importCmd.PersistentFlags().StringVarP(&importFormat, "format", "", app.ImportFormatAuto, "(Optional) The format of the files, "+strings.Join(app.ImportFormats(), ", ")+", auto uses kubernetes-secret for yaml and json files")
+  importCmd.PersistentFlags().StringVarP(&importTargetKey, "target-key", "", "", "The vault key to import to, {name} and {namespace} are replaced for every secret")
+  importCmd.PersistentFlags().BoolVarP(&createOnly, "create-only", "", false, "(Optional) Only create secrets that do not exist, existing secrets are not updated")
+
+  // plan options
+  importCmd.PersistentFlags().BoolVarP(&planOnly, "plan", "", false, "(Optional) Show what would change without writing any secrets")
+  importCmd.PersistentFlags().StringVarP(&planOutFile, "plan-out", "", "", "(Optional) Write the plan document to a file so it can be applied later with bulk-load --apply-plan, the file has the plaintext secret values")
+  importCmd.PersistentFlags().BoolVarP(&showValues, "show-values", "", false, "(Optional) Show secret values in the plan instead of masking them")
+
+  // Required command cli options
+  importCmd.MarkPersistentFlagRequired("target-key")
+
+  // Add command
+  RootCmd.AddCommand(importCmd)
+}
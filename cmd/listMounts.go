@@ -3,7 +3,6 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"os"
 
 	"github.com/dgutierrez1287/vault-util/app"
 	"github.com/dgutierrez1287/vault-util/logger"
@@ -66,28 +65,21 @@ var listMountsCmd = &cobra.Command{
     }
 
     logger.LogDebug("Outputing results")
-    if machineOutput {
-      machineReadableOutput.ExitCode = 0
-      if outputMountDetail {
-        mountMap := app.MountstoMap(mounts)
-        machineReadableOutput.MountsWithData = mountMap
-      } else {
-        mountNames := app.GetMountNames(mounts)
-        machineReadableOutput.MountNames = mountNames
-      }
-      output, eCode := machineReadableOutput.GetOutputJson()
-      fmt.Println(output)
-      os.Exit(eCode)
+    machineReadableOutput.ExitCode = 0
+    if outputMountDetail {
+      mountMap := app.MountstoMap(mounts)
+      machineReadableOutput.MountsWithData = mountMap
     } else {
+      mountNames := app.GetMountNames(mounts)
+      machineReadableOutput.MountNames = mountNames
+    }
+    writeOutput(machineReadableOutput, func() {
       if outputMountDetail {
         app.ListMountsWithDetailConsoleOutput(mounts)
-        os.Exit(0)
       } else {
         app.ListMountNamesConsoleOutput(mounts)
-        os.Exit(0)
       }
-    }
-
+    }, 0)
   },
 }
 
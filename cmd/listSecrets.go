@@ -2,8 +2,10 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/dgutierrez1287/vault-util/app"
 	"github.com/dgutierrez1287/vault-util/logger"
@@ -11,50 +13,42 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// list secrets flags
+var listPrefix string
+var listMaxDepth int
+var listGlob string
+var listTree bool
+var listStream bool
+var listDetail bool
+var listSort string
+var listReverse bool
+
 var listSecretsCmd = &cobra.Command{
   Use: "list-secrets",
   Short: "Lists secrets for a mount",
-  Long: "Lists secrets for mount",
+  Long: "Lists secrets for mount, the listing can start at a prefix, stop at a max depth and be filtered with a glob. With --tree the secrets are shown as a folder tree with counts. Folders the token cannot list are skipped and reported, with --stream secrets are printed as they are found so large mounts are not held in memory. With --detail the kv v2 metadata of every secret is shown in a table",
   Run: func(cmd *cobra.Command, args []string) {
     var machineReadableOutput app.SecretListOutput
-    var vaultInstance *app.VaultInstance
     var err error
     
     if !machineOutput {
       fmt.Println(util.TitleString)
     }
 
-    // Get vault configuration from settings file
-    if vaultName != "" {
-      logger.LogInfo("Vault name passed, getting connection details from settings file")
-
-      logger.LogInfo("Getting the settings file path")
-      settingsFilePath, err := app.ConfigFilePath()
-      if err != nil {
-        logger.LogErrorExit("Error getting settings file path", 200, err)
-      }
-
-      vaultInstance, err = app.GetVaultConfigFromSettings(vaultName, settingsFilePath)
-      if err != nil {
-        logger.LogErrorExit("Error getting the vault config from settings", 200, err)
-      }
-    } else {
-      logger.LogInfo("No Vault name is passed getting connection details from command line")
+    if listMaxDepth < 0 {
+      logger.LogErrorExit("Error max depth cannot be negative", 100,
+        fmt.Errorf("invalid max depth %d", listMaxDepth))
+    }
 
-      vaultInstance, err = app.NewVault(vaultUrl, token, skipTlsVerify, 
-        caCertFile, caKeyFile)
+    if listDetail {
+      err = app.ValidateSecretDetailSort(listSort)
       if err != nil {
-        logger.LogErrorExit("Error creating the vault instance", 150, err)
+        logger.LogErrorExit("Error invalid sort column", 100, err)
       }
     }
 
     ctx := context.Background()
-
-    logger.LogInfo("Getting vault client")
-    vaultClient, err := app.NewClient(*vaultInstance, &ctx)
-    if err != nil {
-      logger.LogErrorExit("Error getting vault client", 250, err)
-    }
+    vaultClient := getVaultClient(&ctx)
 
     logger.LogInfo("Getting secret mount")
     secretMount, err := app.NewSecretMount(mountName, "", "", "", vaultClient)
@@ -62,23 +56,44 @@ var listSecretsCmd = &cobra.Command{
       logger.LogErrorExit("Error getting secret mount details", 250, err)
     }
 
+    opts := app.ListOptions{
+      Prefix: listPrefix,
+      MaxDepth: listMaxDepth,
+      Glob: listGlob,
+    }
+
+    if listStream {
+      streamSecrets(vaultClient, secretMount, opts)
+    }
+
+    if listDetail {
+      listSecretDetails(vaultClient, secretMount, opts)
+    }
+
     logger.LogInfo("Getting secrets")
-    secrets, err := secretMount.ListSecrets(vaultClient)
+    secrets, result, err := secretMount.ListSecretsWithOptions(vaultClient, opts)
     if err != nil {
       logger.LogErrorExit("Error getting secrets for mount", 250, err)
     }
 
-    logger.LogDebug("Outputing results")
-    if machineOutput {
-      machineReadableOutput.ExitCode = 0
-      machineReadableOutput.Secrets = secrets
-      output, eCode := machineReadableOutput.GetOutputJson()
-      fmt.Println(output)
-      os.Exit(eCode)
-    } 
+    var tree *app.SecretFolder
+    if listTree {
+      tree = app.BuildSecretTree(secretMount.Mount+strings.Trim(listPrefix, "/"), secrets, result.Folders)
+    }
 
-    app.ListSecretsConsoleOutput(secrets, secretMount.Mount)
-    os.Exit(0)
+    logger.LogDebug("Outputing results")
+    machineReadableOutput.ExitCode = 0
+    machineReadableOutput.Secrets = secrets
+    machineReadableOutput.Folders = result.Folders
+    machineReadableOutput.Skipped = result.Skipped
+    machineReadableOutput.Tree = tree
+    writeOutput(machineReadableOutput, func() {
+      if listTree {
+        app.SecretTreeConsoleOutput(tree, result.Skipped)
+        return
+      }
+      app.ListSecretsConsoleOutput(secrets, result, secretMount.Mount)
+    }, 0)
   },
 }
 
@@ -87,7 +102,111 @@ func init() {
   listSecretsCmd.MarkFlagRequired("secret-mount")
 
   // Command specific cli options
+  listSecretsCmd.PersistentFlags().StringVarP(&listPrefix, "prefix", "", "", "(Optional) Only list secrets under this folder in the mount")
+  listSecretsCmd.PersistentFlags().IntVarP(&listMaxDepth, "max-depth", "", 0, "(Optional) How many folders deep to list, 1 is only the prefix folder, 0 is no limit")
+  listSecretsCmd.PersistentFlags().StringVarP(&listGlob, "glob", "", "", "(Optional) Only list secrets whose key in the mount matches this glob")
+  listSecretsCmd.PersistentFlags().BoolVarP(&listTree, "tree", "", false, "(Optional) Show the secrets as a folder tree with secret counts")
+  listSecretsCmd.PersistentFlags().BoolVarP(&listDetail, "detail", "", false, "(Optional) Show the kv v2 metadata of every secret in a table")
+  listSecretsCmd.PersistentFlags().StringVarP(&listSort, "sort", "", "key", "(Optional) The column to sort details by, key, version, versions, created, updated, state or owner")
+  listSecretsCmd.PersistentFlags().BoolVarP(&listReverse, "reverse", "", false, "(Optional) Sort details in reverse order")
+  listSecretsCmd.PersistentFlags().BoolVarP(&listStream, "stream", "", false, "(Optional) Print secrets as they are found instead of sorted, machine output is one json object per line")
 
   // Add command 
   RootCmd.AddCommand(listSecretsCmd)
 }
+
+/*
+This will print secrets as the walker finds them and exit, in
+machine output every secret is a json line and the last line
+has the folders that were not walked or could not be listed
+*/
+func streamSecrets(vaultClient *app.VaultClient, secretMount app.SecretMount,
+  opts app.ListOptions) {
+
+  if listTree {
+    logger.LogErrorExit("Error --stream cannot be used with --tree", 100,
+      errors.New("the tree needs every secret before it can be shown"))
+  }
+  if listDetail {
+    logger.LogErrorExit("Error --stream cannot be used with --detail", 100,
+      errors.New("details are sorted so every secret is needed before they can be shown"))
+  }
+  if machineOutput && outputFormat != "json" {
+    logger.LogErrorExit("Error --stream only supports table and json output", 100,
+      fmt.Errorf("cannot stream %s output", outputFormat))
+  }
+
+  if !machineOutput {
+    app.ListSecretsHeaderConsoleOutput(secretMount.Mount)
+  }
+
+  logger.LogInfo("Streaming secrets")
+  result, err := secretMount.WalkSecrets(vaultClient, opts, func(key string) error {
+    if machineOutput {
+      output, _ := app.StreamedSecretOutput{Secret: key}.GetOutputJson()
+      fmt.Println(output)
+      return nil
+    }
+    fmt.Println(key)
+    return nil
+  })
+  if err != nil {
+    logger.LogErrorExit("Error getting secrets for mount", 250, err)
+  }
+
+  if machineOutput {
+    summary := app.SecretListOutput{
+      ExitCode: 0,
+      Secrets: []string{},
+      Folders: result.Folders,
+      Skipped: result.Skipped,
+    }
+    output, eCode := summary.GetOutputJson()
+    fmt.Println(output)
+    os.Exit(eCode)
+  }
+
+  app.WalkResultConsoleOutput(result)
+  os.Exit(0)
+}
+
+/*
+This will list the secrets with their kv v2 metadata and exit
+*/
+func listSecretDetails(vaultClient *app.VaultClient, secretMount app.SecretMount,
+  opts app.ListOptions) {
+
+  var machineReadableOutput app.SecretDetailListOutput
+
+  if listTree {
+    logger.LogErrorExit("Error --detail cannot be used with --tree", 100,
+      errors.New("details are shown as a table"))
+  }
+
+  logger.LogInfo("Getting secrets")
+  secrets, result, err := secretMount.ListSecretsWithOptions(vaultClient, opts)
+  if err != nil {
+    logger.LogErrorExit("Error getting secrets for mount", 250, err)
+  }
+
+  logger.LogInfo("Getting secret metadata")
+  details, secretErrors, err := app.GetSecretDetails(vaultClient, secretMount, secrets)
+  if err != nil {
+    logger.LogErrorExit("Error getting secret metadata", 250, err)
+  }
+
+  err = app.SortSecretDetails(details, listSort, listReverse)
+  if err != nil {
+    logger.LogErrorExit("Error sorting secret details", 100, err)
+  }
+
+  logger.LogDebug("Outputing results")
+  machineReadableOutput.ExitCode = 0
+  machineReadableOutput.Mount = secretMount.Mount
+  machineReadableOutput.Secrets = details
+  machineReadableOutput.Skipped = result.Skipped
+  machineReadableOutput.Errors = secretErrors
+  writeOutput(machineReadableOutput, func() {
+    app.SecretDetailsConsoleOutput(details, result, secretErrors, secretMount.Mount)
+  }, 0)
+}
@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/dgutierrez1287/vault-util/logger"
   "github.com/dgutierrez1287/vault-util/app"
@@ -36,18 +35,12 @@ var listVaultsCmd = &cobra.Command{
     var appSettings app.Settings
     if !exists {
       logger.LogDebug("Settings file does not exist outputing")
-      if machineOutput {
-        machineReadableOutput.ExitCode = 0
-        machineReadableOutput.Vaults = []string{}
-        machineReadableOutput.Message = "no settings file, no vaults configured"
-
-        output, eCode := machineReadableOutput.GetOutputJson()
-        fmt.Println(output)
-        os.Exit(eCode)
-      }
-      
-      app.ListVaultsConsoleOutput(exists, []string{})
-      os.Exit(0)
+      machineReadableOutput.ExitCode = 0
+      machineReadableOutput.Vaults = []string{}
+      machineReadableOutput.Message = "no settings file, no vaults configured"
+      writeOutput(machineReadableOutput, func() {
+        app.ListVaultsConsoleOutput(exists, []string{})
+      }, 0)
     }
       
     logger.LogInfo("Getting the list of vaults from settings")
@@ -62,17 +55,11 @@ var listVaultsCmd = &cobra.Command{
       vaultNames = append(vaultNames, name)
     }
 
-    if machineOutput {
-      machineReadableOutput.ExitCode = 0
-      machineReadableOutput.Vaults = vaultNames
-
-      output, eCode := machineReadableOutput.GetOutputJson()
-      fmt.Println(output)
-      os.Exit(eCode)
-    }
-
-    app.ListVaultsConsoleOutput(true, vaultNames)
-    os.Exit(0)
+    machineReadableOutput.ExitCode = 0
+    machineReadableOutput.Vaults = vaultNames
+    writeOutput(machineReadableOutput, func() {
+      app.ListVaultsConsoleOutput(true, vaultNames)
+    }, 0)
   },
 }
 
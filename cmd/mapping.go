@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"github.com/dgutierrez1287/vault-util/app"
+	"github.com/spf13/cobra"
+)
+
+// field mapping flags
+var envPrefix string
+var envUppercase bool
+var envMappings []string
+var envOnlyMapped bool
+
+/*
+This will add the flags for naming secret fields to a
+command that turns fields into env variables or keys
+*/
+func addMappingFlags(cmd *cobra.Command) {
+  cmd.PersistentFlags().StringVarP(&envPrefix, "env-prefix", "", "", "(Optional) A prefix for the names made from fields")
+  cmd.PersistentFlags().BoolVarP(&envUppercase, "uppercase", "", true, "(Optional) Uppercase the names made from fields")
+  cmd.PersistentFlags().StringSliceVarP(&envMappings, "env-map", "", nil, "(Optional) Name a field in the form field=NAME or secret/key:field=NAME")
+  cmd.PersistentFlags().BoolVarP(&envOnlyMapped, "only-mapped", "", false, "(Optional) Only use the fields named with --env-map")
+}
+
+/*
+This will get the mapping rules from the flags
+*/
+func mappingRules() (app.MappingRules, error) {
+  mappings, err := app.ParseFieldMappings(envMappings)
+  if err != nil {
+    return app.MappingRules{}, err
+  }
+
+  return app.MappingRules{
+    Prefix: envPrefix,
+    Uppercase: envUppercase,
+    OnlyMapped: envOnlyMapped,
+    Mappings: mappings,
+  }, nil
+}
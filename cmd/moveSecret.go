@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgutierrez1287/vault-util/app"
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/dgutierrez1287/vault-util/util"
+	"github.com/spf13/cobra"
+)
+
+// move flags
+var moveAllVersions bool
+var moveMetadata bool
+var moveForce bool
+var journalFile string
+var resumeJournal string
+var reverseJournal string
+
+var moveSecretCmd = &cobra.Command{
+  Use: "move-secret",
+  Short: "Moves a secret to a new key",
+  Long: "Moves a secret to a new key by copying, verifying and then deleting the source, a journal is kept so the move can be resumed or reversed",
+  Run: func(cmd *cobra.Command, args []string) {
+    runMove(false)
+  },
+}
+
+/*
+This will run a move, resume a move from a journal
+or reverse a move from a journal
+*/
+func runMove(tree bool) {
+  var journal *app.MoveJournal
+  var err error
+
+  if !machineOutput {
+    fmt.Println(util.TitleString)
+  }
+
+  ctx := context.Background()
+  vaultClient := getVaultClient(&ctx)
+
+  switch {
+  case reverseJournal != "":
+    logger.LogInfo("Reading move journal", "file", reverseJournal)
+    journal, err = app.ReadMoveJournal(reverseJournal)
+    if err != nil {
+      logger.LogErrorExit("Error reading the move journal", 100, err)
+    }
+
+    logger.LogInfo("Reversing move")
+    result := journal.Reverse(vaultClient)
+    outputMoveResult(result, reverseJournal, true)
+
+  case resumeJournal != "":
+    logger.LogInfo("Reading move journal", "file", resumeJournal)
+    journal, err = app.ReadMoveJournal(resumeJournal)
+    if err != nil {
+      logger.LogErrorExit("Error reading the move journal", 100, err)
+    }
+    journalFile = resumeJournal
+
+  default:
+    if fromPath == "" || toPath == "" {
+      logger.LogErrorExit("Error from and to paths are required", 100,
+        errors.New("--from-path and --to-path must be set"))
+    }
+
+    if journalFile == "" {
+      journalFile = fmt.Sprintf("vault-util-move-%s.json", time.Now().UTC().Format("20060102T150405Z"))
+    }
+
+    opts := app.MoveOptions{
+      AllVersions: moveAllVersions,
+      Metadata: moveMetadata,
+      Force: moveForce,
+    }
+
+    logger.LogInfo("Creating move journal", "file", journalFile)
+    journal, err = app.NewMoveJournal(vaultClient, fromPath, toPath, tree, opts, journalFile)
+    if err != nil {
+      logger.LogErrorExit("Error creating the move journal", 250, err)
+    }
+  }
+
+  logger.LogInfo("Moving secrets", "from", journal.FromPath, "to", journal.ToPath)
+  result := journal.Run(vaultClient)
+  outputMoveResult(result, journalFile, false)
+}
+
+/*
+This will output the results of a move
+*/
+func outputMoveResult(result app.MoveResult, journal string, reversed bool) {
+  var machineReadableOutput app.MoveOutput
+
+  logger.LogDebug("Outputing results")
+  machineReadableOutput.ExitCode = 0
+  machineReadableOutput.JournalFile = journal
+  if reversed {
+    machineReadableOutput.SecretsReversed = result.Completed
+  } else {
+    machineReadableOutput.SecretsMoved = result.Completed
+  }
+  machineReadableOutput.Errors = result.Errors
+  writeOutput(machineReadableOutput, func() {
+    app.MoveConsoleOutput(result, journal, reversed)
+  }, 0)
+}
+
+/*
+This will add the flags shared by the move commands
+*/
+func addMoveFlags(cmd *cobra.Command) {
+  cmd.PersistentFlags().StringVarP(&fromPath, "from-path", "", "", "The key to move from, including the mount")
+  cmd.PersistentFlags().StringVarP(&toPath, "to-path", "", "", "The key to move to, including the mount")
+  cmd.PersistentFlags().BoolVarP(&moveAllVersions, "all-versions", "", false, "(Optional) Copy all kv v2 versions instead of only the current data")
+  cmd.PersistentFlags().BoolVarP(&moveMetadata, "metadata", "", false, "(Optional) Copy kv v2 custom metadata")
+  cmd.PersistentFlags().BoolVarP(&moveForce, "force", "", false, "(Optional) Move onto secrets that already exist, a reverse puts back their kv v2 version from before the move")
+  cmd.PersistentFlags().StringVarP(&journalFile, "journal", "", "", "(Optional) The journal file to write, defaults to a timestamped file in the current directory")
+  cmd.PersistentFlags().StringVarP(&resumeJournal, "resume", "", "", "(Optional) Resume an interrupted move from a journal file")
+  cmd.PersistentFlags().StringVarP(&reverseJournal, "reverse", "", "", "(Optional) Reverse a move from a journal file")
+}
+
+func init() {
+  // Command specific cli options
+  addMoveFlags(moveSecretCmd)
+
+  // Add command
+  RootCmd.AddCommand(moveSecretCmd)
+}
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var moveTreeCmd = &cobra.Command{
+  Use: "move-tree",
+  Short: "Moves a folder of secrets to a new path",
+  Long: "Moves every secret under a folder to a new path by copying, verifying and then deleting the source, a journal is kept so the move can be resumed or reversed",
+  Run: func(cmd *cobra.Command, args []string) {
+    runMove(true)
+  },
+}
+
+func init() {
+  // Command specific cli options
+  addMoveFlags(moveTreeCmd)
+
+  // Add command
+  RootCmd.AddCommand(moveTreeCmd)
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/dgutierrez1287/vault-util/app"
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/spf13/cobra"
+)
+
+// output format flags
+var outputFormat string
+var outputTemplate string
+
+// the formatter for the output format, set before a command runs
+var resultFormatter app.Formatter
+
+/*
+This will pick the output format, -m is the same as
+--output json. Every format but table is machine output
+so titles and info logs are not printed
+*/
+func setOutputFormat(cmd *cobra.Command) error {
+  if machineOutput && !cmd.Flags().Changed("output") {
+    outputFormat = "json"
+  }
+
+  formatter, err := app.NewFormatter(outputFormat, app.FormatterOptions{Template: outputTemplate})
+  if err != nil {
+    return err
+  }
+  resultFormatter = formatter
+  machineOutput = outputFormat != app.DefaultOutputFormat
+  return nil
+}
+
+/*
+This will write the result of a command in the output
+format and exit, the console function is the table output
+*/
+func writeOutput(output app.MachineOutput, console func(), exitCode int) {
+  err := resultFormatter.Format(os.Stdout, app.CommandResult{
+    Output: output,
+    Console: console,
+  })
+  if err != nil {
+    logger.LogErrorExit("Error writing output", 100, err)
+  }
+  os.Exit(exitCode)
+}
+
+/*
+This will check if the command prints a single raw value,
+commands do that when they are asked for one --field and
+generate does it when the file is printed
+*/
+func rawValueOutput(cmd *cobra.Command) bool {
+  field := cmd.Flags().Lookup("field")
+  if field != nil && field.Changed {
+    return true
+  }
+  return cmd == generateCmd && generateOutputFile == ""
+}
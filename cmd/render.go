@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dgutierrez1287/vault-util/app"
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/dgutierrez1287/vault-util/util"
+	"github.com/spf13/cobra"
+)
+
+// render flags
+var renderFiles []string
+var renderMode string
+var renderWatch bool
+var renderPollInterval time.Duration
+var reloadCommand string
+
+var renderCmd = &cobra.Command{
+  Use: "render",
+  Short: "Renders config files from templates with secrets",
+  Long: "Renders go text/template files with secret values. Templates can use secret \"mount/path\" \"field\" for a field or secret \"mount/path\" for all of the data, secrets \"mount/prefix\" for every secret under a prefix keyed by the relative key, base64 and toJSON. Files are written atomically and only when they change. With --watch the secrets the templates read are polled and the templates are rendered again when a kv v2 secret changes or a secret is added or removed under a prefix read with secrets, the reload command is run after files change",
+  Run: func(cmd *cobra.Command, args []string) {
+    if !machineOutput {
+      fmt.Println(util.TitleString)
+    }
+
+    defaultMode, err := app.ParseFileMode(renderMode)
+    if err != nil {
+      logger.LogErrorExit("Error parsing file mode", 100, err)
+    }
+
+    var templates []app.RenderTemplate
+    for _, file := range renderFiles {
+      renderTemplate, err := app.ParseRenderTemplate(file, defaultMode)
+      if err != nil {
+        logger.LogErrorExit("Error parsing template file", 100, err)
+      }
+      templates = append(templates, renderTemplate)
+    }
+
+    if renderWatch && machineOutput && outputFormat != "json" {
+      logger.LogErrorExit("Error --watch only supports table and json output", 100,
+        fmt.Errorf("cannot watch with %s output", outputFormat))
+    }
+
+    ctx := context.Background()
+    vaultClient := getVaultClient(&ctx)
+
+    opts := app.RenderOptions{
+      Templates: templates,
+      Watch: renderWatch,
+      PollInterval: renderPollInterval,
+      ReloadCommand: reloadCommand,
+    }
+
+    var lastResults []app.RenderResult
+    logger.LogInfo("Rendering templates")
+    err = app.Render(vaultClient, opts, func(results []app.RenderResult) {
+      lastResults = results
+      if !renderWatch {
+        return
+      }
+
+      // in watch mode every render is printed as it happens
+      if machineOutput {
+        output, _ := app.RenderOutput{ExitCode: 0, Files: results}.GetOutputJson()
+        fmt.Println(output)
+        return
+      }
+      app.RenderConsoleOutput(results)
+    })
+    if err != nil {
+      logger.LogErrorExit("Error rendering templates", 250, err)
+    }
+
+    logger.LogDebug("Outputing results")
+    machineReadableOutput := app.RenderOutput{
+      ExitCode: 0,
+      Files: lastResults,
+    }
+    writeOutput(machineReadableOutput, func() {
+      app.RenderConsoleOutput(lastResults)
+    }, 0)
+  },
+}
+
+func init() {
+  // Command specific cli options
+  renderCmd.PersistentFlags().StringSliceVarP(&renderFiles, "file", "", nil, "A template to render in the form source:destination or source:destination:mode, can be passed more than once")
+  renderCmd.PersistentFlags().StringVarP(&renderMode, "mode", "", "0600", "(Optional) The permissions of rendered files without a mode")
+  renderCmd.PersistentFlags().BoolVarP(&renderWatch, "watch", "", false, "(Optional) Keep running and render again when a secret changes")
+  renderCmd.PersistentFlags().DurationVarP(&renderPollInterval, "poll-interval", "", 30*time.Second, "(Optional) How often to check for secret changes with --watch")
+  renderCmd.PersistentFlags().StringVarP(&reloadCommand, "reload-command", "", "", "(Optional) A shell command to run after rendered files change")
+
+  // Add command
+  RootCmd.AddCommand(renderCmd)
+}
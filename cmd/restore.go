@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dgutierrez1287/vault-util/app"
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/dgutierrez1287/vault-util/util"
+	"github.com/spf13/cobra"
+)
+
+// restore flags
+var mountMappings []string
+var createMounts bool
+
+var restoreCmd = &cobra.Command{
+  Use: "restore",
+  Short: "Restores a backup archive",
+  Long: "Restores a backup archive written with backup into the same or a different vault, mounts can be remapped with --map-mount old=new. Runs as a dry run unless --apply is passed",
+  Run: func(cmd *cobra.Command, args []string) {
+    var machineReadableOutput app.PlanOutput
+
+    if !machineOutput {
+      fmt.Println(util.TitleString)
+    }
+
+    mountMap, err := app.ParseMountMap(mountMappings)
+    if err != nil {
+      logger.LogErrorExit("Error parsing mount mappings", 100, err)
+    }
+
+    opts := app.RestoreOptions{
+      MountMap: mountMap,
+      CreateMounts: createMounts,
+    }
+
+    ctx := context.Background()
+    vaultClient := getVaultClient(&ctx)
+
+    logger.LogInfo("Reading backup file", "file", backupFile)
+    backup, err := app.ReadBackupFile(backupFile, vaultClient)
+    if err != nil {
+      logger.LogErrorExit("Error reading the backup file", 100, err)
+    }
+
+    logger.LogInfo("Building restore plan")
+    plan, err := backup.BuildRestorePlan(vaultClient, opts)
+    if err != nil {
+      logger.LogErrorExit("Error building the restore plan", 250, err)
+    }
+
+    if applyChanges {
+      logger.LogInfo("Restoring backup")
+      result := backup.Restore(vaultClient, plan, opts)
+
+      logger.LogDebug("Outputing results")
+      outputPlanApplyResult(result, "")
+    }
+
+    logger.LogDebug("Outputing plan")
+    machineReadableOutput.ExitCode = 0
+    machineReadableOutput.Plan = plan.Redacted()
+    writeOutput(machineReadableOutput, func() {
+      app.PlanConsoleOutput(plan, showValues)
+      fmt.Println("Dry run, use --apply to make these changes")
+    }, 0)
+  },
+}
+
+func init() {
+  // Command specific cli options
+  restoreCmd.PersistentFlags().StringVarP(&backupFile, "backup-file", "", "", "The backup archive to restore")
+  restoreCmd.PersistentFlags().StringSliceVarP(&mountMappings, "map-mount", "", nil, "(Optional) Restore a mount to a different mount in the form old=new")
+  restoreCmd.PersistentFlags().BoolVarP(&createMounts, "create-mounts", "", false, "(Optional) Create kv mounts that do not exist in the target vault")
+  restoreCmd.PersistentFlags().BoolVarP(&applyChanges, "apply", "", false, "(Optional) Make the changes, without this it is a dry run")
+  restoreCmd.PersistentFlags().BoolVarP(&showValues, "show-values", "", false, "(Optional) Show secret values in the plan instead of masking them")
+
+  // Required command cli options
+  restoreCmd.MarkPersistentFlagRequired("backup-file")
+
+  // Add command
+  RootCmd.AddCommand(restoreCmd)
+}
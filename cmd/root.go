@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 
+  "github.com/dgutierrez1287/vault-util/app"
   "github.com/dgutierrez1287/vault-util/logger"
 	"github.com/spf13/cobra"
 )
@@ -27,13 +28,29 @@ var vaultUrl string
 var token string
 var vaultName string
 
+// age identity file for encrypted secrets files
+var ageIdentityFile string
+
+// bulk operation throttling flags
+var concurrency int
+var requestsPerSecond float64
+
 
 var RootCmd = &cobra.Command{
   Use: "vault-util",
   Short: "A vault utility cli",
   Long: "A vault utility to add functionality and add ease of use",
   PersistentPreRun: func(cmd *cobra.Command, args []string) {
+    outputErr := setOutputFormat(cmd)
+    logger.SetRawOutput(rawValueOutput(cmd))
     logger.InitLogging(debug, logColorize, machineOutput)
+    if outputErr != nil {
+      logger.LogErrorExit("Error setting up the output format", 100, outputErr)
+    }
+
+    if ageIdentityFile != "" {
+      app.AgeIdentityFile = ageIdentityFile
+    }
   },
   Run: func(cmd *cobra.Command, args []string) {
     fmt.Println("vault-util, Use --help for help")
@@ -60,7 +77,15 @@ func init() {
   This will supress all other output and only output json at the end
   that is machine readable
   */
-  RootCmd.PersistentFlags().BoolVarP(&machineOutput, "machine-output", "m", false, "Enables machine output for this to be run by another script")
+  RootCmd.PersistentFlags().BoolVarP(&machineOutput, "machine-output", "m", false, "Enables machine output for this to be run by another script, the same as --output json")
+
+  /*
+  output format
+  every command result is rendered by the formatter for
+  this format, table is the console output
+  */
+  RootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", app.DefaultOutputFormat, "(Optional) The output format, json, yaml, table, csv, env or template")
+  RootCmd.PersistentFlags().StringVarP(&outputTemplate, "template", "", "", "(Optional) The go template used with --output template, it sees the same names as the json output")
 
   /*
   options that will be used for multiple command 
@@ -72,6 +97,9 @@ func init() {
   // secret key
   RootCmd.PersistentFlags().StringVarP(&secretKey, "secret-key", "", "", "Secret key")
 
+  // age identity file used to decrypt secrets files
+  RootCmd.PersistentFlags().StringVarP(&ageIdentityFile, "age-identity", "", "", "(Optional) The age identity file used to decrypt secrets files")
+
   /*
   Vault connection options
   */
@@ -92,6 +120,15 @@ func init() {
 
   // root token for vault 
   RootCmd.PersistentFlags().StringVarP(&token, "token", "", "", "The root token for access to vault")
+
+  /*
+  Bulk operation options
+  */
+  // number of workers for bulk operations and listing
+  RootCmd.PersistentFlags().IntVarP(&concurrency, "concurrency", "", 4, "(Optional) The number of concurrent requests for bulk operations")
+
+  // client side rate limit
+  RootCmd.PersistentFlags().Float64VarP(&requestsPerSecond, "rps", "", 0, "(Optional) The max requests per second sent to vault, 0 is unlimited")
 }
 
 
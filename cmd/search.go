@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dgutierrez1287/vault-util/app"
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/dgutierrez1287/vault-util/util"
+	"github.com/spf13/cobra"
+)
+
+// search flags
+var searchPattern string
+var searchGlob bool
+var searchPaths bool
+var searchFields bool
+var searchValues bool
+var searchMountNames []string
+
+var searchCmd = &cobra.Command{
+  Use: "search",
+  Short: "Searches secrets by path, field name and value",
+  Long: "Searches secret paths and field names, and with --values field values, in one or more kv mounts for a regex or glob pattern. Only paths and field names are shown unless --show-values is passed",
+  Run: func(cmd *cobra.Command, args []string) {
+    var machineReadableOutput app.SearchOutput
+
+    if !machineOutput {
+      fmt.Println(util.TitleString)
+    }
+
+    ctx := context.Background()
+    vaultClient := getVaultClient(&ctx)
+
+    mounts := searchMountNames
+    if mountName != "" {
+      mounts = append(mounts, mountName)
+    }
+
+    opts := app.SearchOptions{
+      Pattern: searchPattern,
+      Glob: searchGlob,
+      Paths: searchPaths,
+      Fields: searchFields,
+      Values: searchValues,
+      Mounts: mounts,
+      ShowValues: showValues,
+    }
+
+    logger.LogInfo("Searching secrets", "pattern", searchPattern)
+    matches, secretErrors, err := app.Search(vaultClient, opts)
+    if err != nil {
+      logger.LogErrorExit("Error searching secrets", 250, err)
+    }
+
+    logger.LogDebug("Outputing results")
+    machineReadableOutput.ExitCode = 0
+    machineReadableOutput.Matches = matches
+    machineReadableOutput.Errors = secretErrors
+    writeOutput(machineReadableOutput, func() {
+      app.SearchConsoleOutput(matches, secretErrors)
+    }, 0)
+  },
+}
+
+func init() {
+  // Command specific cli options
+  searchCmd.PersistentFlags().StringVarP(&searchPattern, "pattern", "", "", "The regex to search for, or a glob with --glob")
+  searchCmd.PersistentFlags().BoolVarP(&searchGlob, "glob", "", false, "(Optional) The pattern is a glob that must match the whole path, field name or value")
+  searchCmd.PersistentFlags().BoolVarP(&searchPaths, "paths", "", true, "(Optional) Search secret paths")
+  searchCmd.PersistentFlags().BoolVarP(&searchFields, "fields", "", true, "(Optional) Search field names")
+  searchCmd.PersistentFlags().BoolVarP(&searchValues, "values", "", false, "(Optional) Search field values")
+  searchCmd.PersistentFlags().StringSliceVarP(&searchMountNames, "mount", "", nil, "(Optional) The kv mounts to search, defaults to every kv mount")
+  searchCmd.PersistentFlags().BoolVarP(&showValues, "show-values", "", false, "(Optional) Show the values of matched fields")
+
+  // Required command cli options
+  searchCmd.MarkPersistentFlagRequired("pattern")
+
+  // Add command
+  RootCmd.AddCommand(searchCmd)
+}
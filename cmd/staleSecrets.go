@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dgutierrez1287/vault-util/app"
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/dgutierrez1287/vault-util/util"
+	"github.com/spf13/cobra"
+)
+
+// stale secrets flags
+var staleMountNames []string
+var staleWarnDays int
+var staleCriticalDays int
+var staleAll bool
+var staleFailOn string
+
+var staleSecretsCmd = &cobra.Command{
+  Use: "stale-secrets",
+  Short: "Reports secrets that have not been rotated",
+  Long: "Reports kv v2 secrets that have not been updated in the warn or critical number of days, grouped by mount and owner. Exits 2 when there are critical secrets and, with --fail-on warn, 1 when there are warnings so CI can fail on overdue secrets",
+  Run: func(cmd *cobra.Command, args []string) {
+    var machineReadableOutput app.StaleOutput
+
+    if !machineOutput {
+      fmt.Println(util.TitleString)
+    }
+
+    // check fail on before doing any work
+    _, err := app.StaleReport{}.ExitCode(staleFailOn)
+    if err != nil {
+      logger.LogErrorExit("Error unknown fail on status", 100, err)
+    }
+
+    ctx := context.Background()
+    vaultClient := getVaultClient(&ctx)
+
+    mounts := staleMountNames
+    if mountName != "" {
+      mounts = append(mounts, mountName)
+    }
+
+    opts := app.StaleOptions{
+      Mounts: mounts,
+      WarnDays: staleWarnDays,
+      CriticalDays: staleCriticalDays,
+      All: staleAll,
+    }
+
+    logger.LogInfo("Checking secrets for staleness")
+    report, err := app.FindStaleSecrets(vaultClient, opts)
+    if err != nil {
+      logger.LogErrorExit("Error checking secrets for staleness", 250, err)
+    }
+
+    exitCode, _ := report.ExitCode(staleFailOn)
+
+    logger.LogDebug("Outputing results")
+    machineReadableOutput.ExitCode = exitCode
+    machineReadableOutput.WarnDays = staleWarnDays
+    machineReadableOutput.CriticalDays = staleCriticalDays
+    machineReadableOutput.Report = report
+    writeOutput(machineReadableOutput, func() {
+      app.StaleConsoleOutput(report, staleWarnDays, staleCriticalDays)
+    }, exitCode)
+  },
+}
+
+func init() {
+  // Command specific cli options
+  staleSecretsCmd.PersistentFlags().StringSliceVarP(&staleMountNames, "mount", "", nil, "(Optional) The kv v2 mounts to check, defaults to every kv v2 mount")
+  staleSecretsCmd.PersistentFlags().IntVarP(&staleWarnDays, "warn-days", "", 90, "(Optional) Secrets not updated in this many days are a warning, 0 turns warnings off")
+  staleSecretsCmd.PersistentFlags().IntVarP(&staleCriticalDays, "critical-days", "", 180, "(Optional) Secrets not updated in this many days are critical, 0 turns critical off")
+  staleSecretsCmd.PersistentFlags().BoolVarP(&staleAll, "all", "", false, "(Optional) Report every secret, not only the stale ones")
+  staleSecretsCmd.PersistentFlags().StringVarP(&staleFailOn, "fail-on", "", "critical", "(Optional) The status that makes the command fail, warn, critical or never")
+
+  // Add command
+  RootCmd.AddCommand(staleSecretsCmd)
+}
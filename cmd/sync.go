@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dgutierrez1287/vault-util/app"
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/dgutierrez1287/vault-util/util"
+	"github.com/spf13/cobra"
+)
+
+// sync flags
+var syncScope string
+var protectedPaths []string
+var maxDeletions int
+
+var syncCmd = &cobra.Command{
+  Use: "sync",
+  Short: "Makes a secret mount match a secrets file",
+  Long: "Makes a secret mount match a secrets file by creating, updating and with --prune deleting secrets, runs as a dry run unless --apply is passed",
+  Run: func(cmd *cobra.Command, args []string) {
+    var machineReadableOutput app.PlanOutput
+
+    if !machineOutput {
+      fmt.Println(util.TitleString)
+    }
+
+    requireMountName()
+
+    ctx := context.Background()
+    vaultClient := getVaultClient(&ctx)
+
+    logger.LogInfo("Reading secrets from json file", "file", secretsFile)
+    secrets, err := app.ReadSecretsFromJson(secretsFile, vaultClient, ctx)
+    if err != nil {
+      exitOnSecretsFileError(err)
+    }
+
+    opts := app.SyncOptions{
+      Mount: mountName,
+      Scope: syncScope,
+      Prune: prune,
+      Protected: protectedPaths,
+      MaxDeletions: maxDeletions,
+      Destroy: destroySecrets,
+    }
+
+    logger.LogInfo("Building sync plan", "mount", mountName)
+    plan, err := app.BuildSyncPlan(secrets, secretsFile, vaultClient, opts)
+    if err != nil {
+      logger.LogErrorExit("Error building the sync plan", 250, err)
+    }
+
+    if applyChanges {
+      logger.LogInfo("Applying sync plan")
+      result := plan.Apply(vaultClient)
+
+      logger.LogDebug("Outputing results")
+      outputPlanApplyResult(result, "")
+    }
+
+    logger.LogDebug("Outputing plan")
+    machineReadableOutput.ExitCode = 0
+    machineReadableOutput.Plan = plan.Redacted()
+    writeOutput(machineReadableOutput, func() {
+      app.PlanConsoleOutput(plan, showValues)
+      fmt.Println("Dry run, use --apply to make these changes")
+    }, 0)
+  },
+}
+
+func init() {
+  // Command specific cli options
+  syncCmd.PersistentFlags().StringVarP(&secretsFile, "secrets-file", "", "", "The json file that contains the secrets the mount should match")
+  syncCmd.PersistentFlags().StringVarP(&syncScope, "scope", "", "", "(Optional) The folder in the mount that the secrets file owns, defaults to the whole mount")
+  syncCmd.PersistentFlags().BoolVarP(&prune, "prune", "", false, "(Optional) Delete secrets in the scope that are not in the secrets file")
+  syncCmd.PersistentFlags().StringSliceVarP(&protectedPaths, "protected", "", nil, "(Optional) Glob patterns of keys that are never deleted")
+  syncCmd.PersistentFlags().IntVarP(&maxDeletions, "max-deletions", "", 10, "(Optional) The max number of secrets that can be deleted, -1 is unlimited")
+  syncCmd.PersistentFlags().BoolVarP(&destroySecrets, "destroy", "", false, "(Optional) With --prune, destroy every version and the metadata of pruned secrets instead of deleting the current version")
+  syncCmd.PersistentFlags().BoolVarP(&applyChanges, "apply", "", false, "(Optional) Make the changes, without this it is a dry run")
+  syncCmd.PersistentFlags().BoolVarP(&showValues, "show-values", "", false, "(Optional) Show secret values in the plan instead of masking them")
+
+  // Required command cli options
+  syncCmd.MarkPersistentFlagRequired("secrets-file")
+
+  // Add command
+  RootCmd.AddCommand(syncCmd)
+}
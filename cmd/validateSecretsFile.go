@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/dgutierrez1287/vault-util/app"
+	"github.com/dgutierrez1287/vault-util/logger"
+	"github.com/dgutierrez1287/vault-util/util"
+	"github.com/spf13/cobra"
+)
+
+// validate flags
+var schemaOnly bool
+var printSchema bool
+
+var validateSecretsFileCmd = &cobra.Command{
+  Use: "validate-secrets-file",
+  Short: "Validates a secrets file",
+  Long: "Checks a secrets file against the secrets file JSON Schema and checks every secret against vault, the mount must exist and be kv, target keys must be unique and every secret must have data",
+  Run: func(cmd *cobra.Command, args []string) {
+    var machineReadableOutput app.ValidateOutput
+    var vaultClient *app.VaultClient
+
+    if printSchema {
+      fmt.Println(string(app.SecretsFileSchema))
+      os.Exit(0)
+    }
+
+    if secretsFile == "" {
+      logger.LogErrorExit("Error a secrets file is required", 100,
+        errors.New("--secrets-file must be set"))
+    }
+
+    if !machineOutput {
+      fmt.Println(util.TitleString)
+    }
+
+    if !schemaOnly {
+      ctx := context.Background()
+      vaultClient = getVaultClient(&ctx)
+    }
+
+    logger.LogInfo("Validating secrets file", "file", secretsFile)
+    issues, err := app.ValidateSecretsFile(secretsFile, vaultClient)
+    if err != nil {
+      logger.LogErrorExit("Error reading secrets file", 100, err)
+    }
+
+    exitCode := 0
+    if len(issues) > 0 {
+      exitCode = 100
+    }
+
+    logger.LogDebug("Outputing results")
+    machineReadableOutput.ExitCode = exitCode
+    machineReadableOutput.SecretsFile = secretsFile
+    machineReadableOutput.Valid = len(issues) == 0
+    machineReadableOutput.Issues = issues
+    writeOutput(machineReadableOutput, func() {
+      app.ValidateConsoleOutput(secretsFile, issues)
+    }, exitCode)
+  },
+}
+
+func init() {
+  // Command specific cli options
+  validateSecretsFileCmd.PersistentFlags().StringVarP(&secretsFile, "secrets-file", "", "", "The secrets file to validate")
+  validateSecretsFileCmd.PersistentFlags().BoolVarP(&schemaOnly, "schema-only", "", false, "(Optional) Only check the file against the schema, vault is not contacted")
+  validateSecretsFileCmd.PersistentFlags().BoolVarP(&printSchema, "print-schema", "", false, "(Optional) Print the secrets file JSON Schema and exit")
+
+  // Add command
+  RootCmd.AddCommand(validateSecretsFileCmd)
+}
+
+/*
+This will exit when reading a secrets file failed, a file
+that is not valid is a file error so it exits with 100
+*/
+func exitOnSecretsFileError(err error) {
+  var validationErr *app.ValidationError
+  if errors.As(err, &validationErr) {
+    logger.LogErrorExit("Error secrets file is not valid", 100, err)
+  }
+  logger.LogErrorExit("Error reading secrets from json file", 250, err)
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dgutierrez1287/vault-util/app"
+	"github.com/dgutierrez1287/vault-util/logger"
+)
+
+/*
+This will get the vault instance, if a vault name is passed
+the connection details come from the settings file otherwise
+they come from the command line options
+*/
+func getVaultInstance() *app.VaultInstance {
+  var vaultInstance *app.VaultInstance
+  var err error
+
+  if vaultName != "" {
+    logger.LogInfo("Vault name passed, getting connection details from settings file")
+
+    logger.LogInfo("Getting the settings file path")
+    settingsFilePath, err := app.ConfigFilePath()
+    if err != nil {
+      logger.LogErrorExit("Error getting settings file path", 200, err)
+    }
+
+    vaultInstance, err = app.GetVaultConfigFromSettings(vaultName, settingsFilePath)
+    if err != nil {
+      logger.LogErrorExit("Error getting the vault config from settings", 200, err)
+    }
+  } else {
+    logger.LogInfo("No Vault name is passed getting connection details from command line")
+
+    vaultInstance, err = app.NewVault(vaultUrl, token, skipTlsVerify,
+      caCertFile, caKeyFile)
+    if err != nil {
+      logger.LogErrorExit("Error creating the vault instance", 150, err)
+    }
+  }
+  return vaultInstance
+}
+
+/*
+This will get a vault client for a vault saved in
+the settings file
+*/
+func getNamedVaultClient(name string, ctx *context.Context) *app.VaultClient {
+  logger.LogInfo("Getting the settings file path")
+  settingsFilePath, err := app.ConfigFilePath()
+  if err != nil {
+    logger.LogErrorExit("Error getting settings file path", 200, err)
+  }
+
+  vaultInstance, err := app.GetVaultConfigFromSettings(name, settingsFilePath)
+  if err != nil {
+    logger.LogErrorExit("Error getting the vault config from settings", 200, err)
+  }
+
+  logger.LogInfo("Getting vault client", "vault", name)
+  vaultClient, err := app.NewClient(*vaultInstance, ctx)
+  if err != nil {
+    logger.LogErrorExit("Error getting vault client", 250, err)
+  }
+
+  vaultClient.SetThrottling(concurrency, requestsPerSecond)
+  return vaultClient
+}
+
+/*
+This will get a vault client using the connection details
+from getVaultInstance
+*/
+func getVaultClient(ctx *context.Context) *app.VaultClient {
+  vaultInstance := getVaultInstance()
+
+  logger.LogInfo("Getting vault client")
+  vaultClient, err := app.NewClient(*vaultInstance, ctx)
+  if err != nil {
+    logger.LogErrorExit("Error getting vault client", 250, err)
+  }
+
+  vaultClient.SetThrottling(concurrency, requestsPerSecond)
+  return vaultClient
+}
+
+/*
+This will exit when the global --secret-mount is not set,
+it belongs to the root command so the commands that need
+it cannot mark it required
+*/
+func requireMountName() {
+  if mountName == "" {
+    logger.LogErrorExit("Error no secret mount", 100, errors.New("--secret-mount is required"))
+  }
+}
@@ -11,6 +11,16 @@ import (
 var Logger hclog.Logger
 var LogLevel string
 var machineOutput bool
+var rawOutput bool
+
+/*
+This will turn raw output on or off, raw output is a single
+value on stdout so only errors are logged and they go to
+stderr. It must be set before logging is initialized
+*/
+func SetRawOutput(raw bool) {
+  rawOutput = raw
+}
 
 /*
 initialize logging, this will set level, colorization
@@ -24,7 +34,9 @@ func InitLogging(debug bool, colorize bool, machineOnlyOutput bool) {
   // debug output setup
   if debug {
     LogLevel = "DEBUG"
-    fmt.Println("Debugging enabled")
+    if !rawOutput {
+      fmt.Println("Debugging enabled")
+    }
   } else {
     LogLevel = "INFO"
   }
@@ -36,7 +48,7 @@ func InitLogging(debug bool, colorize bool, machineOnlyOutput bool) {
     colorOpt = hclog.ColorOption(hclog.ColorOff)
   }
 
-  if !machineOnlyOutput {
+  if !machineOnlyOutput && !rawOutput {
     fmt.Printf("loglevel %s \n", LogLevel)
   }
 
@@ -53,7 +65,7 @@ This will wrap info logging to handle if it should be
 written to console based on machine output setting
 */
 func LogInfo(message string, args ...interface{}) {
-  if !machineOutput {
+  if !machineOutput && !rawOutput {
     Logger.Info(message, args...)
   }
 }
@@ -0,0 +1,55 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+)
+
+/*
+This will write a file atomically by writing to a temp
+file in the same directory and renaming it over the
+destination, readers never see a partly written file
+*/
+func WriteFileAtomic(filePath string, data []byte, perm os.FileMode) error {
+  return WriteFileAtomicOwner(filePath, data, perm, -1, -1)
+}
+
+/*
+This will write a file atomically and set its owner before
+it is renamed into place, a uid or gid of -1 is not changed
+*/
+func WriteFileAtomicOwner(filePath string, data []byte, perm os.FileMode,
+  uid int, gid int) error {
+
+  tmpFile, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".tmp-*")
+  if err != nil {
+    return err
+  }
+  tmpName := tmpFile.Name()
+
+  _, err = tmpFile.Write(data)
+  if err == nil {
+    err = tmpFile.Sync()
+  }
+  closeErr := tmpFile.Close()
+  if err == nil {
+    err = closeErr
+  }
+  if err == nil {
+    err = os.Chmod(tmpName, perm)
+  }
+  if err == nil && (uid != -1 || gid != -1) {
+    err = os.Chown(tmpName, uid, gid)
+  }
+  if err != nil {
+    os.Remove(tmpName)
+    return err
+  }
+
+  err = os.Rename(tmpName, filePath)
+  if err != nil {
+    os.Remove(tmpName)
+    return err
+  }
+  return nil
+}